@@ -0,0 +1,117 @@
+package filters
+
+import (
+	"math/big"
+	"reflect"
+	"slices"
+
+	"github.com/oarkflow/filters/utils"
+)
+
+// compareLength orders the length of the array or map data against the
+// number value.
+func compareLength(data, value any) (int, error) {
+	rv := reflect.ValueOf(data)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if n, ok := utils.ToRat(value); ok {
+			return big.NewRat(int64(rv.Len()), 1).Cmp(n), nil
+		}
+	}
+	return 0, typeMismatch(data, value)
+}
+
+func checkLenEqual(data, value any) (bool, error) {
+	c, err := compareLength(data, value)
+	return err == nil && c == 0, err
+}
+
+func checkLenGreaterThan(data, value any) (bool, error) {
+	c, err := compareLength(data, value)
+	return err == nil && c > 0, err
+}
+
+func checkLenBetween(data, value any) (bool, error) {
+	bounds := sqlValues(value)
+	if len(bounds) != 2 {
+		return false, typeMismatch(data, value)
+	}
+	from, err := compareLength(data, bounds[0])
+	if err != nil {
+		return false, err
+	}
+	to, err := compareLength(data, bounds[1])
+	return err == nil && from >= 0 && to <= 0, err
+}
+
+// collections returns the elements of the array data and the values, a list
+// or a single value, it is compared with.
+func collections(data, value any) ([]any, []any, error) {
+	kind := reflect.ValueOf(data).Kind()
+	if value == nil || (kind != reflect.Slice && kind != reflect.Array) {
+		return nil, nil, typeMismatch(data, value)
+	}
+	return sqlValues(data), sqlValues(value), nil
+}
+
+// hasElement reports whether list holds an element equal to v, comparing
+// numbers by value and strings without regard to case.
+func hasElement(list []any, v any) bool {
+	return slices.ContainsFunc(list, func(element any) bool {
+		matched, err := checkComparison(element, v, true)
+		return err == nil && matched
+	})
+}
+
+func checkContainsAll(data, value any) (bool, error) {
+	elements, values, err := collections(data, value)
+	if err != nil {
+		return false, err
+	}
+	for _, v := range values {
+		if !hasElement(elements, v) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func checkContainsAny(data, value any) (bool, error) {
+	elements, values, err := collections(data, value)
+	if err != nil {
+		return false, err
+	}
+	for _, v := range values {
+		if hasElement(elements, v) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func checkSubsetOf(data, value any) (bool, error) {
+	elements, values, err := collections(data, value)
+	if err != nil {
+		return false, err
+	}
+	for _, element := range elements {
+		if !hasElement(values, element) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func checkHasDuplicates(data, value any) (bool, error) {
+	kind := reflect.ValueOf(data).Kind()
+	if kind != reflect.Slice && kind != reflect.Array {
+		return false, typeMismatch(data, value)
+	}
+	elements := sqlValues(data)
+	for i, element := range elements {
+		if hasElement(elements[i+1:], element) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
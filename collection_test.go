@@ -0,0 +1,98 @@
+package filters_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/oarkflow/filters"
+)
+
+func TestCollectionOperators(t *testing.T) {
+	data := map[string]any{
+		"tags":   []any{"A", "b", "c"},
+		"roles":  []string{"admin", "editor"},
+		"scores": []any{1.0, json.Number("2"), 3},
+		"ids":    []int{1, 2, 2},
+		"empty":  []any{},
+		"attrs":  map[string]any{"x": 1},
+	}
+	tests := []struct {
+		filter *filters.Filter
+		want   bool
+	}{
+		{filters.NewFilter("tags", filters.LenEqual, 3), true},
+		{filters.NewFilter("tags", filters.LenGreaterThan, "3"), false},
+		{filters.NewFilter("empty", filters.LenBetween, []int{1, 5}), false},
+		{filters.NewFilter("roles", filters.LenBetween, []int{1, 5}), true},
+		{filters.NewFilter("attrs", filters.LenEqual, 1), true},
+		{filters.NewFilter("tags", filters.ContainsAll, []string{"a", "b"}), true},
+		{filters.NewFilter("tags", filters.ContainsAll, []string{"a", "d"}), false},
+		{filters.NewFilter("tags", filters.ContainsAll, "c"), true},
+		{filters.NewFilter("empty", filters.ContainsAll, []any{}), true},
+		{filters.NewFilter("scores", filters.ContainsAll, []int{1, 2}), true},
+		{filters.NewFilter("tags", filters.ContainsAny, []string{"d", "C"}), true},
+		{filters.NewFilter("empty", filters.ContainsAny, []string{"a"}), false},
+		{filters.NewFilter("roles", filters.SubsetOf, []string{"admin", "editor", "viewer"}), true},
+		{filters.NewFilter("roles", filters.SubsetOf, []string{"admin"}), false},
+		{filters.NewFilter("empty", filters.SubsetOf, []string{"admin"}), true},
+		{filters.NewFilter("scores", filters.SupersetOf, []any{"3", 1.0}), true},
+		{filters.NewFilter("roles", filters.Disjoint, []string{"viewer"}), true},
+		{filters.NewFilter("roles", filters.Disjoint, []string{"Admin"}), false},
+		{filters.NewFilter("ids", filters.HasDuplicates, nil), true},
+		{filters.NewFilter("scores", filters.HasDuplicates, nil), false},
+	}
+	for _, test := range tests {
+		if got, err := test.filter.MatchE(data); err != nil || got != test.want {
+			t.Errorf("%s %s %v: got %v, %v, want %v", test.filter.Field, test.filter.Operator, test.filter.Value, got, err, test.want)
+		}
+	}
+	for _, filter := range []*filters.Filter{
+		filters.NewFilter("attrs", filters.ContainsAny, []string{"x"}),
+		filters.NewFilter("tags", filters.LenEqual, "many"),
+		filters.NewFilter("attrs", filters.HasDuplicates, nil),
+	} {
+		if _, err := filter.MatchE(data); !errors.Is(err, filters.ErrTypeMismatch) {
+			t.Errorf("%s %s: got %v, want ErrTypeMismatch", filter.Field, filter.Operator, err)
+		}
+	}
+	if err := filters.NewFilter("tags", filters.LenBetween, 3).Validate(); err == nil {
+		t.Errorf("expected len_between without two bounds to be invalid")
+	}
+}
+
+func TestCollectionOperatorsFromQueries(t *testing.T) {
+	data := map[string]any{"tags": []any{"a", "b", "b"}}
+	list, err := filters.ParseQuery("tags=contains_all:a,b&tags:len_between:1,5&tags:has_duplicates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("got %d filters", len(list))
+	}
+	for _, filter := range list {
+		if got, err := filter.MatchE(data); err != nil || !got {
+			t.Errorf("%s %s %v: got %v, %v", filter.Field, filter.Operator, filter.Value, got, err)
+		}
+	}
+
+	schema := filters.Schema{"ids": filters.SliceField, "ids.$": filters.IntField}
+	list, err = schema.ParseQuery("ids=subset_of:1,2,3&ids:len_eq:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filter := range list {
+		if got, err := filter.MatchE(map[string]any{"ids": []int{1, 3}}); err != nil || !got {
+			t.Errorf("%s %v: got %v, %v", filter.Operator, filter.Value, got, err)
+		}
+	}
+
+	mongo, err := filters.ToMongo(filters.NewFilter("tags", filters.ContainsAll, []string{"a", "b"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"tags": map[string]any{"$all": []any{"a", "b"}}}; !reflect.DeepEqual(mongo, want) {
+		t.Errorf("got %v, want %v", mongo, want)
+	}
+}
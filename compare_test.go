@@ -0,0 +1,53 @@
+package filters_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oarkflow/filters"
+)
+
+func TestOperatorsAcrossTypes(t *testing.T) {
+	// values as decoded from JSON, rules as written in Go
+	data := map[string]any{"age": 30.0, "score": json.Number("7.25"), "count": uint16(3), "wait": 90 * time.Second}
+	tests := []struct {
+		filter *filters.Filter
+		want   bool
+	}{
+		{filters.NewFilter("age", filters.Equal, 30), true},
+		{filters.NewFilter("age", filters.NotEqual, 30), false},
+		{filters.NewFilter("age", filters.GreaterThan, int64(29)), true},
+		{filters.NewFilter("age", filters.In, []int{25, 30}), true},
+		{filters.NewFilter("age", filters.NotIn, []any{"30"}), false},
+		{filters.NewFilter("age", filters.Between, []int{18, 30}), true},
+		{filters.NewFilter("score", filters.LessThanEqual, 7.25), true},
+		{filters.NewFilter("score", filters.Between, []any{"7", json.Number("7.5")}), true},
+		{filters.NewFilter("count", filters.Equal, 3.0), true},
+		{filters.NewFilter("wait", filters.GreaterThan, "1m"), true},
+		{filters.NewFilter("wait", filters.Between, []any{time.Minute, 2 * time.Minute}), true},
+	}
+	for _, test := range tests {
+		if got, err := test.filter.MatchE(data); err != nil || got != test.want {
+			t.Errorf("%s %s %v: got %v, %v, want %v", test.filter.Field, test.filter.Operator, test.filter.Value, got, err, test.want)
+		}
+	}
+}
+
+func TestOperatorsOnIncomparableValues(t *testing.T) {
+	data := map[string]any{"age": 25, "active": true, "stamp": "2024-01-02 03:04:05"}
+	for _, filter := range []*filters.Filter{
+		filters.NewFilter("age", filters.LessThanEqual, "abc"),
+		filters.NewFilter("age", filters.GreaterThan, "2024-01-02"),
+		filters.NewFilter("active", filters.GreaterThan, "abc"),
+		filters.NewFilter("stamp", filters.Between, []int{20, 30}),
+	} {
+		if got, err := filter.MatchE(data); got || !errors.Is(err, filters.ErrTypeMismatch) {
+			t.Errorf("%s %s %v: got %v, %v, want a type mismatch", filter.Field, filter.Operator, filter.Value, got, err)
+		}
+	}
+	if !filters.NewFilter("age", filters.NotEqual, "abc").Match(data) {
+		t.Errorf("expected values that cannot be compared to be not equal")
+	}
+}
@@ -0,0 +1,574 @@
+package filters
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/oarkflow/expr"
+	"github.com/oarkflow/expr/vm"
+)
+
+// CompiledCondition is a pre-validated evaluation plan produced by Compile.
+// Operators are resolved to functions, regular expressions and expressions are
+// compiled once and literal values are converted up front, so Match does no
+// parsing at runtime.
+type CompiledCondition interface {
+	Condition
+	// Source returns the condition the plan was compiled from.
+	Source() Condition
+}
+
+// Compile validates condition and builds a reusable evaluation plan for it.
+// Filter, FilterGroup, Rule and Join are compiled recursively; any other
+// Condition implementation is evaluated as-is.
+func Compile(condition Condition) (CompiledCondition, error) {
+	switch c := condition.(type) {
+	case nil:
+		return nil, errors.New("condition cannot be nil")
+	case CompiledCondition:
+		return c, nil
+	case *Filter:
+		return compileFilter(c)
+	case *FilterGroup:
+		return compileGroup(c)
+	case *Rule:
+		return compileRule(c)
+	case *Join:
+		return compileJoin(c)
+	default:
+		return &compiledCondition{source: c}, nil
+	}
+}
+
+// MustCompile is like Compile but panics if the condition cannot be compiled.
+func MustCompile(condition Condition) CompiledCondition {
+	compiled, err := Compile(condition)
+	if err != nil {
+		panic(err)
+	}
+	return compiled
+}
+
+// valueResolver reads a field or value for item. References are evaluated
+// against env, which is item itself unless the filter runs inside a
+// quantifier and can see the enclosing items.
+type valueResolver func(item, env any) (any, error)
+
+type compiledCondition struct {
+	source Condition
+}
+
+func (c *compiledCondition) Source() Condition {
+	return c.source
+}
+
+func (c *compiledCondition) MatchE(data any, opts ...MatchOptions) (bool, error) {
+	return MatchE(c.source, data, opts...)
+}
+
+func (c *compiledCondition) Match(data any) bool {
+	return c.source.Match(data)
+}
+
+type compiledFilter struct {
+	filter   *Filter
+	field    valueResolver
+	value    valueResolver
+	lookup   valueResolver
+	check    operatorFunc
+	dateOp   dateOperatorFunc
+	relative bool
+	// scoped is set when references may use the quantifier scope.
+	scoped   bool
+	countOp  string
+	elements CompiledCondition
+	program  *vm.Program
+	pattern  *regexp.Regexp
+}
+
+func compileFilter(filter *Filter) (*compiledFilter, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, filter.wrapError(fmt.Errorf("%w: %v", ErrInvalidFilter, err))
+	}
+	return newCompiledFilter(filter)
+}
+
+// newCompiledFilter builds the evaluation plan for an already validated filter.
+func newCompiledFilter(filter *Filter) (*compiledFilter, error) {
+	c := &compiledFilter{filter: filter}
+	_, computed := reference(filter.Field)
+	c.scoped = computed || hasReference(filter.Value) || filter.Operator == Expression
+	var err error
+	if c.field, err = compileField(filter.Field); err != nil {
+		return nil, filter.wrapError(err)
+	}
+	if c.value, err = compileValue(filter.Value, lowercaseOperators[filter.Operator]); err != nil {
+		return nil, filter.wrapError(err)
+	}
+	if c.lookup, err = compileLookup(filter.Lookup); err != nil {
+		return nil, filter.wrapError(err)
+	}
+	switch filter.Operator {
+	case Expression:
+		v, ok := filter.Value.(string)
+		if !ok {
+			return nil, filter.wrapError(fmt.Errorf("%w: expression must be a string", ErrInvalidFilter))
+		}
+		if c.program, err = compileExpr(v); err != nil {
+			return nil, filter.wrapError(err)
+		}
+	case Pattern:
+		v, ok := filter.Value.(string)
+		if !ok {
+			return nil, filter.wrapError(fmt.Errorf("%w: pattern must be a string", ErrInvalidFilter))
+		}
+		if c.pattern, err = regexp.Compile(v); err != nil {
+			return nil, filter.wrapError(fmt.Errorf("%w: %v", ErrInvalidFilter, err))
+		}
+	case Any, All, None:
+		if c.elements, err = Compile(filter.Value.(Condition)); err != nil {
+			return nil, filter.wrapError(fmt.Errorf("%w: %v", ErrInvalidFilter, err))
+		}
+	default:
+		if op, ok := countOperatorSymbols[filter.Operator]; ok {
+			c.countOp = op
+		} else if op, ok := dateOperatorFuncs[filter.Operator]; ok {
+			c.dateOp = op
+		} else {
+			c.check = operatorFuncs[filter.Operator]
+			c.relative = slices.ContainsFunc(sqlValues(filter.Value), isRelative)
+		}
+	}
+	return c, nil
+}
+
+func (c *compiledFilter) Source() Condition {
+	return c.filter
+}
+
+func (c *compiledFilter) Match(data any) bool {
+	matched, _ := c.match(data, MatchOptions{})
+	if c.filter.Reverse {
+		return !matched
+	}
+	return matched
+}
+
+func (c *compiledFilter) MatchE(data any, opts ...MatchOptions) (bool, error) {
+	matched, err := c.match(data, matchOptions(opts))
+	if err != nil {
+		return false, err
+	}
+	if c.filter.Reverse {
+		return !matched, nil
+	}
+	return matched, nil
+}
+
+func (c *compiledFilter) match(item any, opts MatchOptions) (bool, error) {
+	fieldValue, val, lookupData, err := c.resolve(item, opts)
+	if err != nil {
+		if matched, ok := c.missing(err, opts); ok {
+			return matched, nil
+		}
+		return false, c.filter.wrapError(err)
+	}
+	return c.evaluate(item, fieldValue, val, lookupData, opts)
+}
+
+// missing decides the outcome when err reports a field missing from the
+// data: exists and nexists decide it in every mode, any other operator does
+// not match in Lenient mode. ok is false when err must be reported.
+func (c *compiledFilter) missing(err error, opts MatchOptions) (matched, ok bool) {
+	if !errors.Is(err, ErrFieldNotFound) {
+		return false, false
+	}
+	if c.filter.Operator == Exists || c.filter.Operator == NotExists {
+		return c.filter.Operator == NotExists, true
+	}
+	return false, opts.Mode == Lenient
+}
+
+// resolve reads the field value, the comparison value and the lookup data for
+// item. When a lookup is in use its result replaces the comparison value.
+func (c *compiledFilter) resolve(item any, opts MatchOptions) (fieldValue, val, lookupData any, err error) {
+	env := c.env(item, opts)
+	if fieldValue, err = c.field(item, env); err != nil {
+		return
+	}
+	if val, err = c.value(item, env); err != nil {
+		return
+	}
+	if c.lookup != nil {
+		if lookupData, err = c.lookup(item, env); err != nil {
+			err = fmt.Errorf("%w: %v", ErrLookupFailed, err)
+			return
+		}
+		if c.countOp == "" && lookupData != nil {
+			val = lookupData
+		}
+	}
+	return
+}
+
+func (c *compiledFilter) evaluate(item, fieldValue, val, lookupData any, opts MatchOptions) (bool, error) {
+	if !checkLookup(fieldValue, lookupData) {
+		return false, nil
+	}
+	matched, err := c.apply(item, fieldValue, val, lookupData, opts)
+	if err != nil {
+		return matched, c.filter.wrapError(err)
+	}
+	return matched, nil
+}
+
+// apply runs the operator against resolved operands. Null field values are
+// compared like any other value but never reported as type mismatches.
+func (c *compiledFilter) apply(item, fieldValue, val, lookupData any, opts MatchOptions) (bool, error) {
+	var matched bool
+	var err error
+	switch {
+	case c.program != nil:
+		r, err := expr.Run(c.program, c.env(item, opts))
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrExpressionFailed, err)
+		}
+		return r != nil, nil
+	case c.pattern != nil:
+		vt, ok := fieldValue.(string)
+		if !ok {
+			err = typeMismatch(fieldValue, c.filter.Value)
+		}
+		matched = ok && c.pattern.MatchString(vt)
+	case c.countOp != "":
+		matched, err = validateCount(c.countOp, val, lookupData, fieldValue)
+	case c.elements != nil:
+		matched, err = quantify(c.filter.Operator, c.elements, fieldValue, item, opts)
+	case c.dateOp != nil:
+		matched, err = c.dateOp(fieldValue, val, opts.clock())
+	case c.check != nil:
+		if c.relative {
+			fieldValue, val = opts.clock().operands(fieldValue, val)
+		}
+		if opts.Decimal != nil && decimalOperators[c.filter.Operator] {
+			fieldValue, val = opts.Decimal.operands(fieldValue, val)
+		}
+		matched, err = c.check(fieldValue, val)
+	}
+	if fieldValue == nil {
+		return matched, nil
+	}
+	return matched, err
+}
+
+type compiledGroup struct {
+	group   *FilterGroup
+	filters []CompiledCondition
+}
+
+func compileGroup(group *FilterGroup) (*compiledGroup, error) {
+	if group.Operator != AND && group.Operator != OR {
+		return nil, fmt.Errorf("unsupported boolean operator: %s", group.Operator)
+	}
+	c := &compiledGroup{group: group, filters: make([]CompiledCondition, 0, len(group.Filters))}
+	for _, filter := range group.Filters {
+		compiled, err := Compile(filter)
+		if err != nil {
+			return nil, err
+		}
+		c.filters = append(c.filters, compiled)
+	}
+	return c, nil
+}
+
+func (c *compiledGroup) Source() Condition {
+	return c.group
+}
+
+func (c *compiledGroup) MatchE(data any, opts ...MatchOptions) (bool, error) {
+	matched := c.group.Operator == AND
+	for _, filter := range c.filters {
+		m, err := MatchE(filter, data, opts...)
+		if err != nil {
+			return false, err
+		}
+		if m != matched {
+			matched = !matched
+			break
+		}
+	}
+	if c.group.Reverse {
+		return !matched, nil
+	}
+	return matched, nil
+}
+
+func (c *compiledGroup) Match(data any) bool {
+	matched := c.group.Operator == AND
+	for _, filter := range c.filters {
+		if filter.Match(data) != matched {
+			matched = !matched
+			break
+		}
+	}
+	if c.group.Reverse {
+		return !matched
+	}
+	return matched
+}
+
+type compiledRule struct {
+	rule *Rule
+	node CompiledCondition
+	next CompiledCondition
+}
+
+func compileRule(rule *Rule) (*compiledRule, error) {
+	if rule.Node == nil {
+		return nil, errors.New("rule has no condition")
+	}
+	c := &compiledRule{rule: rule}
+	var err error
+	if c.node, err = Compile(rule.Node); err != nil {
+		return nil, err
+	}
+	if rule.Next != nil {
+		if c.next, err = Compile(rule.Next); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *compiledRule) Source() Condition {
+	return c.rule
+}
+
+func (c *compiledRule) Match(data any) bool {
+	matched := c.match(data)
+	if c.rule.Reverse {
+		return !matched
+	}
+	return matched
+}
+
+func (c *compiledRule) MatchE(data any, opts ...MatchOptions) (bool, error) {
+	matched, err := MatchE(c.node, data, opts...)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case c.next == nil:
+	case c.rule.Operator == AND && !matched:
+	case c.rule.Operator == OR && matched:
+	default:
+		if matched, err = MatchE(c.next, data, opts...); err != nil {
+			return false, err
+		}
+	}
+	if c.rule.Reverse {
+		return !matched, nil
+	}
+	return matched, nil
+}
+
+func (c *compiledRule) match(data any) bool {
+	matched := c.node.Match(data)
+	if c.rule.Operator == AND && !matched {
+		return false
+	}
+	if c.next == nil {
+		return matched
+	}
+	if c.rule.Operator == OR && matched {
+		return true
+	}
+	return c.next.Match(data)
+}
+
+type compiledJoin struct {
+	join  *Join
+	left  CompiledCondition
+	right CompiledCondition
+}
+
+func compileJoin(join *Join) (*compiledJoin, error) {
+	if join.Left == nil || join.Right == nil {
+		return nil, errors.New("missing left or right filter group")
+	}
+	if join.Operator != AND && join.Operator != OR {
+		return nil, errors.New("unsupported boolean operator")
+	}
+	c := &compiledJoin{join: join}
+	var err error
+	if c.left, err = compileGroup(join.Left); err != nil {
+		return nil, err
+	}
+	if c.right, err = compileGroup(join.Right); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *compiledJoin) Source() Condition {
+	return c.join
+}
+
+func (c *compiledJoin) MatchE(data any, opts ...MatchOptions) (bool, error) {
+	return matchJoinE(data, c.join.Operator, c.join.Reverse, c.left, c.right, opts)
+}
+
+func (c *compiledJoin) Match(data any) bool {
+	var matched bool
+	if c.join.Operator == AND {
+		matched = c.left.Match(data) && c.right.Match(data)
+	} else {
+		matched = c.left.Match(data) || c.right.Match(data)
+	}
+	if c.join.Reverse {
+		return !matched
+	}
+	return matched
+}
+
+// lowercaseOperators are compared case-insensitively, so their literal values
+// can be lowered once at compile time.
+var lowercaseOperators = map[Operator]bool{
+	Contains:      true,
+	NotContains:   true,
+	StartsWith:    true,
+	NotStartsWith: true,
+	EndsWith:      true,
+	NotEndsWith:   true,
+}
+
+var curlyBraces = strings.NewReplacer("{{", "", "}}", "")
+
+func compileExpr(input string) (*vm.Program, error) {
+	program, err := expr.Parse(curlyBraces.Replace(input))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExpressionFailed, err)
+	}
+	return program, nil
+}
+
+func compileField(field string) (valueResolver, error) {
+	if _, ok := reference(field); !ok {
+		return func(item, _ any) (any, error) {
+			return resolveField(item, field)
+		}, nil
+	}
+	return compileString(field, false)
+}
+
+func compileString(v string, lower bool) (valueResolver, error) {
+	ref, ok := reference(v)
+	if !ok {
+		if lower {
+			v = strings.ToLower(v)
+		}
+		return constant(v), nil
+	}
+	program, err := expr.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExpressionFailed, err)
+	}
+	return func(_, env any) (any, error) {
+		val, err := expr.Run(program, env)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrExpressionFailed, err)
+		}
+		return val, nil
+	}, nil
+}
+
+func compileValue(value any, lower bool) (valueResolver, error) {
+	var values []any
+	switch v := value.(type) {
+	case string:
+		return compileString(v, lower)
+	case []string:
+		values = make([]any, 0, len(v))
+		for _, s := range v {
+			values = append(values, s)
+		}
+	case []any:
+		values = v
+	default:
+		return constant(value), nil
+	}
+	resolvers := make([]valueResolver, 0, len(values))
+	literals := make([]any, 0, len(values))
+	dynamic := false
+	for _, val := range values {
+		s, ok := val.(string)
+		if !ok {
+			resolvers = append(resolvers, constant(val))
+			literals = append(literals, val)
+			continue
+		}
+		resolver, err := compileString(s, lower)
+		if err != nil {
+			return nil, err
+		}
+		if _, isRef := reference(s); isRef {
+			dynamic = true
+		} else {
+			literal, _ := resolver(nil, nil)
+			literals = append(literals, literal)
+		}
+		resolvers = append(resolvers, resolver)
+	}
+	if !dynamic {
+		return constant(literals), nil
+	}
+	return func(item, env any) (any, error) {
+		resolved := make([]any, 0, len(resolvers))
+		for _, resolver := range resolvers {
+			val, err := resolver(item, env)
+			if err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, val)
+		}
+		return resolved, nil
+	}, nil
+}
+
+func compileLookup(lookup *Lookup) (valueResolver, error) {
+	if lookup == nil {
+		return nil, nil
+	}
+	source := func(item, _ any) (any, error) {
+		if lookup.Data != nil {
+			return lookup.Data, nil
+		}
+		if lookup.Handler != nil {
+			return lookup.Handler(item, lookup.HandlerCondition)
+		}
+		return nil, nil
+	}
+	if lookup.Condition == "" {
+		return source, nil
+	}
+	program, err := compileExpr(lookup.Condition)
+	if err != nil {
+		return nil, err
+	}
+	return func(item, env any) (any, error) {
+		lookupData, err := source(item, env)
+		if err != nil {
+			return nil, err
+		}
+		return expr.Run(program, map[string]any{"data": item, "lookup": lookupData})
+	}, nil
+}
+
+func constant(value any) valueResolver {
+	return func(any, any) (any, error) {
+		return value, nil
+	}
+}
@@ -0,0 +1,76 @@
+package filters_test
+
+import (
+	"testing"
+
+	"github.com/oarkflow/filters"
+)
+
+var people = []map[string]any{
+	{"name": "John Doe", "age": 30, "city": "New York", "tags": []any{"a", "b"}, "created": "2022-06-15 15:30:00", "verified": "2022-06-15 15:45:00", "logged": "2022-06-15 15:33:00"},
+	{"name": "Jane Smith", "age": 25, "city": "Los Angeles", "tags": []any{"c"}, "created": "2023-01-01 12:00:00", "verified": "2023-01-01 12:00:00", "logged": "2023-02-01 12:00:00"},
+	{"name": "Bob Johnson", "age": 35, "city": nil, "tags": []any{}, "created": "2021-12-25 08:45:00", "verified": "2021-12-25 08:45:00", "logged": "2021-12-25 08:45:00"},
+}
+
+func TestCompileMatchesInterpreter(t *testing.T) {
+	rule := filters.NewRule()
+	rule.AddCondition(filters.OR, false,
+		filters.NewFilterGroup(filters.AND, false,
+			filters.NewFilter("age", filters.GreaterThan, 26),
+			filters.NewFilter("name", filters.StartsWith, "JOHN"),
+		),
+		filters.NewFilter("city", filters.IsNull, nil),
+	)
+	conditions := map[string]filters.Condition{
+		"eq":        filters.NewFilter("name", filters.Equal, "jane smith"),
+		"nin":       filters.NewFilter("age", filters.NotIn, []any{25, 35}),
+		"between":   filters.NewFilter("logged", filters.Between, []string{"{{created}}", "{{verified}}"}),
+		"pattern":   filters.NewFilter("name", filters.Pattern, "^J.*h$"),
+		"expr":      filters.NewFilter("name", filters.Expression, "age > 26"),
+		"contains":  &filters.Filter{Field: "city", Operator: filters.Contains, Value: "York", Reverse: true},
+		"rule":      rule,
+		"join":      &filters.Join{Operator: filters.OR, Left: filters.NewFilterGroup(filters.AND, false, filters.NewFilter("age", filters.Equal, 25)), Right: filters.NewFilterGroup(filters.AND, false, filters.NewFilter("age", filters.Equal, 35))},
+		"nested_or": filters.NewFilterGroup(filters.OR, true, filters.NewFilter("age", filters.LessThan, 26), filters.NewFilter("age", filters.GreaterThanEqual, 35)),
+	}
+	for name, condition := range conditions {
+		compiled, err := filters.Compile(condition)
+		if err != nil {
+			t.Fatalf("%s: compile: %v", name, err)
+		}
+		if compiled.Source() != condition {
+			t.Errorf("%s: source mismatch", name)
+		}
+		for i, person := range people {
+			if got, want := compiled.Match(person), condition.Match(person); got != want {
+				t.Errorf("%s: record %d: compiled=%v interpreted=%v", name, i, got, want)
+			}
+		}
+	}
+}
+
+func TestCompileRejectsInvalidFilters(t *testing.T) {
+	invalid := []filters.Condition{
+		filters.NewFilter("", filters.Equal, 1),
+		filters.NewFilter("age", filters.Operator("bogus"), 1),
+		filters.NewFilter("age", filters.Between, []any{1}),
+		filters.NewFilter("name", filters.Pattern, "("),
+		filters.NewFilterGroup("XOR", false, filters.NewFilter("age", filters.Equal, 1)),
+		filters.NewRule(),
+	}
+	for i, condition := range invalid {
+		if _, err := filters.Compile(condition); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
+
+func TestFilterMatchSeesChanges(t *testing.T) {
+	filter := filters.NewFilter("a", filters.Equal, 1)
+	if !filter.Match(map[string]any{"a": 1}) {
+		t.Fatalf("unexpected match result")
+	}
+	filter.Value = 2
+	if !filter.Match(map[string]any{"a": 2}) || filter.Match(map[string]any{"a": 1}) {
+		t.Errorf("expected Match to use the new value")
+	}
+}
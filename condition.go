@@ -3,12 +3,9 @@ package filters
 import (
 	"fmt"
 	"reflect"
-	"regexp"
-	"slices"
 	"strings"
 
 	convert "github.com/oarkflow/convert/v2"
-	"github.com/oarkflow/dipper"
 	"github.com/oarkflow/expr"
 
 	"github.com/oarkflow/filters/utils"
@@ -43,427 +40,363 @@ var (
 		NotZero:               {},
 		IsNull:                {},
 		NotNull:               {},
+		Exists:                {},
+		NotExists:             {},
 		ContainsCS:            {},
 		NotContainsCS:         {},
 		StartsWithCS:          {},
 		NotStartsWithCS:       {},
 		EndsWithCS:            {},
 		NotEndsWithCS:         {},
+		Any:                   {},
+		All:                   {},
+		None:                  {},
+		Before:                {},
+		After:                 {},
+		OnDate:                {},
+		WithinLast:            {},
+		WithinNext:            {},
+		AgeBetween:            {},
+		DayOfWeekIn:           {},
+		LenEqual:              {},
+		LenGreaterThan:        {},
+		LenBetween:            {},
+		ContainsAll:           {},
+		ContainsAny:           {},
+		SubsetOf:              {},
+		SupersetOf:            {},
+		Disjoint:              {},
+		HasDuplicates:         {},
 	}
-	countOperators = []Operator{
-		GreaterThanEqualCount,
-		GreaterThanCount,
-		LesserThanEqualCount,
-		LesserThanCount,
-		NotEqualCount,
-		EqualCount,
+	countOperatorSymbols = map[Operator]string{
+		GreaterThanEqualCount: ">=",
+		GreaterThanCount:      ">",
+		LesserThanEqualCount:  "<=",
+		LesserThanCount:       "<",
+		NotEqualCount:         "!=",
+		EqualCount:            "==",
+	}
+	operatorFuncs = map[Operator]operatorFunc{
+		Equal:            checkEq,
+		NotEqual:         checkNeq,
+		GreaterThan:      checkGt,
+		LessThan:         checkLt,
+		GreaterThanEqual: checkGte,
+		LessThanEqual:    checkLte,
+		Between:          checkBetween,
+		In:               checkIn,
+		NotIn:            not(checkIn),
+		Contains:         checkContains,
+		NotContains:      not(checkContains),
+		StartsWith:       checkStartsWith,
+		EndsWith:         checkEndsWith,
+		NotStartsWith:    not(checkStartsWith),
+		NotEndsWith:      not(checkEndsWith),
+		ContainsCS:       checkContainsCS,
+		NotContainsCS:    not(checkContainsCS),
+		StartsWithCS:     checkStartsWithCS,
+		NotStartsWithCS:  not(checkStartsWithCS),
+		EndsWithCS:       checkEndsWithCS,
+		NotEndsWithCS:    not(checkEndsWithCS),
+		IsZero:           checkIsZero,
+		NotZero:          checkNotZero,
+		IsNull:           checkIsNull,
+		NotNull:          checkNotNull,
+		Exists:           checkExists,
+		NotExists:        not(checkExists),
+		LenEqual:         checkLenEqual,
+		LenGreaterThan:   checkLenGreaterThan,
+		LenBetween:       checkLenBetween,
+		ContainsAll:      checkContainsAll,
+		ContainsAny:      checkContainsAny,
+		SubsetOf:         checkSubsetOf,
+		SupersetOf:       checkContainsAll,
+		Disjoint:         not(checkContainsAny),
+		HasDuplicates:    checkHasDuplicates,
 	}
 )
 
-func validatedCount(input string, lookupData any) bool {
+// operatorFunc compares a resolved field value against a resolved filter value.
+type operatorFunc func(data, value any) (bool, error)
+
+func validatedCount(input string, lookupData any) (bool, error) {
 	rs, err := expr.Eval(input, map[string]any{"data": lookupData})
 	if err != nil {
-		return false
+		return false, fmt.Errorf("%w: %v", ErrExpressionFailed, err)
 	}
 	converted, err := convert.ToBool(rs)
 	if err != nil {
-		return false
+		return false, fmt.Errorf("%w: %v", ErrExpressionFailed, err)
 	}
-	return converted
+	return converted, nil
 }
 
-func processValidate(op string, val any, lookupData, fieldValue any) bool {
-	fieldValue, err := utils.FilterSlice(lookupData, fieldValue)
-	if err != nil {
-		return false
+func processValidate(op string, val any, lookupData, fieldValue any) (bool, error) {
+	if lookupData != nil {
+		filtered, err := utils.FilterSlice(lookupData, fieldValue)
+		if err != nil {
+			return false, fmt.Errorf("%w: %v", ErrTypeMismatch, err)
+		}
+		fieldValue = filtered
 	}
 	return validatedCount(fmt.Sprintf("len(data) %s %v", op, val), fieldValue)
 }
 
-func validateCount(op string, vat any, lookupData, fieldValue any) bool {
+func validateCount(op string, vat any, lookupData, fieldValue any) (bool, error) {
 	val := reflect.ValueOf(fieldValue)
-	if val.Kind() == reflect.Slice {
-		if val.Len() > 0 && val.Index(0).Elem().Kind() == reflect.Slice {
-			for i := 0; i < val.Len(); i++ {
-				innerSlice := val.Index(i)
-				if !processValidate(op, vat, lookupData, innerSlice.Interface()) {
-					return false
-				}
+	if val.Kind() != reflect.Slice {
+		return false, fmt.Errorf("%w: count operators expect a slice, got %T", ErrTypeMismatch, fieldValue)
+	}
+	if val.Len() > 0 && isNestedSlice(val.Index(0)) {
+		for i := 0; i < val.Len(); i++ {
+			innerSlice := val.Index(i)
+			matched, err := processValidate(op, vat, lookupData, innerSlice.Interface())
+			if !matched {
+				return false, err
 			}
-			return true
-		} else {
-			return processValidate(op, vat, lookupData, fieldValue)
 		}
+		return true, nil
+	}
+	return processValidate(op, vat, lookupData, fieldValue)
+}
+
+func isNestedSlice(v reflect.Value) bool {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
 	}
-	return false
+	return v.Kind() == reflect.Slice
 }
 
 func match[T any](item T, filter *Filter) bool {
-	if !filter.validated {
-		if filter.Validate() != nil {
-			return false
-		}
+	matched, _ := matchFilter(item, filter, MatchOptions{})
+	return matched
+}
+
+// matchFilter evaluates filter against item without applying Reverse. The
+// boolean result follows the lenient semantics of Match even when an error
+// is reported.
+func matchFilter(item any, filter *Filter, opts MatchOptions) (bool, error) {
+	plan, err := filter.compiled()
+	if err != nil {
+		return false, err
 	}
-	if filter.err != nil {
-		return false
+	return plan.match(item, opts)
+}
+
+// resolveField reads the filter field from item, evaluating it as an
+// expression when it contains a {{...}} reference.
+func resolveField(item any, field string) (any, error) {
+	if field == SelfField {
+		return item, nil
 	}
-	var fieldValue any
-	var err error
-	if strings.Contains(filter.Field, "{{") {
-		fieldValue, err = resolveFilterField(item, filter.Field)
-		if err != nil {
-			return false
-		}
-	} else {
-		fieldValue, err = dipper.Get(item, filter.Field)
+	if ref, ok := reference(field); ok {
+		val, err := expr.Eval(ref, item)
 		if err != nil {
-			return false
+			return nil, fmt.Errorf("%w: %v", ErrExpressionFailed, err)
 		}
+		return val, nil
 	}
-	val, err := resolveFilterValue(item, filter.Value)
+	val, err := DefaultResolver.Resolve(item, field)
 	if err != nil {
-		return false
+		return nil, fmt.Errorf("%w: %v", ErrFieldNotFound, err)
 	}
-	var lookupData any
-	if filter.Lookup != nil {
-		if filter.Lookup.Data != nil {
-			lookupData = filter.Lookup.Data
-		} else if filter.Lookup.Handler != nil {
-			rs, err := filter.Lookup.Handler(item, filter.Lookup.HandlerCondition)
-			if err != nil {
-				return false
-			}
-			lookupData = rs
-		}
-		if filter.Lookup.Condition != "" {
-			lookupData = map[string]any{"data": item, "lookup": lookupData}
-			rs, err := expr.Eval(filter.Lookup.Condition, lookupData)
-			if err != nil {
-				return false
-			}
-			lookupData = rs
-		}
-	}
-	if lookupData != nil && utils.IsSlice(lookupData) {
-		lookupLength, err := utils.GetSliceLength(lookupData)
-		fieldLength, _ := utils.GetSliceLength(fieldValue)
-		if utils.IsSlice(fieldValue) && fieldLength == 0 {
-			return false
-		}
-		if err != nil {
-			return false
-		}
-		if lookupLength == 0 {
-			return false
-		}
+	return val, nil
+}
+
+// checkLookup rejects empty lookup results and empty slice fields when a lookup is in use.
+func checkLookup(fieldValue, lookupData any) bool {
+	if lookupData == nil || !utils.IsSlice(lookupData) {
+		return true
 	}
-	if !slices.Contains(countOperators, filter.Operator) && lookupData != nil {
-		val = lookupData
+	lookupLength, err := utils.GetSliceLength(lookupData)
+	fieldLength, _ := utils.GetSliceLength(fieldValue)
+	if utils.IsSlice(fieldValue) && fieldLength == 0 {
+		return false
 	}
-	switch filter.Operator {
-	case Equal:
-		return checkEq(fieldValue, val)
-	case NotEqual:
-		return checkNeq(fieldValue, val)
-	case GreaterThan:
-		return checkGt(fieldValue, val)
-	case LessThan:
-		return checkLt(fieldValue, val)
-	case GreaterThanEqual:
-		return checkGte(fieldValue, val)
-	case LessThanEqual:
-		return checkLte(fieldValue, val)
-	case Between:
-		return checkBetween(fieldValue, val)
-	case GreaterThanEqualCount:
-		return validateCount(">=", val, lookupData, fieldValue)
-	case GreaterThanCount:
-		return validateCount(">", val, lookupData, fieldValue)
-	case LesserThanEqualCount:
-		return validateCount("<=", val, lookupData, fieldValue)
-	case LesserThanCount:
-		return validateCount("<", val, lookupData, fieldValue)
-	case NotEqualCount:
-		return validateCount("!=", val, lookupData, fieldValue)
-	case EqualCount:
-		return validateCount("==", val, lookupData, fieldValue)
-	case Expression:
-		v, ok := filter.Value.(string)
-		if !ok {
-			return false
-		}
-		r, err := expr.Eval(v, item)
-		if err != nil || r == nil {
-			return false
-		}
-		return true
-	case Pattern:
-		v, ok := filter.Value.(string)
-		if !ok {
-			return false
-		}
-		re, err := regexp.Compile(v)
-		if err != nil {
-			return false
-		}
-		vt, ok := fieldValue.(string)
-		if !ok {
-			return false
-		}
-		return re.MatchString(vt)
-	case In:
-		return checkIn(fieldValue, val)
-	case NotIn:
-		return checkNotIn(fieldValue, val)
-	case Contains:
-		return checkContains(fieldValue, val)
-	case NotContains:
-		return checkNotContains(fieldValue, val)
-	case StartsWith:
-		return checkStartsWith(fieldValue, val)
-	case EndsWith:
-		return checkEndsWith(fieldValue, val)
-	case NotStartsWith:
-		return !checkStartsWith(fieldValue, val)
-	case NotEndsWith:
-		return !checkEndsWith(fieldValue, val)
-	case ContainsCS:
-		return checkContainsCS(fieldValue, val)
-	case NotContainsCS:
-		return checkNotContainsCS(fieldValue, val)
-	case StartsWithCS:
-		return checkStartsWithCS(fieldValue, val)
-	case NotStartsWithCS:
-		return checkNotStartsWithCS(fieldValue, val)
-	case EndsWithCS:
-		return checkEndsWithCS(fieldValue, val)
-	case NotEndsWithCS:
-		return checkNotEndsWithCS(fieldValue, val)
-	case IsZero:
-		if fieldValue == nil {
-			return false
-		}
-		return reflect.ValueOf(fieldValue).IsZero()
-	case NotZero:
-		if fieldValue == nil {
-			return true
-		}
-		return !reflect.ValueOf(fieldValue).IsZero()
-	case IsNull:
-		return fieldValue == nil
-	case NotNull:
-		return fieldValue != nil
+	if err != nil {
+		return false
 	}
-	return false
+	return lookupLength != 0
 }
 
-func resolveString(item any, v string) (any, error) {
+// reference returns the expression wrapped by {{...}} in v, if any.
+func reference(v string) (string, bool) {
 	if strings.HasPrefix(v, "{{") && strings.HasSuffix(v, "}}") {
-		referenceField := strings.TrimSpace(strings.TrimPrefix(strings.TrimSuffix(v, "}}"), "{{"))
-		return expr.Eval(referenceField, item)
+		return strings.TrimSpace(strings.TrimPrefix(strings.TrimSuffix(v, "}}"), "{{")), true
 	}
-	return v, nil
+	return "", false
 }
 
-func resolveFilterField(item, field any) (any, error) {
-	switch v := field.(type) {
-	case string:
-		return resolveString(item, v)
-	}
-	return field, nil
-}
-
-// Resolve filter value that may reference another field
-func resolveFilterValue(fieldVal, value any) (any, error) {
-	switch v := value.(type) {
-	case string:
-		return resolveString(fieldVal, v)
-	case []string:
-		var resolvedValues []any
-		for _, val := range v {
-			resolvedValue, err := resolveFilterValue(fieldVal, val)
-			if err != nil {
-				return nil, err
-			}
-			resolvedValues = append(resolvedValues, resolvedValue)
-		}
-		return resolvedValues, nil
-	case []any:
-		var resolvedValues []any
-		for _, t := range v {
-			switch t := t.(type) {
-			case string:
-				resolvedValue, err := resolveFilterValue(fieldVal, t)
-				if err != nil {
-					return nil, err
-				}
-				resolvedValues = append(resolvedValues, resolvedValue)
-			}
-		}
-		return resolvedValues, nil
-	default:
-		return value, nil
-	}
+func typeMismatch(data, value any) error {
+	return fmt.Errorf("%w: cannot compare %T with %T", ErrTypeMismatch, data, value)
 }
 
-func checkComparison(val, value any, isEqual bool) bool {
-	var comparisonResult bool
-	switch val := val.(type) {
-	case string:
-		data, err := convert.ToString(value)
-		if err != nil {
-			return false
+// checkComparison compares val with value under the rules of
+// utils.CompareValues, except that two strings are also equal when they
+// differ only in case.
+func checkComparison(val, value any, isEqual bool) (bool, error) {
+	comparisonResult := false
+	if s, ok := val.(string); ok {
+		if t, ok := value.(string); ok {
+			comparisonResult = strings.EqualFold(s, t)
 		}
-		comparisonResult = strings.EqualFold(val, data)
-	case bool:
-		data, err := convert.ToString(value)
-		if err != nil {
-			return false
-		}
-		comparisonResult = val == (strings.ToLower(data) == "true")
-	default:
-		data, err := convert.To(val, value)
+	}
+	if !comparisonResult {
+		equal, err := utils.EqualValues(val, value)
 		if err != nil {
-			return false
+			// values that cannot be compared are not equal
+			return !isEqual, typeMismatch(val, value)
 		}
-		comparisonResult = val == data
+		comparisonResult = equal
 	}
 	if isEqual {
-		return comparisonResult
+		return comparisonResult, nil
 	}
-	return !comparisonResult
+	return !comparisonResult, nil
 }
 
-func checkEq(val, value any) bool {
+func checkEq(val, value any) (bool, error) {
 	return checkComparison(val, value, true)
 }
 
-func checkNeq(val, value any) bool {
+func checkNeq(val, value any) (bool, error) {
 	return checkComparison(val, value, false)
 }
 
-func checkGt(data, value any) bool {
-	val, err := convert.Compare(data, value)
+func compare(data, value any, accept func(int) bool) (bool, error) {
+	val, err := utils.CompareValues(data, value)
 	if err != nil {
-		return false
+		return false, typeMismatch(data, value)
 	}
-	return val > 0
+	return accept(val), nil
 }
 
-func checkLt(data, value any) bool {
-	val, err := convert.Compare(data, value)
-	if err != nil {
-		return false
-	}
-	return val < 0
+func checkGt(data, value any) (bool, error) {
+	return compare(data, value, func(c int) bool { return c > 0 })
 }
 
-func checkGte(data, value any) bool {
-	val, err := convert.Compare(data, value)
-	if err != nil {
-		return false
-	}
-	return val >= 0
+func checkLt(data, value any) (bool, error) {
+	return compare(data, value, func(c int) bool { return c < 0 })
+}
+
+func checkGte(data, value any) (bool, error) {
+	return compare(data, value, func(c int) bool { return c >= 0 })
 }
 
-func checkLte(data, value any) bool {
-	val, err := convert.Compare(data, value)
+func checkLte(data, value any) (bool, error) {
+	return compare(data, value, func(c int) bool { return c <= 0 })
+}
+
+func checkBetween(data, value any) (bool, error) {
+	bounds := sqlValues(value)
+	if value == nil || len(bounds) != 2 {
+		return false, fmt.Errorf("%w: between expects two values, got %T", ErrTypeMismatch, value)
+	}
+	low, err := utils.CompareValues(data, bounds[0])
 	if err != nil {
-		return false
+		return false, typeMismatch(data, bounds[0])
+	}
+	high, err := utils.CompareValues(data, bounds[1])
+	if err != nil {
+		return false, typeMismatch(data, bounds[1])
 	}
-	return val <= 0
+	return low >= 0 && high <= 0, nil
 }
 
-func checkBetween(data, value any) bool {
-	switch values := value.(type) {
-	case []string:
-		return utils.Compare(data, values[0]) >= 0 && utils.Compare(data, values[1]) <= 0
-	case []any:
-		return utils.Compare(data, values[0]) >= 0 && utils.Compare(data, values[1]) <= 0
+// not negates the result of an operator. The lenient result is negated even
+// when an error is reported, so a negated string operator matches a field
+// that is not a string as it always has; MatchE still reports the error.
+func not(check operatorFunc) operatorFunc {
+	return func(data, value any) (bool, error) {
+		matched, err := check(data, value)
+		return !matched, err
 	}
-	return false
 }
 
 // Utility function to handle string-based operations
-func stringOperation(data, value any, op func(string, string) bool) bool {
+func stringOperation(data, value any, op func(string, string) bool) (bool, error) {
 	strData, ok1 := data.(string)
 	strValue, ok2 := value.(string)
 	if !ok1 || !ok2 {
-		return false
+		return false, typeMismatch(data, value)
 	}
-	return op(strings.ToLower(strData), strings.ToLower(strValue))
+	return op(strings.ToLower(strData), strings.ToLower(strValue)), nil
 }
 
-func checkIn(data, value any) bool {
-	isValueSlice, isDataSlice := false, false
-	if reflect.TypeOf(data).Kind() == reflect.Slice {
-		isDataSlice = true
-		data = utils.Flatten(data)
-	}
-	if reflect.TypeOf(value).Kind() == reflect.Slice {
-		isValueSlice = true
-		value = utils.Flatten(value)
-	}
-	sl, err := convert.To(data, value)
-	if err != nil {
-		return false
+// checkIn reports whether data, or any element of data when it is a slice,
+// equals one of the values.
+func checkIn(data, value any) (bool, error) {
+	if value == nil {
+		return false, typeMismatch(data, value)
 	}
-	if isValueSlice && isDataSlice {
-		return utils.ItemExists(sl, data)
+	values := utils.Flatten(value)
+	for _, item := range utils.Flatten(data) {
+		for _, v := range values {
+			if matched, err := checkComparison(item, v, true); err == nil && matched {
+				return true, nil
+			}
+		}
 	}
-	return utils.Contains(sl, data)
-}
-
-func checkNotIn(data, value any) bool {
-	return !checkIn(data, value)
+	return false, nil
 }
 
-func checkContains(data, value any) bool {
+func checkContains(data, value any) (bool, error) {
 	return stringOperation(data, value, strings.Contains)
 }
 
-func checkNotContains(data, value any) bool {
-	return !checkContains(data, value)
-}
-
-func checkStartsWith(data, value any) bool {
+func checkStartsWith(data, value any) (bool, error) {
 	return stringOperation(data, value, strings.HasPrefix)
 }
 
-func checkEndsWith(data, value any) bool {
+func checkEndsWith(data, value any) (bool, error) {
 	return stringOperation(data, value, strings.HasSuffix)
 }
 
 // Case-sensitive versions
-func stringOperationCS(data, value any, op func(string, string) bool) bool {
+func stringOperationCS(data, value any, op func(string, string) bool) (bool, error) {
 	strData, ok1 := data.(string)
 	strValue, ok2 := value.(string)
 	if !ok1 || !ok2 {
-		return false
+		return false, typeMismatch(data, value)
 	}
-	return op(strData, strValue)
+	return op(strData, strValue), nil
 }
 
-func checkContainsCS(data, value any) bool {
+func checkContainsCS(data, value any) (bool, error) {
 	return stringOperationCS(data, value, strings.Contains)
 }
 
-func checkNotContainsCS(data, value any) bool {
-	return !checkContainsCS(data, value)
+func checkStartsWithCS(data, value any) (bool, error) {
+	return stringOperationCS(data, value, strings.HasPrefix)
 }
 
-func checkStartsWithCS(data, value any) bool {
-	return stringOperationCS(data, value, strings.HasPrefix)
+func checkEndsWithCS(data, value any) (bool, error) {
+	return stringOperationCS(data, value, strings.HasSuffix)
 }
 
-func checkNotStartsWithCS(data, value any) bool {
-	return !checkStartsWithCS(data, value)
+func checkIsZero(data, _ any) (bool, error) {
+	if data == nil {
+		return false, nil
+	}
+	return reflect.ValueOf(data).IsZero(), nil
 }
 
-func checkEndsWithCS(data, value any) bool {
-	return stringOperationCS(data, value, strings.HasSuffix)
+func checkNotZero(data, _ any) (bool, error) {
+	if data == nil {
+		return true, nil
+	}
+	return !reflect.ValueOf(data).IsZero(), nil
+}
+
+func checkIsNull(data, _ any) (bool, error) {
+	return data == nil, nil
+}
+
+func checkNotNull(data, _ any) (bool, error) {
+	return data != nil, nil
 }
 
-func checkNotEndsWithCS(data, value any) bool {
-	return !checkEndsWithCS(data, value)
+// checkExists only runs for fields that resolved, so it always matches.
+func checkExists(_, _ any) (bool, error) {
+	return true, nil
 }
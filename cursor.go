@@ -0,0 +1,151 @@
+package filters
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrInvalidCursor is returned for a cursor that is malformed or was issued
+// for a different sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursorToken is the content of an encoded cursor: the sort it was issued
+// for and the sort values of the last record of the page.
+type cursorToken struct {
+	Sort   []SortKey `json:"sort"`
+	Values []any     `json:"values"`
+}
+
+// EncodeCursor returns an opaque cursor pointing after item in a result
+// sorted by keys. For keyset pagination to be stable the keys must order
+// records uniquely, typically by ending with an id.
+func EncodeCursor(item any, keys ...SortKey) (string, error) {
+	if len(keys) == 0 {
+		return "", errors.New("cursor requires at least one sort key")
+	}
+	token := cursorToken{Sort: keys, Values: make([]any, len(keys))}
+	for i, key := range keys {
+		// a missing field is encoded like nil, the way it sorts
+		token.Values[i], _ = resolveField(item, key.Field)
+	}
+	data, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor returns the sort values stored in cursor, checking that it
+// was issued for keys.
+func DecodeCursor(cursor string, keys ...SortKey) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var token cursorToken
+	if err := decoder.Decode(&token); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if !slices.Equal(token.Sort, keys) || len(token.Values) != len(keys) {
+		return nil, fmt.Errorf("%w: issued for a different sort", ErrInvalidCursor)
+	}
+	for i, value := range token.Values {
+		token.Values[i] = normalizeValue(value)
+	}
+	return token.Values, nil
+}
+
+// KeysetFilter returns the condition selecting the records that come after
+// values in the order given by keys, as in (a > x) OR (a = x AND b > y).
+// The group can be matched in memory or translated with ToSQL and the other
+// translators to push the pagination down to a database.
+func KeysetFilter(keys []SortKey, values []any) (*FilterGroup, error) {
+	if len(keys) != len(values) {
+		return nil, fmt.Errorf("keyset has %d sort keys but %d values", len(keys), len(values))
+	}
+	keyset := NewFilterGroup(OR, false)
+	for i, key := range keys {
+		after := keysetAfter(key, values[i])
+		if after == nil {
+			continue
+		}
+		branch := NewFilterGroup(AND, false)
+		for j := range i {
+			branch.Filters = append(branch.Filters, keysetEqual(keys[j], values[j]))
+		}
+		branch.Filters = append(branch.Filters, after)
+		keyset.Filters = append(keyset.Filters, branch)
+	}
+	return keyset, nil
+}
+
+// nullsAfter reports whether nil values sort after all others for key.
+func nullsAfter(key SortKey) bool {
+	return key.Nulls == NullsLast || (key.Nulls == "" && !key.Desc)
+}
+
+// isNil matches nil and, unlike IsNull, missing fields, which sort as nil.
+func isNil(field string) Condition {
+	filter := NewFilter(field, NotNull, nil)
+	filter.Reverse = true
+	return filter
+}
+
+// keysetEqual returns the condition for records tied with value on key. It
+// is a Between on the one value rather than an Equal, which folds case, so
+// that ties are decided by the same comparison SortBy orders with.
+func keysetEqual(key SortKey, value any) Condition {
+	if value == nil {
+		return isNil(key.Field)
+	}
+	return NewFilter(key.Field, Between, []any{value, value})
+}
+
+// keysetAfter returns the condition for records strictly after value on
+// key alone, or nil when none can be.
+func keysetAfter(key SortKey, value any) Condition {
+	if value == nil {
+		if nullsAfter(key) {
+			return nil
+		}
+		return NewFilter(key.Field, NotNull, nil)
+	}
+	operator := GreaterThan
+	if key.Desc {
+		operator = LessThan
+	}
+	after := NewFilter(key.Field, operator, value)
+	if nullsAfter(key) {
+		return NewFilterGroup(OR, false, after, isNil(key.Field))
+	}
+	return after
+}
+
+// CursorFilter returns the keyset condition for the query's cursor, or nil
+// when it has none.
+func (query Query) CursorFilter() (*FilterGroup, error) {
+	if query.Cursor == "" {
+		return nil, nil
+	}
+	values, err := DecodeCursor(query.Cursor, query.Sort...)
+	if err != nil {
+		return nil, err
+	}
+	return KeysetFilter(query.Sort, values)
+}
+
+// NextCursor returns the cursor for the page following page, which was
+// returned for query, or "" when page is empty. The records must still hold
+// the sort fields, so take it before projecting them away.
+func NextCursor[T any](page []T, query Query) (string, error) {
+	if len(page) == 0 {
+		return "", nil
+	}
+	return EncodeCursor(page[len(page)-1], query.Sort...)
+}
@@ -0,0 +1,116 @@
+package filters_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/oarkflow/filters"
+)
+
+func TestCursorPagination(t *testing.T) {
+	records := []map[string]any{
+		{"id": 1, "team": "red", "score": 7.5},
+		{"id": 2, "team": "blue", "score": 3.0},
+		{"id": 3, "team": nil, "score": 7.5},
+		{"id": 4, "team": "red", "score": 1.25},
+		{"id": 5, "team": "blue", "score": 9.0},
+		{"id": 6, "score": 3.0},
+		{"id": 7, "team": "green", "score": 7.5},
+	}
+	for _, sort := range []string{"score,id", "-score,id", "team,-id", "-team,id", "team:nullsfirst,score,id", "-team:nullslast,-score,-id"} {
+		query, err := filters.ParseListQuery("sort=" + sort)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []any
+		for _, record := range filters.ApplyQuery(records, query) {
+			want = append(want, record["id"])
+		}
+		query.Limit = 2
+		var got []any
+		for range records {
+			page := filters.ApplyQuery(records, query)
+			if len(page) == 0 {
+				break
+			}
+			for _, record := range page {
+				got = append(got, record["id"])
+			}
+			if query.Cursor, err = filters.NextCursor(page, query); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: paged %v, want %v", sort, got, want)
+		}
+	}
+}
+
+func TestCursorPaginationMixedCase(t *testing.T) {
+	records := []map[string]any{
+		{"id": 1, "n": "b"},
+		{"id": 2, "n": "B"},
+		{"id": 3, "n": "a"},
+		{"id": 4, "n": "A"},
+		{"id": 5, "n": "c"},
+	}
+	for _, sort := range []string{"n,id", "-n,id"} {
+		query, err := filters.ParseListQuery("sort=" + sort)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []any
+		for _, record := range filters.ApplyQuery(records, query) {
+			want = append(want, record["id"])
+		}
+		query.Limit = 2
+		var got []any
+		for range records {
+			page := filters.ApplyQuery(records, query)
+			if len(page) == 0 {
+				break
+			}
+			for _, record := range page {
+				got = append(got, record["id"])
+			}
+			if query.Cursor, err = filters.NextCursor(page, query); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: paged %v, want %v", sort, got, want)
+		}
+	}
+}
+
+func TestKeysetFilterToSQL(t *testing.T) {
+	keys := []filters.SortKey{{Field: "created_at", Desc: true}, {Field: "id"}}
+	keyset, err := filters.KeysetFilter(keys, []any{"2024-01-31", 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clause, args, err := filters.ToSQL(keyset, filters.Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantClause := `("created_at" < $1 OR ("created_at" BETWEEN $2 AND $3 AND ("id" > $4 OR NOT ("id" IS NOT NULL))))`
+	if clause != wantClause || !reflect.DeepEqual(args, []any{"2024-01-31", "2024-01-31", "2024-01-31", 42}) {
+		t.Errorf("got %s %v, want %s", clause, args, wantClause)
+	}
+}
+
+func TestDecodeCursorRejectsOtherSort(t *testing.T) {
+	cursor, err := filters.EncodeCursor(map[string]any{"id": 3}, filters.SortKey{Field: "id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values, err := filters.DecodeCursor(cursor, filters.SortKey{Field: "id"}); err != nil || !reflect.DeepEqual(values, []any{3}) {
+		t.Errorf("got %v, %v", values, err)
+	}
+	for _, bad := range []string{"sort=-id&cursor=" + cursor, "sort=id&cursor=%21%21"} {
+		if _, err := filters.ParseListQuery(bad); !errors.Is(err, filters.ErrInvalidCursor) {
+			t.Errorf("%s: got %v, want ErrInvalidCursor", bad, err)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package filters
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oarkflow/filters/utils"
+)
+
+// dateOperatorFunc compares a resolved field value against a resolved filter
+// value at the time and in the zone of clock.
+type dateOperatorFunc func(data, value any, c clock) (bool, error)
+
+var dateOperatorFuncs = map[Operator]dateOperatorFunc{
+	Before:      checkBefore,
+	After:       checkAfter,
+	OnDate:      checkOnDate,
+	WithinLast:  checkWithinLast,
+	WithinNext:  checkWithinNext,
+	AgeBetween:  checkAgeBetween,
+	DayOfWeekIn: checkDayOfWeekIn,
+}
+
+// clock is the current time and the default zone of an evaluation.
+type clock struct {
+	now time.Time
+	loc *time.Location
+}
+
+func (o MatchOptions) clock() clock {
+	loc := o.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	now := time.Now
+	if o.Now != nil {
+		now = o.Now
+	}
+	return clock{now: now().In(loc), loc: loc}
+}
+
+// TranslateOptions gives the translators, such as ToSQL and ToMongo, the
+// clock to resolve now() and today() with. Without it they report relative
+// times as untranslatable rather than writing them out as strings.
+type TranslateOptions struct {
+	// Location is the zone of today(). It defaults to UTC.
+	Location *time.Location
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// translateClock returns the clock of opts, or nil when none were given.
+func translateClock(opts []TranslateOptions) *clock {
+	if len(opts) == 0 {
+		return nil
+	}
+	c := MatchOptions{Location: opts[0].Location, Now: opts[0].Now}.clock()
+	return &c
+}
+
+// translate returns filter with the relative times in its value replaced by
+// the times they stand for, reporting false when it has some but there is no
+// clock to resolve them with.
+func (c *clock) translate(filter *Filter) (*Filter, bool) {
+	if _, ok := filter.Value.(Condition); ok || !slices.ContainsFunc(sqlValues(filter.Value), isRelative) {
+		return filter, true
+	}
+	if c == nil {
+		return filter, false
+	}
+	resolved := *filter
+	_, resolved.Value = c.operands(nil, filter.Value)
+	return &resolved, true
+}
+
+// untranslatableRelative is the reason given for relative times translated
+// without TranslateOptions.
+const untranslatableRelative = "relative times need TranslateOptions to be translated"
+
+var relativeTime = regexp.MustCompile(`(?i)^\s*(now|today)\(\)\s*(?:([+-])\s*(.+?))?\s*$`)
+
+// isRelative reports whether v is now() or today(), optionally followed by
+// + or - and a duration, as in "today() - 7d".
+func isRelative(v any) bool {
+	s, ok := v.(string)
+	return ok && relativeTime.MatchString(s)
+}
+
+// relative resolves s when it is a relative time, reporting whether it was.
+func (c clock) relative(s string) (time.Time, bool, error) {
+	m := relativeTime.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, false, nil
+	}
+	t := c.now
+	if strings.EqualFold(m[1], "today") {
+		year, month, day := t.Date()
+		t = time.Date(year, month, day, 0, 0, 0, 0, c.loc)
+	}
+	if m[2] == "" {
+		return t, true, nil
+	}
+	d, err := utils.ParseDuration(m[3])
+	if err != nil {
+		return time.Time{}, true, err
+	}
+	if m[2] == "-" {
+		d = -d
+	}
+	return shift(t, d), true, nil
+}
+
+// shift adds d to t, moving whole days by calendar days so that midnight
+// stays midnight across daylight saving changes.
+func shift(t time.Time, d time.Duration) time.Time {
+	const day = 24 * time.Hour
+	if d%day == 0 {
+		return t.AddDate(0, 0, int(d/day))
+	}
+	return t.Add(d)
+}
+
+// time reads v as a point in time, resolving relative times and reading
+// strings without a zone in the clock's location.
+func (c clock) time(v any) (time.Time, bool) {
+	if s, ok := v.(string); ok {
+		if t, ok, err := c.relative(s); ok {
+			return t, err == nil
+		}
+	}
+	t, err := utils.ParseTimeIn(v, c.loc)
+	return t, err == nil
+}
+
+// times reads both the field value and the value as points in time.
+func (c clock) times(data, value any) (time.Time, time.Time, error) {
+	t, ok := c.time(data)
+	v, vok := c.time(value)
+	if !ok || !vok {
+		return t, v, typeMismatch(data, value)
+	}
+	return t, v, nil
+}
+
+// operands replaces relative times in value, or in each of its values, by
+// the times they stand for, and reads a string field value as a time in the
+// clock's location so that the comparison operators can use them.
+func (c clock) operands(fieldValue, value any) (any, any) {
+	kind := reflect.ValueOf(value).Kind()
+	if kind == reflect.Slice || kind == reflect.Array {
+		values := sqlValues(value)
+		for i, v := range values {
+			values[i] = c.resolve(v)
+		}
+		value = values
+	} else {
+		value = c.resolve(value)
+	}
+	if s, ok := fieldValue.(string); ok && utils.IsValidDateTime(s) {
+		if t, err := utils.ParseTimeIn(s, c.loc); err == nil {
+			fieldValue = t
+		}
+	}
+	return fieldValue, value
+}
+
+func (c clock) resolve(v any) any {
+	if s, ok := v.(string); ok {
+		if t, ok, err := c.relative(s); ok && err == nil {
+			return t
+		}
+	}
+	return v
+}
+
+func checkBefore(data, value any, c clock) (bool, error) {
+	t, v, err := c.times(data, value)
+	return err == nil && t.Before(v), err
+}
+
+func checkAfter(data, value any, c clock) (bool, error) {
+	t, v, err := c.times(data, value)
+	return err == nil && t.After(v), err
+}
+
+func checkOnDate(data, value any, c clock) (bool, error) {
+	t, v, err := c.times(data, value)
+	if err != nil {
+		return false, err
+	}
+	ty, tm, td := t.In(c.loc).Date()
+	vy, vm, vd := v.In(c.loc).Date()
+	return ty == vy && tm == vm && td == vd, nil
+}
+
+func checkWithinLast(data, value any, c clock) (bool, error) {
+	t, d, err := c.span(data, value)
+	return err == nil && !t.Before(shift(c.now, -d)) && !t.After(c.now), err
+}
+
+func checkWithinNext(data, value any, c clock) (bool, error) {
+	t, d, err := c.span(data, value)
+	return err == nil && !t.Before(c.now) && !t.After(shift(c.now, d)), err
+}
+
+// span reads the field value as a time and the value as a duration.
+func (c clock) span(data, value any) (time.Time, time.Duration, error) {
+	t, ok := c.time(data)
+	d, dok := duration(value)
+	if !ok || !dok {
+		return t, d, typeMismatch(data, value)
+	}
+	return t, d, nil
+}
+
+func duration(v any) (time.Duration, bool) {
+	switch d := v.(type) {
+	case time.Duration:
+		return d, true
+	case string:
+		parsed, err := utils.ParseDuration(d)
+		return parsed, err == nil
+	}
+	return 0, false
+}
+
+func checkAgeBetween(data, value any, c clock) (bool, error) {
+	t, ok := c.time(data)
+	bounds := sqlValues(value)
+	if !ok || len(bounds) != 2 {
+		return false, typeMismatch(data, value)
+	}
+	years := age(t.In(c.loc), c.now)
+	from, err := utils.CompareValues(years, bounds[0])
+	if err != nil {
+		return false, typeMismatch(data, value)
+	}
+	to, err := utils.CompareValues(years, bounds[1])
+	if err != nil {
+		return false, typeMismatch(data, value)
+	}
+	return from >= 0 && to <= 0, nil
+}
+
+// age returns the number of whole years from birth to now.
+func age(birth, now time.Time) int {
+	years := now.Year() - birth.Year()
+	if now.Month() < birth.Month() || (now.Month() == birth.Month() && now.Day() < birth.Day()) {
+		years--
+	}
+	return years
+}
+
+func checkDayOfWeekIn(data, value any, c clock) (bool, error) {
+	t, ok := c.time(data)
+	if !ok {
+		return false, typeMismatch(data, value)
+	}
+	day := t.In(c.loc).Weekday()
+	for _, v := range sqlValues(value) {
+		d, ok := weekday(v)
+		if !ok {
+			return false, typeMismatch(data, value)
+		}
+		if d == day {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// weekday reads a day of the week given as a time.Weekday, a number from 0
+// for Sunday to 6, or an English name or its first three letters.
+func weekday(v any) (time.Weekday, bool) {
+	if s, ok := v.(string); ok {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if n, err := strconv.Atoi(s); err == nil {
+			return time.Weekday(n), n >= 0 && n <= 6
+		}
+		d, ok := weekdays[s]
+		if !ok && len(s) > 3 {
+			d, ok = weekdays[s[:3]]
+			ok = ok && strings.EqualFold(d.String(), s)
+		}
+		return d, ok
+	}
+	rv := reflect.ValueOf(v)
+	if rv.CanInt() {
+		n := rv.Int()
+		return time.Weekday(n), n >= 0 && n <= 6
+	}
+	if rv.CanUint() {
+		n := rv.Uint()
+		return time.Weekday(n), n <= 6
+	}
+	return 0, false
+}
+
+// validateDateFilter checks the values of the date operators and any
+// relative times, leaving references to evaluation time.
+func validateDateFilter(filter *Filter) error {
+	if _, ok := filter.Value.(Condition); ok {
+		return nil
+	}
+	values := sqlValues(filter.Value)
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			if _, isRef := reference(s); isRef {
+				continue
+			}
+			if _, isRelative, err := (clock{loc: time.UTC}).relative(s); isRelative && err != nil {
+				return fmt.Errorf("invalid relative time %q: %v", s, err)
+			}
+		}
+		switch filter.Operator {
+		case WithinLast, WithinNext:
+			if _, ok := duration(v); !ok {
+				return fmt.Errorf("%s filter must have a duration as value", filter.Operator)
+			}
+		case DayOfWeekIn:
+			if _, ok := weekday(v); !ok {
+				return fmt.Errorf("day_of_week_in filter must have days of the week as value, got %v", v)
+			}
+		}
+	}
+	if filter.Operator == AgeBetween && (reflect.ValueOf(filter.Value).Kind() != reflect.Slice || len(values) != 2) {
+		return errors.New("age_between filter must have a slice of two elements as value")
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package filters_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oarkflow/filters"
+	"github.com/oarkflow/filters/utils"
+)
+
+// a Friday
+var clockNow = time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+
+func fixedClock() time.Time { return clockNow }
+
+func TestDateOperators(t *testing.T) {
+	eastern := time.FixedZone("EST", -5*60*60)
+	data := map[string]any{
+		"created":  "2024-03-10 08:00:00",
+		"due":      clockNow.Add(36 * time.Hour),
+		"stamp":    time.Date(2024, 3, 15, 2, 0, 0, 0, time.UTC),
+		"local":    "2024-03-15 02:00:00",
+		"birth":    "2000-03-16",
+		"birthday": "2000-03-15",
+	}
+	tests := []struct {
+		filter *filters.Filter
+		loc    *time.Location
+		want   bool
+	}{
+		{filters.NewFilter("created", filters.Before, "2024-03-11"), nil, true},
+		{filters.NewFilter("created", filters.After, "today() - 7d"), nil, true},
+		{filters.NewFilter("created", filters.After, "today() - 2d"), nil, false},
+		{filters.NewFilter("due", filters.After, "now()"), nil, true},
+		{filters.NewFilter("stamp", filters.OnDate, "today()"), nil, true},
+		{filters.NewFilter("stamp", filters.OnDate, "today()"), eastern, false},
+		{filters.NewFilter("local", filters.OnDate, "2024-03-15"), eastern, true},
+		{filters.NewFilter("stamp", filters.OnDate, "2024-03-14"), eastern, true},
+		{filters.NewFilter("created", filters.WithinLast, "30d"), nil, true},
+		{filters.NewFilter("created", filters.WithinLast, "2 days"), nil, false},
+		{filters.NewFilter("due", filters.WithinNext, 48*time.Hour), nil, true},
+		{filters.NewFilter("due", filters.WithinNext, "1d"), nil, false},
+		{filters.NewFilter("due", filters.WithinLast, "1w"), nil, false},
+		{filters.NewFilter("birth", filters.AgeBetween, []int{18, 23}), nil, true},
+		{filters.NewFilter("birth", filters.AgeBetween, []int{24, 30}), nil, false},
+		{filters.NewFilter("birthday", filters.AgeBetween, []string{"24", "30"}), nil, true},
+		{filters.NewFilter("created", filters.DayOfWeekIn, []string{"sat", "Sunday"}), nil, true},
+		{filters.NewFilter("stamp", filters.DayOfWeekIn, []any{5}), nil, true},
+		{filters.NewFilter("stamp", filters.DayOfWeekIn, []any{5}), eastern, false},
+		{filters.NewFilter("created", filters.GreaterThanEqual, "today()-5d"), nil, true},
+		{filters.NewFilter("created", filters.Between, []string{"today() - 7d", "now()"}), nil, true},
+		{filters.NewFilter("local", filters.LessThan, "today()"), eastern, false},
+	}
+	for _, test := range tests {
+		opts := filters.MatchOptions{Now: fixedClock, Location: test.loc}
+		if got, err := test.filter.MatchE(data, opts); err != nil || got != test.want {
+			t.Errorf("%s %s %v in %v: got %v, %v, want %v", test.filter.Field, test.filter.Operator, test.filter.Value, test.loc, got, err, test.want)
+		}
+	}
+}
+
+func TestDateOperatorErrors(t *testing.T) {
+	for _, filter := range []*filters.Filter{
+		filters.NewFilter("created", filters.WithinLast, "soon"),
+		filters.NewFilter("created", filters.AgeBetween, 18),
+		filters.NewFilter("created", filters.DayOfWeekIn, []string{"someday"}),
+		filters.NewFilter("created", filters.After, "now() - forever"),
+	} {
+		if err := filter.Validate(); err == nil {
+			t.Errorf("%s %v: expected a validation error", filter.Operator, filter.Value)
+		}
+	}
+	_, err := filters.NewFilter("name", filters.Before, "now()").MatchE(map[string]any{"name": "bob"})
+	if !errors.Is(err, filters.ErrTypeMismatch) {
+		t.Errorf("got %v, want ErrTypeMismatch", err)
+	}
+}
+
+func TestDateOperatorsFromParsers(t *testing.T) {
+	data := map[string]any{"created": "2024-03-10 08:00:00", "birth": "2000-03-16"}
+	opts := filters.MatchOptions{Now: fixedClock}
+	list, err := filters.ParseQuery("created=within_last:30d&birth=age_between:18,30&created:day_of_week_in:sun,sat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filter := range list {
+		if got, err := filter.MatchE(data, opts); err != nil || !got {
+			t.Errorf("query %s %s %v: got %v, %v", filter.Field, filter.Operator, filter.Value, got, err)
+		}
+	}
+	for _, sql := range []string{
+		"created > NOW() - INTERVAL '30 days'",
+		"created > now() - 7d AND created < now()-1h30m",
+		"created AFTER today() - '1w' AND created BEFORE now()",
+		"created WITHIN_LAST INTERVAL '7 days' AND birth AGE_BETWEEN 18 AND 30",
+		"created DAY_OF_WEEK_IN ('sat', 'sun') AND NOT created ON_DATE TODAY()",
+	} {
+		rule, err := filters.ParseSQL(sql)
+		if err != nil {
+			t.Errorf("%s: %v", sql, err)
+			continue
+		}
+		if got, err := rule.MatchE(data, opts); err != nil || !got {
+			t.Errorf("%s: got %v, %v", sql, got, err)
+		}
+	}
+	for _, sql := range []string{"created > now() + INTERVAL 'soon'", "created > now() - 7x"} {
+		if _, err := filters.ParseSQL(sql); err == nil {
+			t.Errorf("%s: expected an invalid offset to fail", sql)
+		}
+	}
+}
+
+func TestTranslateRelativeTimes(t *testing.T) {
+	filter := filters.NewFilter("created", filters.GreaterThan, "today() - 7d")
+	week := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+	translators := map[string]func(...filters.TranslateOptions) (any, error){
+		"sql": func(opts ...filters.TranslateOptions) (any, error) {
+			_, args, err := filters.ToSQL(filter, filters.Postgres, opts...)
+			return args, err
+		},
+		"mongo": func(opts ...filters.TranslateOptions) (any, error) {
+			return filters.ToMongo(filter, opts...)
+		},
+		"elasticsearch": func(opts ...filters.TranslateOptions) (any, error) {
+			return filters.ToElasticQuery(filter, opts...)
+		},
+		"jsonlogic": func(opts ...filters.TranslateOptions) (any, error) {
+			return filters.ToJSONLogic(filter, opts...)
+		},
+		"rsql": func(opts ...filters.TranslateOptions) (any, error) {
+			return filters.ToRSQL(filter, opts...)
+		},
+	}
+	want := map[string]any{
+		"sql":           []any{week},
+		"mongo":         map[string]any{"created": map[string]any{"$gt": week}},
+		"elasticsearch": map[string]any{"range": map[string]any{"created": map[string]any{"gt": week}}},
+		"jsonlogic":     map[string]any{">": []any{map[string]any{"var": "created"}, "2024-03-08T00:00:00Z"}},
+		"rsql":          "created=gt=2024-03-08T00:00:00Z",
+	}
+	for name, translate := range translators {
+		var translateErr *filters.TranslateError
+		if _, err := translate(); !errors.As(err, &translateErr) {
+			t.Errorf("%s: got %v, want a TranslateError without a clock", name, err)
+		}
+		got, err := translate(filters.TranslateOptions{Now: fixedClock})
+		if err != nil || !reflect.DeepEqual(got, want[name]) {
+			t.Errorf("%s: got %#v, %v, want %#v", name, got, err, want[name])
+		}
+	}
+}
+
+func TestSchemaDateOperators(t *testing.T) {
+	schema := filters.Schema{"created": filters.TimeField}
+	list, err := schema.ParseQuery("created=within_last:2d&created:after:today()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filter := range list {
+		if filter.Operator == filters.WithinLast && filter.Value != 48*time.Hour {
+			t.Errorf("got %#v, want a duration", filter.Value)
+		}
+		if filter.Operator == filters.After && filter.Value != "today()" {
+			t.Errorf("got %#v, want the relative time", filter.Value)
+		}
+	}
+	if _, err := schema.ParseQuery("created=before:tomorrowish"); !errors.Is(err, filters.ErrTypeMismatch) {
+		t.Errorf("got %v, want ErrTypeMismatch", err)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"2h":        2 * time.Hour,
+		"1h30m":     90 * time.Minute,
+		"30d":       30 * 24 * time.Hour,
+		"1w2d":      9 * 24 * time.Hour,
+		"1d 12h":    36 * time.Hour,
+		"7 days":    7 * 24 * time.Hour,
+		"-1.5 days": -36 * time.Hour,
+	}
+	for text, want := range tests {
+		if got, err := utils.ParseDuration(text); err != nil || got != want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v", text, got, err, want)
+		}
+	}
+	for _, text := range []string{"", "d", "3 fortnights", "1.2.3h"} {
+		if _, err := utils.ParseDuration(text); err == nil {
+			t.Errorf("ParseDuration(%q): expected an error", text)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package filters
+
+import (
+	"math/big"
+	"reflect"
+
+	"github.com/oarkflow/filters/utils"
+)
+
+// RoundingMode selects how DecimalOptions rounds a value to its scale.
+type RoundingMode int
+
+const (
+	// RoundHalfEven rounds ties to the even neighbour, as banks do.
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp rounds ties away from zero.
+	RoundHalfUp
+	// RoundHalfDown rounds ties toward zero.
+	RoundHalfDown
+	// RoundDown truncates toward zero.
+	RoundDown
+	// RoundUp rounds away from zero.
+	RoundUp
+	// RoundFloor rounds toward negative infinity.
+	RoundFloor
+	// RoundCeiling rounds toward positive infinity.
+	RoundCeiling
+)
+
+// DecimalOptions rounds decimal comparisons, such as of money amounts, to
+// Scale digits after the point. When a field or value of eq, ne, gt, ge,
+// lt, le, between, in or nin is a decimal (see utils.IsDecimal), both sides
+// are rounded before they are compared, floats through their shortest
+// decimal form.
+type DecimalOptions struct {
+	Scale    int
+	Rounding RoundingMode
+}
+
+var decimalOperators = map[Operator]bool{
+	Equal: true, NotEqual: true, GreaterThan: true, GreaterThanEqual: true, LessThan: true,
+	LessThanEqual: true, Between: true, In: true, NotIn: true,
+}
+
+// Round rounds r to the scale of the options.
+func (d DecimalOptions) Round(r *big.Rat) *big.Rat {
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(max(d.Scale, 0))), nil)
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(unit))
+	quotient, remainder := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if remainder.Sign() != 0 {
+		// compare the discarded fraction with one half
+		half := new(big.Int).Lsh(new(big.Int).Abs(remainder), 1).Cmp(scaled.Denom())
+		negative := scaled.Sign() < 0
+		var away bool
+		switch d.Rounding {
+		case RoundHalfEven:
+			away = half > 0 || (half == 0 && quotient.Bit(0) == 1)
+		case RoundHalfUp:
+			away = half >= 0
+		case RoundHalfDown:
+			away = half > 0
+		case RoundUp:
+			away = true
+		case RoundFloor:
+			away = negative
+		case RoundCeiling:
+			away = !negative
+		}
+		if away && negative {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else if away {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return new(big.Rat).SetFrac(quotient, unit)
+}
+
+// operands rounds the field value and the value, or each of the values,
+// when any of them is a decimal.
+func (d DecimalOptions) operands(fieldValue, value any) (any, any) {
+	kind := reflect.ValueOf(value).Kind()
+	list := kind == reflect.Slice || kind == reflect.Array
+	values := []any{value}
+	if list {
+		values = sqlValues(value)
+	}
+	decimal := utils.IsDecimal(fieldValue)
+	for _, v := range values {
+		decimal = decimal || utils.IsDecimal(v)
+	}
+	if !decimal {
+		return fieldValue, value
+	}
+	for i, v := range values {
+		values[i] = d.round(v)
+	}
+	if list {
+		return d.round(fieldValue), values
+	}
+	return d.round(fieldValue), values[0]
+}
+
+// round rounds v when it is a number and leaves other values alone.
+func (d DecimalOptions) round(v any) any {
+	if _, isText := v.(string); isText && !utils.IsDecimal(v) {
+		return v
+	}
+	if r, ok := utils.ToRat(v); ok {
+		return d.Round(r)
+	}
+	return v
+}
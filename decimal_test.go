@@ -0,0 +1,103 @@
+package filters_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/oarkflow/filters"
+	"github.com/oarkflow/filters/utils"
+)
+
+func TestDecimalRound(t *testing.T) {
+	modes := []filters.RoundingMode{
+		filters.RoundHalfEven, filters.RoundHalfUp, filters.RoundHalfDown,
+		filters.RoundDown, filters.RoundUp, filters.RoundFloor, filters.RoundCeiling,
+	}
+	tests := []struct {
+		value string
+		want  []string // in the order of modes
+	}{
+		{"2.345", []string{"2.34", "2.35", "2.34", "2.34", "2.35", "2.34", "2.35"}},
+		{"2.355", []string{"2.36", "2.36", "2.35", "2.35", "2.36", "2.35", "2.36"}},
+		{"-2.345", []string{"-2.34", "-2.35", "-2.34", "-2.34", "-2.35", "-2.35", "-2.34"}},
+		{"2.3451", []string{"2.35", "2.35", "2.35", "2.34", "2.35", "2.34", "2.35"}},
+		{"2.34", []string{"2.34", "2.34", "2.34", "2.34", "2.34", "2.34", "2.34"}},
+	}
+	for _, test := range tests {
+		r, _ := new(big.Rat).SetString(test.value)
+		for i, mode := range modes {
+			got := filters.DecimalOptions{Scale: 2, Rounding: mode}.Round(r).FloatString(2)
+			if got != test.want[i] {
+				t.Errorf("%s with mode %d: got %s, want %s", test.value, mode, got, test.want[i])
+			}
+		}
+	}
+}
+
+func TestDecimalComparisons(t *testing.T) {
+	sum := 0.1
+	sum += 0.2 // 0.30000000000000004
+	data := map[string]any{"total": sum, "exact": json.Number("0.30000000000000001"), "price": 0.3}
+	cents := &filters.DecimalOptions{Scale: 2}
+	tests := []struct {
+		filter  *filters.Filter
+		decimal *filters.DecimalOptions
+		want    bool
+	}{
+		{filters.NewFilter("total", filters.Between, []string{"0.1", "0.3"}), nil, false},
+		{filters.NewFilter("total", filters.Between, []string{"0.1", "0.3"}), cents, true},
+		{filters.NewFilter("total", filters.Equal, json.Number("0.30")), cents, true},
+		{filters.NewFilter("total", filters.In, []any{json.Number("0.30"), json.Number("0.40")}), cents, true},
+		{filters.NewFilter("exact", filters.GreaterThan, json.Number("0.3")), nil, true},
+		{filters.NewFilter("exact", filters.Equal, "0.3"), cents, true},
+		{filters.NewFilter("price", filters.Equal, json.Number("0.3")), nil, true},
+		{filters.NewFilter("price", filters.LessThan, 0.31), cents, true},
+	}
+	for _, test := range tests {
+		got, err := test.filter.MatchE(data, filters.MatchOptions{Decimal: test.decimal})
+		if err != nil || got != test.want {
+			t.Errorf("%s %s %v (options %v): got %v, %v, want %v", test.filter.Field, test.filter.Operator, test.filter.Value, test.decimal, got, err, test.want)
+		}
+	}
+}
+
+type cents int64
+
+func TestRegisterDecimal(t *testing.T) {
+	utils.RegisterDecimal(cents(0), func(v any) (*big.Rat, error) {
+		return big.NewRat(int64(v.(cents)), 100), nil
+	})
+	data := map[string]any{"amount": cents(1050)}
+	if !filters.NewFilter("amount", filters.Equal, "10.50").Match(data) {
+		t.Errorf("registered decimal should equal its decimal value")
+	}
+	if !filters.NewFilter("amount", filters.Between, []any{json.Number("10.49"), 10.5}).Match(data) {
+		t.Errorf("registered decimal should compare with json.Number and floats")
+	}
+}
+
+func TestParsersKeepDecimals(t *testing.T) {
+	rule, err := filters.ParseSQL("amount BETWEEN 0.1 AND 0.30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := rule.Node.(*filters.Filter).Value.([]any)
+	if values[0] != json.Number("0.1") || values[1] != json.Number("0.30") {
+		t.Errorf("got %#v, want json.Number literals", values)
+	}
+	type invoice struct {
+		Total float64 `json:"total" filter:",decimal"`
+	}
+	schema, err := filters.SchemaFromStruct(invoice{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := schema.ParseQuery("total=between:0.1,0.30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema["total"] != filters.DecimalField || list[0].Value.([]any)[1] != json.Number("0.30") {
+		t.Errorf("got %v and %#v", schema["total"], list[0].Value)
+	}
+}
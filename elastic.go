@@ -0,0 +1,316 @@
+package filters
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ToElasticQuery converts condition into an Elasticsearch/OpenSearch query.
+// Groups become bool queries: AND children go to filter, OR children to
+// should and negations to must_not. Case-insensitive string operators use
+// the case_insensitive option of term, prefix and wildcard queries. Elements
+// matched with Any, All or None are queried through a nested query unless
+// they are scalars. Relative times are resolved with opts.
+//
+// Nodes that cannot be translated are reported together in a
+// *TranslateError.
+func ToElasticQuery(condition Condition, opts ...TranslateOptions) (map[string]any, error) {
+	b := &elasticBuilder{clock: translateClock(opts)}
+	query := b.condition(condition)
+	if len(b.unsupported) > 0 {
+		return nil, &TranslateError{Target: "elasticsearch", Nodes: b.unsupported}
+	}
+	return query, nil
+}
+
+type elasticBuilder struct {
+	// clock resolves relative times; it is nil without TranslateOptions.
+	clock *clock
+	// path is the array field of the enclosing Any filter, if any.
+	path        string
+	unsupported []Untranslatable
+}
+
+func (b *elasticBuilder) fail(condition Condition, reason string) map[string]any {
+	b.unsupported = append(b.unsupported, Untranslatable{Condition: condition, Reason: reason})
+	return nil
+}
+
+func (b *elasticBuilder) condition(condition Condition) map[string]any {
+	switch c := condition.(type) {
+	case CompiledCondition:
+		return b.condition(c.Source())
+	case *Filter:
+		c = asAny(c)
+		return mustNot(b.filter(c), c.Reverse)
+	case *FilterGroup:
+		if c.Operator != AND && c.Operator != OR {
+			return b.fail(c, fmt.Sprintf("unsupported boolean operator: %s", c.Operator))
+		}
+		return mustNot(b.sequence(c.Operator, c.Filters...), c.Reverse)
+	case *Rule:
+		if c.Node == nil {
+			return b.fail(c, "rule has no condition")
+		}
+		if c.Next == nil {
+			return mustNot(b.condition(c.Node), c.Reverse)
+		}
+		if c.Operator != AND && c.Operator != OR {
+			return b.fail(c, fmt.Sprintf("unsupported boolean operator: %s", c.Operator))
+		}
+		return mustNot(b.sequence(c.Operator, c.Node, c.Next), c.Reverse)
+	case *Join:
+		if c.Left == nil || c.Right == nil {
+			return b.fail(c, "missing left or right filter group")
+		}
+		if c.Operator != AND && c.Operator != OR {
+			return b.fail(c, fmt.Sprintf("unsupported boolean operator: %s", c.Operator))
+		}
+		return mustNot(b.sequence(c.Operator, c.Left, c.Right), c.Reverse)
+	case nil:
+		return b.fail(c, "condition is nil")
+	default:
+		return b.fail(c, "custom conditions cannot be translated")
+	}
+}
+
+func (b *elasticBuilder) sequence(operator Boolean, conditions ...Condition) map[string]any {
+	if len(conditions) == 0 {
+		if operator == AND {
+			return map[string]any{"match_all": map[string]any{}}
+		}
+		return map[string]any{"match_none": map[string]any{}}
+	}
+	if len(conditions) == 1 {
+		return b.condition(conditions[0])
+	}
+	queries := make([]any, len(conditions))
+	for i, condition := range conditions {
+		queries[i] = b.condition(condition)
+	}
+	if operator == AND {
+		return map[string]any{"bool": map[string]any{"filter": queries}}
+	}
+	return map[string]any{"bool": map[string]any{"should": queries, "minimum_should_match": 1}}
+}
+
+func mustNot(query map[string]any, negate bool) map[string]any {
+	if !negate || query == nil {
+		return query
+	}
+	return map[string]any{"bool": map[string]any{"must_not": []any{query}}}
+}
+
+var elasticRanges = map[Operator]string{
+	GreaterThan:      "gt",
+	GreaterThanEqual: "gte",
+	LessThan:         "lt",
+	LessThanEqual:    "lte",
+}
+
+var wildcardEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`)
+
+func (b *elasticBuilder) filter(filter *Filter) map[string]any {
+	if err := filter.Validate(); err != nil {
+		return b.fail(filter, err.Error())
+	}
+	filter, ok := b.clock.translate(filter)
+	if !ok {
+		return b.fail(filter, untranslatableRelative)
+	}
+	if filter.Lookup != nil {
+		return b.fail(filter, "lookups cannot be translated")
+	}
+	if _, isRef := reference(filter.Field); isRef {
+		return b.fail(filter, "computed fields cannot be translated")
+	}
+	if hasReference(filter.Value) {
+		return b.fail(filter, "field references cannot be translated")
+	}
+	field := b.field(filter.Field)
+	if op, ok := elasticRanges[filter.Operator]; ok {
+		return map[string]any{"range": map[string]any{field: map[string]any{op: filter.Value}}}
+	}
+	switch filter.Operator {
+	case Equal, NotEqual:
+		return mustNot(term(field, filter.Value), filter.Operator == NotEqual)
+	case In, NotIn, ContainsAny, Disjoint:
+		negated := filter.Operator == NotIn || filter.Operator == Disjoint
+		return mustNot(map[string]any{"terms": map[string]any{field: sqlValues(filter.Value)}}, negated)
+	case ContainsAll, SupersetOf:
+		var terms []any
+		for _, v := range sqlValues(filter.Value) {
+			terms = append(terms, term(field, v))
+		}
+		return map[string]any{"bool": map[string]any{"filter": terms}}
+	case Between:
+		values := sqlValues(filter.Value)
+		return map[string]any{"range": map[string]any{field: map[string]any{"gte": values[0], "lte": values[1]}}}
+	case IsNull, NotNull:
+		return mustNot(map[string]any{"exists": map[string]any{"field": field}}, filter.Operator == IsNull)
+	case Pattern:
+		pattern := filter.Value.(string)
+		options := map[string]any{}
+		if rest, ok := strings.CutPrefix(pattern, "(?i)"); ok {
+			pattern, options["case_insensitive"] = rest, true
+		}
+		if strings.HasPrefix(pattern, "(?") {
+			return b.fail(filter, "regular expression flags other than (?i) cannot be translated")
+		}
+		value, ok := luceneRegexp(pattern)
+		if !ok {
+			return b.fail(filter, "anchors inside a regular expression cannot be translated")
+		}
+		options["value"] = value
+		return map[string]any{"regexp": map[string]any{field: options}}
+	case Any:
+		return b.any(filter, field)
+	}
+	if shape, ok := likeShapes[filter.Operator]; ok {
+		value, ok := filter.Value.(string)
+		if !ok {
+			return b.fail(filter, "string operators need a string value")
+		}
+		kind := "prefix"
+		if shape.prefix {
+			kind = "wildcard"
+			value = "*" + wildcardEscaper.Replace(value)
+			if shape.suffix {
+				value += "*"
+			}
+		}
+		options := map[string]any{"value": value}
+		if !shape.caseSensitive {
+			options["case_insensitive"] = true
+		}
+		return mustNot(map[string]any{kind: map[string]any{field: options}}, shape.negated)
+	}
+	if _, ok := countOperatorSymbols[filter.Operator]; ok {
+		return b.fail(filter, "count operators cannot be translated")
+	}
+	return b.fail(filter, "operator has no Elasticsearch equivalent")
+}
+
+// any queries the elements of an array field. Scalar elements, referred to
+// as "$", are matched by querying the array field itself; documents need a
+// nested query.
+func (b *elasticBuilder) any(filter *Filter, field string) map[string]any {
+	inner := filter.Value.(Condition)
+	scope := &elasticBuilder{path: field, clock: b.clock}
+	query := scope.condition(inner)
+	b.unsupported = append(b.unsupported, scope.unsupported...)
+	if query == nil || onlySelf(inner) {
+		return query
+	}
+	return map[string]any{"nested": map[string]any{"path": field, "query": query}}
+}
+
+// field returns the full name of field, relative to the enclosing array.
+func (b *elasticBuilder) field(field string) string {
+	switch {
+	case b.path == "":
+		return field
+	case field == SelfField:
+		return b.path
+	}
+	return b.path + "." + field
+}
+
+// onlySelf reports whether every filter in condition refers to SelfField.
+func onlySelf(condition Condition) bool {
+	switch c := condition.(type) {
+	case CompiledCondition:
+		return onlySelf(c.Source())
+	case *Filter:
+		return c.Field == SelfField
+	case *FilterGroup:
+		for _, filter := range c.Filters {
+			if !onlySelf(filter) {
+				return false
+			}
+		}
+		return true
+	case *Rule:
+		return (c.Node == nil || onlySelf(c.Node)) && (c.Next == nil || onlySelf(c.Next))
+	case *Join:
+		return (c.Left == nil || onlySelf(c.Left)) && (c.Right == nil || onlySelf(c.Right))
+	}
+	return false
+}
+
+// term matches value exactly, ignoring case for strings like Equal does.
+func term(field string, value any) map[string]any {
+	if s, ok := value.(string); ok {
+		return map[string]any{"term": map[string]any{field: map[string]any{"value": s, "case_insensitive": true}}}
+	}
+	return map[string]any{"term": map[string]any{field: value}}
+}
+
+// luceneRegexp converts a Go pattern, which matches anywhere in the value,
+// into a Lucene pattern, which must match the whole value. A leading ^ or a
+// trailing $ is dropped only when it anchors the whole pattern; Lucene has
+// no anchors, so the pattern cannot be converted when others remain.
+func luceneRegexp(pattern string) (string, bool) {
+	alternation, anchors := scanRegexp(pattern)
+	start := !alternation && len(anchors) > 0 && anchors[0] == 0 && pattern[0] == '^'
+	if start {
+		anchors = anchors[1:]
+	}
+	end := !alternation && len(anchors) > 0 && anchors[len(anchors)-1] == len(pattern)-1 && pattern[len(pattern)-1] == '$'
+	if end {
+		anchors = anchors[:len(anchors)-1]
+	}
+	if len(anchors) > 0 {
+		return "", false
+	}
+	if end {
+		pattern = pattern[:len(pattern)-1]
+	}
+	if start {
+		pattern = pattern[1:]
+	}
+	if start && end {
+		return pattern, true
+	}
+	if alternation {
+		pattern = "(" + pattern + ")"
+	}
+	if !start {
+		pattern = ".*" + pattern
+	}
+	if !end {
+		pattern += ".*"
+	}
+	return pattern, true
+}
+
+// scanRegexp reports whether pattern has an alternation outside any group
+// and the offsets of its ^ and $ anchors.
+func scanRegexp(pattern string) (alternation bool, anchors []int) {
+	depth, class := 0, false
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\':
+			i++
+		case class:
+			class = c != ']'
+		case c == '[':
+			class = true
+			// a ] right after [ or [^ is a literal
+			if strings.HasPrefix(pattern[i+1:], "^]") {
+				i += 2
+			} else if strings.HasPrefix(pattern[i+1:], "]") {
+				i++
+			}
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == '|':
+			alternation = alternation || depth == 0
+		case c == '^', c == '$':
+			anchors = append(anchors, i)
+		}
+	}
+	return alternation, anchors
+}
@@ -0,0 +1,65 @@
+package filters_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/oarkflow/filters"
+)
+
+func TestToElasticQuery(t *testing.T) {
+	rule, err := filters.ParseSQL("(name = 'Jane' OR city LIKE 'New%') AND age NOT BETWEEN 18 AND 30 AND tags IN ('a', 'b') AND city IS NULL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		condition filters.Condition
+		want      string
+	}{
+		{rule, `{"bool":{"filter":[` +
+			`{"bool":{"minimum_should_match":1,"should":[{"term":{"name":{"case_insensitive":true,"value":"Jane"}}},{"prefix":{"city":{"case_insensitive":true,"value":"New"}}}]}},` +
+			`{"bool":{"must_not":[{"range":{"age":{"gte":18,"lte":30}}}]}},` +
+			`{"terms":{"tags":["a","b"]}},` +
+			`{"bool":{"must_not":[{"exists":{"field":"city"}}]}}]}}`},
+		{filters.NewFilter("name", filters.NotContainsCS, "a*b"),
+			`{"bool":{"must_not":[{"wildcard":{"name":{"value":"*a\\*b*"}}}]}}`},
+		{filters.NewFilter("name", filters.Pattern, "(?i)^jo"),
+			`{"regexp":{"name":{"case_insensitive":true,"value":"jo.*"}}}`},
+		{filters.NewFilter("name", filters.Pattern, "jo|ann"),
+			`{"regexp":{"name":{"value":".*(jo|ann).*"}}}`},
+		{filters.NewFilter("name", filters.Pattern, "^(jo|ann)$"),
+			`{"regexp":{"name":{"value":"(jo|ann)"}}}`},
+		{filters.NewFilter("price", filters.Pattern, `[$^]\d+\$`),
+			`{"regexp":{"price":{"value":".*[$^]\\d+\\$.*"}}}`},
+		{filters.NewFilter("lines", filters.Any, filters.NewFilterGroup(filters.AND, false,
+			filters.NewFilter("sku", filters.StartsWithCS, "B"),
+			filters.NewFilter("qty", filters.GreaterThan, 2),
+		)), `{"nested":{"path":"lines","query":{"bool":{"filter":[{"prefix":{"lines.sku":{"value":"B"}}},{"range":{"lines.qty":{"gt":2}}}]}}}}`},
+		{filters.NewFilter("tags", filters.Any, filters.NewFilter(filters.SelfField, filters.Equal, 7)),
+			`{"term":{"tags":7}}`},
+	}
+	for i, test := range tests {
+		query, err := filters.ToElasticQuery(test.condition)
+		if err != nil {
+			t.Fatalf("case %d: %v", i, err)
+		}
+		got, _ := json.Marshal(query)
+		if string(got) != test.want {
+			t.Errorf("case %d:\n got %s\nwant %s", i, got, test.want)
+		}
+	}
+}
+
+func TestToElasticQueryReportsUntranslatableNodes(t *testing.T) {
+	_, err := filters.ToElasticQuery(filters.NewFilterGroup(filters.OR, false,
+		filters.NewFilter("age", filters.Expression, "age > 1"),
+		filters.NewFilter("logged", filters.GreaterThan, "{{created}}"),
+		filters.NewFilter("age", filters.Equal, 1),
+		filters.NewFilter("name", filters.Pattern, "^jo|ann$"),
+	))
+	var translateErr *filters.TranslateError
+	if !errors.As(err, &translateErr) || len(translateErr.Nodes) != 3 {
+		t.Fatalf("got %v, want a TranslateError with 3 nodes", err)
+	}
+}
@@ -0,0 +1,156 @@
+package filters
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error kinds reported by MatchE. They are wrapped by FilterError, so use
+// errors.Is to check for a particular kind.
+var (
+	ErrFieldNotFound    = errors.New("field not found")
+	ErrTypeMismatch     = errors.New("type mismatch")
+	ErrLookupFailed     = errors.New("lookup failed")
+	ErrExpressionFailed = errors.New("expression failed")
+	ErrInvalidFilter    = errors.New("invalid filter")
+)
+
+// Error kinds reported when a condition does not fit a Schema.
+var (
+	ErrUnknownField       = errors.New("unknown field")
+	ErrOperatorNotAllowed = errors.New("operator not allowed")
+)
+
+// FilterError reports a failure while compiling or evaluating a Filter.
+type FilterError struct {
+	Key      string
+	Field    string
+	Operator Operator
+	Err      error
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("filter %s on field %q (%s): %v", e.Key, e.Field, e.Operator, e.Err)
+}
+
+func (e *FilterError) Unwrap() error {
+	return e.Err
+}
+
+func (filter *Filter) wrapError(err error) error {
+	return &FilterError{Key: filter.Key, Field: filter.Field, Operator: filter.Operator, Err: err}
+}
+
+// SyntaxError reports a malformed query together with where it occurred.
+type SyntaxError struct {
+	// Line and Column are 1-based; Column counts characters, not bytes.
+	Line   int
+	Column int
+	// Token is the offending input, empty at the end of the input.
+	Token string
+	// Expected lists what would have been accepted instead of Token.
+	Expected []string
+	// Message describes errors that are not about an unexpected token.
+	Message string
+	// Snippet is the offending line with the token marked by carets.
+	Snippet string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at line %d, column %d: %s", e.Line, e.Column, e.message())
+}
+
+func (e *SyntaxError) message() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	found := "end of input"
+	if e.Token != "" {
+		found = strconv.Quote(e.Token)
+	}
+	if len(e.Expected) == 0 {
+		return "unexpected " + found
+	}
+	expected := e.Expected[0]
+	if n := len(e.Expected); n > 1 {
+		expected = strings.Join(e.Expected[:n-1], ", ") + " or " + e.Expected[n-1]
+	}
+	return fmt.Sprintf("expected %s, found %s", expected, found)
+}
+
+// SyntaxErrors collects every syntax error found in a query.
+type SyntaxErrors []*SyntaxError
+
+func (e SyntaxErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e SyntaxErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// newSyntaxError locates the token source[pos:end] and builds an error for it.
+func newSyntaxError(source string, pos, end int, message string, expected ...string) *SyntaxError {
+	lineStart := strings.LastIndexByte(source[:pos], '\n') + 1
+	lineEnd := len(source)
+	if i := strings.IndexByte(source[pos:], '\n'); i >= 0 {
+		lineEnd = pos + i
+	}
+	end = min(end, lineEnd)
+	line := strings.TrimRight(source[lineStart:lineEnd], "\r")
+	// keep tabs in the marker line so the caret lines up with the source
+	marker := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, source[lineStart:pos])
+	return &SyntaxError{
+		Line:     strings.Count(source[:pos], "\n") + 1,
+		Column:   utf8.RuneCountInString(source[lineStart:pos]) + 1,
+		Token:    source[pos:end],
+		Expected: expected,
+		Message:  message,
+		Snippet:  line + "\n" + marker + strings.Repeat("^", max(utf8.RuneCountInString(source[pos:end]), 1)),
+	}
+}
+
+// Untranslatable describes a node that has no equivalent in a target query
+// language.
+type Untranslatable struct {
+	Condition Condition
+	Reason    string
+}
+
+func (u Untranslatable) String() string {
+	if filter, ok := u.Condition.(*Filter); ok {
+		return fmt.Sprintf("filter on field %q (%s): %s", filter.Field, filter.Operator, u.Reason)
+	}
+	return fmt.Sprintf("%T: %s", u.Condition, u.Reason)
+}
+
+// TranslateError lists every node of a condition that could not be
+// translated, so the caller can decide to evaluate them in memory instead.
+type TranslateError struct {
+	Target string
+	Nodes  []Untranslatable
+}
+
+func (e *TranslateError) Error() string {
+	reasons := make([]string, len(e.Nodes))
+	for i, node := range e.Nodes {
+		reasons[i] = node.String()
+	}
+	return fmt.Sprintf("cannot translate condition to %s: %s", e.Target, strings.Join(reasons, "; "))
+}
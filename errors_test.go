@@ -0,0 +1,63 @@
+package filters_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/oarkflow/filters"
+)
+
+func TestMatchEReportsErrorKinds(t *testing.T) {
+	data := map[string]any{"name": "John", "age": 30, "tags": []any{"a"}}
+	failing := func(any, string) (any, error) { return nil, errors.New("boom") }
+	cases := []struct {
+		name      string
+		condition filters.Condition
+		mode      filters.MatchMode
+		kind      error
+	}{
+		{"missing field lenient", filters.NewFilter("city", filters.Equal, "x"), filters.Lenient, nil},
+		{"missing field strict", filters.NewFilter("city", filters.Equal, "x"), filters.Strict, filters.ErrFieldNotFound},
+		{"type mismatch", filters.NewFilter("age", filters.Contains, "3"), filters.Lenient, filters.ErrTypeMismatch},
+		{"expression", filters.NewFilter("name", filters.Expression, "name +* 1"), filters.Lenient, filters.ErrExpressionFailed},
+		{"lookup", &filters.Filter{Field: "name", Operator: filters.In, Lookup: &filters.Lookup{Handler: failing}}, filters.Lenient, filters.ErrLookupFailed},
+		{"invalid", filters.NewFilter("age", filters.Between, 1), filters.Lenient, filters.ErrInvalidFilter},
+		{"group", filters.NewFilterGroup(filters.OR, false, filters.NewFilter("age", filters.Equal, 1), filters.NewFilter("missing", filters.Equal, 1)), filters.Strict, filters.ErrFieldNotFound},
+	}
+	for _, tc := range cases {
+		matched, err := filters.MatchE(tc.condition, data, filters.MatchOptions{Mode: tc.mode})
+		if matched {
+			t.Errorf("%s: unexpected match", tc.name)
+		}
+		if tc.kind == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tc.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, tc.kind) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.kind)
+		}
+		var filterErr *filters.FilterError
+		if !errors.As(err, &filterErr) || filterErr.Field == "" {
+			t.Errorf("%s: expected a FilterError carrying the field, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestNegatedOperatorsKeepLenientMatch(t *testing.T) {
+	data := map[string]any{"note": nil, "age": 30}
+	for _, filter := range []*filters.Filter{
+		filters.NewFilter("note", filters.NotContains, "a"),
+		filters.NewFilter("age", filters.NotStartsWith, "3"),
+		filters.NewFilter("age", filters.NotEndsWithCS, "0"),
+	} {
+		if !filter.Match(data) {
+			t.Errorf("%s %s: expected Match to negate the lenient result", filter.Field, filter.Operator)
+		}
+	}
+	_, err := filters.NewFilter("age", filters.NotStartsWith, "3").MatchE(data, filters.MatchOptions{Mode: filters.Strict})
+	if !errors.Is(err, filters.ErrTypeMismatch) {
+		t.Errorf("got %v, want ErrTypeMismatch", err)
+	}
+}
@@ -0,0 +1,198 @@
+package filters
+
+import "fmt"
+
+// NodeType names the kind of node in a condition tree.
+type NodeType string
+
+const (
+	NodeRule      NodeType = "rule"
+	NodeGroup     NodeType = "group"
+	NodeFilter    NodeType = "filter"
+	NodeJoin      NodeType = "join"
+	NodeCondition NodeType = "condition"
+)
+
+// Explanation mirrors the structure of an evaluated condition and records,
+// for every node, what was compared and what the outcome was. Nodes that were
+// not evaluated because an earlier sibling decided the result are marked as
+// short-circuited.
+type Explanation struct {
+	Type           NodeType       `json:"type"`
+	Key            string         `json:"key,omitempty"`
+	Field          string         `json:"field,omitempty"`
+	Operator       string         `json:"operator,omitempty"`
+	FieldValue     any            `json:"field_value,omitempty"`
+	Value          any            `json:"value,omitempty"`
+	Reverse        bool           `json:"reverse,omitempty"`
+	Matched        bool           `json:"matched"`
+	ShortCircuited bool           `json:"short_circuited,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	Children       []*Explanation `json:"children,omitempty"`
+}
+
+// Explain evaluates condition against data and returns a trace of the
+// evaluation. The outcome of every node follows MatchE semantics.
+func Explain(condition Condition, data any, opts ...MatchOptions) *Explanation {
+	return explain(condition, data, matchOptions(opts))
+}
+
+// Explain evaluates the rule against data and returns a trace of the evaluation.
+func (r *Rule) Explain(data any, opts ...MatchOptions) *Explanation {
+	return Explain(r, data, opts...)
+}
+
+func explain(condition Condition, data any, opts MatchOptions) *Explanation {
+	switch c := condition.(type) {
+	case CompiledCondition:
+		return explain(c.Source(), data, opts)
+	case *Filter:
+		return explainFilter(c, data, opts)
+	case *FilterGroup:
+		e := &Explanation{Type: NodeGroup, Operator: string(c.Operator), Reverse: c.Reverse}
+		if c.Operator != AND && c.Operator != OR {
+			e.Error = fmt.Sprintf("unsupported boolean operator: %s", c.Operator)
+			return e
+		}
+		e.Matched, e.Error, e.Children = explainSequence(data, opts, c.Operator, c.Filters...)
+		return finish(e)
+	case *Rule:
+		e := &Explanation{Type: NodeRule, Operator: string(c.Operator), Reverse: c.Reverse}
+		if c.Node == nil {
+			e.Error = "rule has no condition"
+			return e
+		}
+		node := explain(c.Node, data, opts)
+		e.Children = append(e.Children, node)
+		e.Matched, e.Error = node.Matched, node.Error
+		if c.Next != nil {
+			decided := node.Error != "" || (c.Operator == AND && !node.Matched) || (c.Operator == OR && node.Matched)
+			if decided {
+				e.Children = append(e.Children, skipped(c.Next))
+			} else {
+				next := explain(c.Next, data, opts)
+				e.Children = append(e.Children, next)
+				e.Matched, e.Error = next.Matched, next.Error
+			}
+		}
+		return finish(e)
+	case *Join:
+		e := &Explanation{Type: NodeJoin, Operator: string(c.Operator), Reverse: c.Reverse}
+		if c.Left == nil || c.Right == nil {
+			e.Error = "missing left or right filter group"
+			return e
+		}
+		if c.Operator != AND && c.Operator != OR {
+			e.Error = "unsupported boolean operator"
+			return e
+		}
+		e.Matched, e.Error, e.Children = explainSequence(data, opts, c.Operator, c.Left, c.Right)
+		return finish(e)
+	default:
+		e := &Explanation{Type: NodeCondition}
+		matched, err := MatchE(condition, data, opts)
+		e.Matched = matched
+		if err != nil {
+			e.Error = err.Error()
+		}
+		return e
+	}
+}
+
+// explainSequence evaluates conditions combined by operator, marking the ones
+// after the deciding condition as short-circuited.
+func explainSequence(data any, opts MatchOptions, operator Boolean, conditions ...Condition) (bool, string, []*Explanation) {
+	matched := operator == AND
+	var errMsg string
+	children := make([]*Explanation, 0, len(conditions))
+	decided := false
+	for _, condition := range conditions {
+		if decided {
+			children = append(children, skipped(condition))
+			continue
+		}
+		child := explain(condition, data, opts)
+		children = append(children, child)
+		if child.Error != "" {
+			matched, errMsg, decided = false, child.Error, true
+		} else if child.Matched != matched {
+			matched, decided = child.Matched, true
+		}
+	}
+	return matched, errMsg, children
+}
+
+func explainFilter(filter *Filter, data any, opts MatchOptions) *Explanation {
+	e := &Explanation{
+		Type:     NodeFilter,
+		Key:      filter.Key,
+		Field:    filter.Field,
+		Operator: string(filter.Operator),
+		Value:    filter.Value,
+		Reverse:  filter.Reverse,
+	}
+	plan, err := filter.compiled()
+	if err != nil {
+		e.Error = err.Error()
+		return e
+	}
+	fieldValue, val, lookupData, err := plan.resolve(data, opts)
+	if err != nil {
+		// as in Match, missing fields decide the outcome without an error
+		if matched, ok := plan.missing(err, opts); ok {
+			e.Matched = matched
+			return finish(e)
+		}
+		e.Error = filter.wrapError(err).Error()
+		return e
+	}
+	e.FieldValue, e.Value = fieldValue, val
+	e.Matched, err = plan.evaluate(data, fieldValue, val, lookupData, opts)
+	if err != nil {
+		e.Matched, e.Error = false, err.Error()
+		return e
+	}
+	return finish(e)
+}
+
+// finish applies Reverse to a node that evaluated without error.
+func finish(e *Explanation) *Explanation {
+	if e.Reverse && e.Error == "" {
+		e.Matched = !e.Matched
+	}
+	return e
+}
+
+// skipped describes a condition that was not evaluated.
+func skipped(condition Condition) *Explanation {
+	var e *Explanation
+	switch c := condition.(type) {
+	case CompiledCondition:
+		return skipped(c.Source())
+	case *Filter:
+		e = &Explanation{Type: NodeFilter, Key: c.Key, Field: c.Field, Operator: string(c.Operator), Value: c.Value, Reverse: c.Reverse}
+	case *FilterGroup:
+		e = &Explanation{Type: NodeGroup, Operator: string(c.Operator), Reverse: c.Reverse}
+		for _, filter := range c.Filters {
+			e.Children = append(e.Children, skipped(filter))
+		}
+	case *Rule:
+		e = &Explanation{Type: NodeRule, Operator: string(c.Operator), Reverse: c.Reverse}
+		for _, child := range []Condition{c.Node, c.Next} {
+			if child != nil {
+				e.Children = append(e.Children, skipped(child))
+			}
+		}
+	case *Join:
+		e = &Explanation{Type: NodeJoin, Operator: string(c.Operator), Reverse: c.Reverse}
+		for _, child := range []*FilterGroup{c.Left, c.Right} {
+			if child != nil {
+				e.Children = append(e.Children, skipped(child))
+			}
+		}
+	default:
+		e = &Explanation{Type: NodeCondition}
+	}
+	e.ShortCircuited = true
+	return e
+}
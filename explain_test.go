@@ -0,0 +1,42 @@
+package filters_test
+
+import (
+	"testing"
+
+	"github.com/oarkflow/filters"
+)
+
+func TestExplainAgreesWithMatchE(t *testing.T) {
+	data := map[string]any{"name": "John", "age": 30, "tags": []any{"a", "b"}}
+	missing := filters.NewFilter("missing", filters.Equal, 1)
+	age := filters.NewFilter("age", filters.Equal, 30)
+	rule := filters.NewRule()
+	rule.AddCondition(filters.AND, false, &filters.Filter{Field: "missing", Operator: filters.Equal, Value: 1, Reverse: true})
+	rule.AddCondition(filters.OR, false, missing)
+	conditions := map[string]filters.Condition{
+		"missing":          missing,
+		"reversed missing": &filters.Filter{Field: "missing", Operator: filters.Equal, Value: 1, Reverse: true},
+		"or after missing": filters.NewFilterGroup(filters.OR, false, missing, age),
+		"and with missing": filters.NewFilterGroup(filters.AND, false, age, missing),
+		"reversed group":   filters.NewFilterGroup(filters.AND, true, missing, age),
+		"rule":             rule,
+		"join":             &filters.Join{Operator: filters.OR, Left: filters.NewFilterGroup(filters.AND, false, missing), Right: filters.NewFilterGroup(filters.AND, false, age)},
+		"quantifier":       filters.NewFilter("tags", filters.Any, filters.NewFilter("$", filters.Equal, "B")),
+		"type mismatch":    filters.NewFilterGroup(filters.OR, false, filters.NewFilter("age", filters.Contains, "3"), age),
+	}
+	for _, mode := range []filters.MatchMode{filters.Lenient, filters.Strict} {
+		opts := filters.MatchOptions{Mode: mode}
+		for name, condition := range conditions {
+			matched, err := filters.MatchE(condition, data, opts)
+			e := filters.Explain(condition, data, opts)
+			if e.Matched != matched || (e.Error != "") != (err != nil) {
+				t.Errorf("%s in mode %v: explained %v, %q; MatchE %v, %v", name, mode, e.Matched, e.Error, matched, err)
+			}
+		}
+	}
+
+	e := filters.Explain(filters.NewFilterGroup(filters.OR, false, missing, age), data)
+	if len(e.Children) != 2 || e.Children[1].ShortCircuited || !e.Children[1].Matched {
+		t.Errorf("expected the OR group to evaluate past the missing field: %+v", e.Children)
+	}
+}
@@ -1,9 +1,11 @@
 package filters
 
+import "fmt"
+
 type FilterGroup struct {
-	Operator Boolean
-	Filters  []Condition
-	Reverse  bool
+	Operator Boolean     `json:"operator"`
+	Filters  []Condition `json:"filters"`
+	Reverse  bool        `json:"reverse"`
 }
 
 func NewFilterGroup(operator Boolean, reverse bool, conditions ...Condition) *FilterGroup {
@@ -14,6 +16,28 @@ func (group *FilterGroup) Match(data any) bool {
 	return MatchGroup(data, group)
 }
 
+// MatchE evaluates the group like Match but stops at the first error.
+func (group *FilterGroup) MatchE(data any, opts ...MatchOptions) (bool, error) {
+	if group.Operator != AND && group.Operator != OR {
+		return false, fmt.Errorf("unsupported boolean operator: %s", group.Operator)
+	}
+	matched := group.Operator == AND
+	for _, filter := range group.Filters {
+		m, err := MatchE(filter, data, opts...)
+		if err != nil {
+			return false, err
+		}
+		if m != matched {
+			matched = !matched
+			break
+		}
+	}
+	if group.Reverse {
+		return !matched, nil
+	}
+	return matched, nil
+}
+
 func ApplyGroup[T any](collection []T, filterGroups ...*FilterGroup) []T {
 	var position, size = 0, len(collection)
 	for i := 0; i < size; i++ {
@@ -49,6 +73,11 @@ func MatchGroup[T any](item T, group *FilterGroup) bool {
 					matched = false
 					break
 				}
+			default:
+				if !filter.Match(item) {
+					matched = false
+					break
+				}
 			}
 		}
 		if group.Reverse {
@@ -69,6 +98,11 @@ func MatchGroup[T any](item T, group *FilterGroup) bool {
 					matched = true
 					break
 				}
+			default:
+				if filter.Match(item) {
+					matched = true
+					break
+				}
 			}
 		}
 		if group.Reverse {
@@ -17,6 +17,10 @@ type Lookup struct {
 	HandlerCondition string `json:"handler_condition"`
 }
 
+// SelfField is the field name that resolves to the data itself, for filters
+// applied to the scalar elements of an array.
+const SelfField = "$"
+
 type Filter struct {
 	Key       string   `json:"key"`
 	FilterKey string   `json:"filter_key"`
@@ -33,6 +37,20 @@ func (filter *Filter) Match(data any) bool {
 	return Match(data, filter)
 }
 
+// MatchE evaluates the filter like Match but reports why it failed. In the
+// default Lenient mode a missing field is a non-match; Strict mode reports it
+// as ErrFieldNotFound.
+func (filter *Filter) MatchE(data any, opts ...MatchOptions) (bool, error) {
+	matched, err := matchFilter(data, filter, matchOptions(opts))
+	if err != nil {
+		return false, err
+	}
+	if filter.Reverse {
+		return !matched, nil
+	}
+	return matched, nil
+}
+
 func (filter *Filter) SetLookup(lookup *Lookup) {
 	filter.Lookup = lookup
 }
@@ -45,6 +63,19 @@ func Match[T any](item T, filter *Filter) bool {
 	return matched
 }
 
+// compiled validates the filter and builds its evaluation plan. The plan is
+// not kept, since the fields of a Filter may change between matches; use
+// Compile to reuse one.
+func (filter *Filter) compiled() (*compiledFilter, error) {
+	if !filter.validated {
+		_ = filter.Validate()
+	}
+	if filter.err != nil {
+		return nil, filter.wrapError(fmt.Errorf("%w: %v", ErrInvalidFilter, filter.err))
+	}
+	return newCompiledFilter(filter)
+}
+
 func (filter *Filter) Validate() error {
 	filter.validated = true
 	if filter.Field == "" {
@@ -55,18 +86,28 @@ func (filter *Filter) Validate() error {
 		filter.err = fmt.Errorf("invalid operator: %s", filter.Operator)
 		return filter.err
 	}
-	if filter.Operator == Between {
-		if reflect.TypeOf(filter.Value).Kind() != reflect.Slice || reflect.ValueOf(filter.Value).Len() != 2 {
-			filter.err = errors.New("between filter must have a slice of two elements as value")
+	if filter.Operator == Between || filter.Operator == LenBetween {
+		if reflect.ValueOf(filter.Value).Kind() != reflect.Slice || reflect.ValueOf(filter.Value).Len() != 2 {
+			filter.err = fmt.Errorf("%s filter must have a slice of two elements as value", filter.Operator)
+			return filter.err
+		}
+	}
+	if isQuantifier(filter.Operator) {
+		if _, ok := filter.Value.(Condition); !ok {
+			filter.err = fmt.Errorf("%s filter must have a condition as value", filter.Operator)
 			return filter.err
 		}
 	}
 	if filter.Operator == In && filter.Lookup == nil {
-		if reflect.TypeOf(filter.Value).Kind() != reflect.Slice {
+		if reflect.ValueOf(filter.Value).Kind() != reflect.Slice {
 			filter.err = errors.New("in filter must have a slice as value")
 			return filter.err
 		}
 	}
+	if err := validateDateFilter(filter); err != nil {
+		filter.err = err
+		return filter.err
+	}
 	return nil
 }
 
@@ -51,3 +51,33 @@ func MatchJoin[T any](item T, expr Join) bool {
 		return false
 	}
 }
+
+func (join *Join) Match(data any) bool {
+	return MatchJoin(data, *join)
+}
+
+func (join *Join) MatchE(data any, opts ...MatchOptions) (bool, error) {
+	if join.Left == nil || join.Right == nil {
+		return false, errors.New("missing left or right filter group")
+	}
+	return matchJoinE(data, join.Operator, join.Reverse, join.Left, join.Right, opts)
+}
+
+func matchJoinE(data any, operator Boolean, reverse bool, left, right Condition, opts []MatchOptions) (bool, error) {
+	if operator != AND && operator != OR {
+		return false, errors.New("unsupported boolean operator")
+	}
+	matched, err := MatchE(left, data, opts...)
+	if err != nil {
+		return false, err
+	}
+	if (operator == AND) == matched {
+		if matched, err = MatchE(right, data, opts...); err != nil {
+			return false, err
+		}
+	}
+	if reverse {
+		return !matched, nil
+	}
+	return matched, nil
+}
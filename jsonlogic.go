@@ -0,0 +1,525 @@
+package filters
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ParseJSONLogic converts a JsonLogic rule into a condition. Comparisons
+// must compare a {"var": ...} with a literal or with another var, which
+// becomes a {{field}} reference. Inside some, all and none, {"var": ""} is
+// the array element itself. Strings compared with ==, != or in become exact,
+// case-sensitive patterns, as JsonLogic compares them, rather than the
+// case-insensitive Equal and In. Note that all is true for an empty array
+// here, while JsonLogic itself returns false.
+func ParseJSONLogic(data []byte) (Condition, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var rule any
+	if err := decoder.Decode(&rule); err != nil {
+		return nil, err
+	}
+	return parseLogic(normalizeValue(rule))
+}
+
+func parseLogic(rule any) (Condition, error) {
+	switch r := rule.(type) {
+	case bool:
+		if r {
+			return NewFilterGroup(AND, false), nil
+		}
+		return NewFilterGroup(OR, false), nil
+	case map[string]any:
+		if len(r) != 1 {
+			return nil, fmt.Errorf("a JsonLogic operation must have exactly one operator, got %d", len(r))
+		}
+		for op, args := range r {
+			return parseLogicOperation(op, args)
+		}
+	}
+	return nil, fmt.Errorf("unsupported JsonLogic rule %v", rule)
+}
+
+var logicComparisons = map[string]Operator{
+	"==":  Equal,
+	"===": Equal,
+	"!=":  NotEqual,
+	"!==": NotEqual,
+	">":   GreaterThan,
+	">=":  GreaterThanEqual,
+	"<":   LessThan,
+	"<=":  LessThanEqual,
+}
+
+// flipped gives the operator to use when the var is on the right.
+var flipped = map[Operator]Operator{
+	Equal:            Equal,
+	NotEqual:         NotEqual,
+	GreaterThan:      LessThan,
+	GreaterThanEqual: LessThanEqual,
+	LessThan:         GreaterThan,
+	LessThanEqual:    GreaterThanEqual,
+}
+
+func parseLogicOperation(op string, args any) (Condition, error) {
+	list, isList := args.([]any)
+	if !isList {
+		list = []any{args}
+	}
+	switch op {
+	case "and", "or":
+		conditions := make([]Condition, 0, len(list))
+		for _, arg := range list {
+			condition, err := parseLogic(arg)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, condition)
+		}
+		if op == "and" {
+			return NewFilterGroup(AND, false, conditions...), nil
+		}
+		return NewFilterGroup(OR, false, conditions...), nil
+	case "!":
+		if len(list) != 1 {
+			return nil, errors.New(`"!" expects one argument`)
+		}
+		condition, err := parseLogic(list[0])
+		if err != nil {
+			return nil, err
+		}
+		return negate(condition), nil
+	case "in":
+		return parseLogicIn(list)
+	case "some", "all", "none":
+		return parseLogicQuantifier(op, list)
+	}
+	operator, ok := logicComparisons[op]
+	if !ok {
+		return nil, fmt.Errorf("unsupported JsonLogic operator %q", op)
+	}
+	switch len(list) {
+	case 2:
+		return parseLogicComparison(op, operator, list[0], list[1])
+	case 3:
+		// {"<": [a, {"var": "x"}, b]} checks that x lies between a and b
+		if operator != LessThan && operator != LessThanEqual {
+			return nil, fmt.Errorf("%q does not take three arguments", op)
+		}
+		field, ok := logicVar(list[1])
+		if !ok {
+			return nil, fmt.Errorf("the middle argument of %q must be a var", op)
+		}
+		if operator == LessThanEqual {
+			return NewFilter(field, Between, []any{logicValue(list[0]), logicValue(list[2])}), nil
+		}
+		return NewFilterGroup(AND, false,
+			NewFilter(field, GreaterThan, logicValue(list[0])),
+			NewFilter(field, LessThan, logicValue(list[2])),
+		), nil
+	}
+	return nil, fmt.Errorf("%q expects two or three arguments", op)
+}
+
+func parseLogicComparison(op string, operator Operator, left, right any) (Condition, error) {
+	field, ok := logicVar(left)
+	value := right
+	if !ok {
+		if field, ok = logicVar(right); !ok {
+			return nil, fmt.Errorf("%q must compare a var", op)
+		}
+		operator, value = flipped[operator], left
+	}
+	value = logicValue(value)
+	if value == nil {
+		switch operator {
+		case Equal:
+			return NewFilter(field, IsNull, nil), nil
+		case NotEqual:
+			return NewFilter(field, NotNull, nil), nil
+		}
+		return nil, fmt.Errorf("%q cannot compare with null", op)
+	}
+	if s, ok := literalString(value); ok && (operator == Equal || operator == NotEqual) {
+		filter := NewFilter(field, Pattern, exactPattern([]string{s}))
+		filter.Reverse = operator == NotEqual
+		return filter, nil
+	}
+	return NewFilter(field, operator, value), nil
+}
+
+// parseLogicIn handles both forms of "in": a var in an array of values, and
+// a value in a var, which JsonLogic treats as a substring test for strings
+// and a membership test for arrays.
+func parseLogicIn(list []any) (Condition, error) {
+	if len(list) != 2 {
+		return nil, errors.New(`"in" expects two arguments`)
+	}
+	if field, ok := logicVar(list[0]); ok {
+		values, ok := logicValue(list[1]).([]any)
+		if !ok {
+			return nil, errors.New(`"in" on a var expects an array of values`)
+		}
+		var texts []string
+		var others []any
+		for _, v := range values {
+			if s, ok := literalString(v); ok {
+				texts = append(texts, s)
+			} else {
+				others = append(others, v)
+			}
+		}
+		switch {
+		case len(texts) == 0:
+			return NewFilter(field, In, values), nil
+		case len(others) == 0:
+			return NewFilter(field, Pattern, exactPattern(texts)), nil
+		}
+		return NewFilterGroup(OR, false, NewFilter(field, In, others), NewFilter(field, Pattern, exactPattern(texts))), nil
+	}
+	field, ok := logicVar(list[1])
+	if !ok {
+		return nil, errors.New(`"in" must involve a var`)
+	}
+	value := logicValue(list[0])
+	if s, ok := value.(string); ok {
+		return NewFilterGroup(OR, false,
+			NewFilter(field, ContainsCS, s),
+			NewFilter(field, In, []any{s}),
+		), nil
+	}
+	return NewFilter(field, In, []any{value}), nil
+}
+
+func parseLogicQuantifier(op string, list []any) (Condition, error) {
+	if len(list) != 2 {
+		return nil, fmt.Errorf("%q expects two arguments", op)
+	}
+	field, ok := logicVar(list[0])
+	if !ok {
+		return nil, fmt.Errorf("the first argument of %q must be a var", op)
+	}
+	condition, err := parseLogic(list[1])
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "all":
+		return NewFilter(field, All, condition), nil
+	case "none":
+		return NewFilter(field, None, condition), nil
+	}
+	return NewFilter(field, Any, condition), nil
+}
+
+// logicVar returns the field named by {"var": "field"} or {"var": ["field"]}.
+// The empty name refers to the current element of some, all and none.
+func logicVar(v any) (string, bool) {
+	m, ok := v.(map[string]any)
+	if !ok || len(m) != 1 {
+		return "", false
+	}
+	name, ok := m["var"]
+	if !ok {
+		return "", false
+	}
+	if list, isList := name.([]any); isList && len(list) == 1 {
+		name = list[0]
+	}
+	field, ok := name.(string)
+	if !ok {
+		return "", false
+	}
+	if field == "" {
+		return SelfField, true
+	}
+	return field, true
+}
+
+// literalString returns v when it is a string other than a {{field}}
+// reference.
+func literalString(v any) (string, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return "", false
+	}
+	_, isRef := reference(s)
+	return s, !isRef
+}
+
+// exactPattern returns an anchored, case-sensitive pattern matching exactly
+// the given strings.
+func exactPattern(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = regexp.QuoteMeta(v)
+	}
+	if len(quoted) == 1 {
+		return "^" + quoted[0] + "$"
+	}
+	return "^(?:" + strings.Join(quoted, "|") + ")$"
+}
+
+// exactValues recovers the strings of a pattern written by exactPattern.
+func exactValues(pattern string) ([]string, bool) {
+	body, ok := strings.CutPrefix(pattern, "^")
+	if !ok || !strings.HasSuffix(body, "$") {
+		return nil, false
+	}
+	body = body[:len(body)-1]
+	if inner, ok := strings.CutPrefix(body, "(?:"); ok && strings.HasSuffix(inner, ")") {
+		body = inner[:len(inner)-1]
+	}
+	var values []string
+	var value strings.Builder
+	for i := 0; i < len(body); i++ {
+		switch c := body[i]; {
+		case c == '\\' && i+1 < len(body):
+			i++
+			value.WriteByte(body[i])
+		case c == '|':
+			values = append(values, value.String())
+			value.Reset()
+		default:
+			value.WriteByte(c)
+		}
+	}
+	values = append(values, value.String())
+	return values, exactPattern(values) == pattern
+}
+
+// logicValue turns a var used as a value into a {{field}} reference.
+func logicValue(v any) any {
+	if field, ok := logicVar(v); ok {
+		return "{{" + field + "}}"
+	}
+	return v
+}
+
+// ToJSONLogic converts condition into a JsonLogic rule. Only operators with
+// a JsonLogic equivalent are supported. JsonLogic compares strings
+// case-sensitively, so Equal, NotEqual, In and NotIn on strings are reported
+// like the other case-insensitive string operators, while the exact patterns
+// ParseJSONLogic writes become == and in again. Other patterns, expressions,
+// lookups and count operators are reported in a
+// *TranslateError, as are relative times unless opts are given to resolve
+// them to RFC 3339 strings.
+func ToJSONLogic(condition Condition, opts ...TranslateOptions) (map[string]any, error) {
+	b := &logicBuilder{clock: translateClock(opts)}
+	rule := b.condition(condition)
+	if len(b.unsupported) > 0 {
+		return nil, &TranslateError{Target: "jsonlogic", Nodes: b.unsupported}
+	}
+	return rule, nil
+}
+
+type logicBuilder struct {
+	// clock resolves relative times; it is nil without TranslateOptions.
+	clock       *clock
+	unsupported []Untranslatable
+}
+
+func (b *logicBuilder) fail(condition Condition, reason string) map[string]any {
+	b.unsupported = append(b.unsupported, Untranslatable{Condition: condition, Reason: reason})
+	return nil
+}
+
+func (b *logicBuilder) condition(condition Condition) map[string]any {
+	switch c := condition.(type) {
+	case CompiledCondition:
+		return b.condition(c.Source())
+	case *Filter:
+		if isQuantifier(c.Operator) {
+			return b.quantifier(asAny(c))
+		}
+		return logicNot(b.filter(c), c.Reverse)
+	case *FilterGroup:
+		if c.Operator != AND && c.Operator != OR {
+			return b.fail(c, fmt.Sprintf("unsupported boolean operator: %s", c.Operator))
+		}
+		return logicNot(b.sequence(c.Operator, c.Filters...), c.Reverse)
+	case *Rule:
+		if c.Node == nil {
+			return b.fail(c, "rule has no condition")
+		}
+		if c.Next == nil {
+			return logicNot(b.condition(c.Node), c.Reverse)
+		}
+		if c.Operator != AND && c.Operator != OR {
+			return b.fail(c, fmt.Sprintf("unsupported boolean operator: %s", c.Operator))
+		}
+		return logicNot(b.sequence(c.Operator, c.Node, c.Next), c.Reverse)
+	case *Join:
+		if c.Left == nil || c.Right == nil {
+			return b.fail(c, "missing left or right filter group")
+		}
+		if c.Operator != AND && c.Operator != OR {
+			return b.fail(c, fmt.Sprintf("unsupported boolean operator: %s", c.Operator))
+		}
+		return logicNot(b.sequence(c.Operator, c.Left, c.Right), c.Reverse)
+	case nil:
+		return b.fail(c, "condition is nil")
+	default:
+		return b.fail(c, "custom conditions cannot be translated")
+	}
+}
+
+func (b *logicBuilder) sequence(operator Boolean, conditions ...Condition) map[string]any {
+	if len(conditions) == 1 {
+		return b.condition(conditions[0])
+	}
+	rules := make([]any, len(conditions))
+	for i, condition := range conditions {
+		rules[i] = b.condition(condition)
+	}
+	if operator == AND {
+		return map[string]any{"and": rules}
+	}
+	return map[string]any{"or": rules}
+}
+
+// logicNot wraps rule in "!" when negate is set.
+func logicNot(rule map[string]any, negate bool) map[string]any {
+	if !negate || rule == nil {
+		return rule
+	}
+	return map[string]any{"!": []any{rule}}
+}
+
+var logicOperators = map[Operator]string{
+	Equal:            "==",
+	NotEqual:         "!=",
+	GreaterThan:      ">",
+	GreaterThanEqual: ">=",
+	LessThan:         "<",
+	LessThanEqual:    "<=",
+}
+
+func (b *logicBuilder) filter(filter *Filter) map[string]any {
+	if err := filter.Validate(); err != nil {
+		return b.fail(filter, err.Error())
+	}
+	filter, ok := b.clock.translate(filter)
+	if !ok {
+		return b.fail(filter, untranslatableRelative)
+	}
+	if filter.Lookup != nil {
+		return b.fail(filter, "lookups cannot be translated")
+	}
+	if _, isRef := reference(filter.Field); isRef {
+		return b.fail(filter, "computed fields cannot be translated")
+	}
+	field := toLogicVar(filter.Field)
+	switch filter.Operator {
+	case Equal, NotEqual, In, NotIn:
+		if slices.ContainsFunc(sqlValues(filter.Value), func(v any) bool {
+			_, ok := literalString(v)
+			return ok
+		}) {
+			return b.fail(filter, "strings compare case-insensitively, unlike in JsonLogic")
+		}
+	case Pattern:
+		values, ok := exactValues(filter.Value.(string))
+		if !ok {
+			break
+		}
+		if len(values) == 1 {
+			return map[string]any{"==": []any{field, values[0]}}
+		}
+		list := make([]any, len(values))
+		for i, v := range values {
+			list[i] = v
+		}
+		return map[string]any{"in": []any{field, list}}
+	}
+	if op, ok := logicOperators[filter.Operator]; ok {
+		return map[string]any{op: []any{field, toLogicValue(filter.Value)}}
+	}
+	switch filter.Operator {
+	case Between:
+		values := sqlValues(filter.Value)
+		return map[string]any{"<=": []any{toLogicValue(values[0]), field, toLogicValue(values[1])}}
+	case In, NotIn:
+		values := sqlValues(filter.Value)
+		for i, v := range values {
+			values[i] = toLogicValue(v)
+		}
+		return logicNot(map[string]any{"in": []any{field, values}}, filter.Operator == NotIn)
+	case IsNull:
+		return map[string]any{"==": []any{field, nil}}
+	case NotNull:
+		return map[string]any{"!=": []any{field, nil}}
+	case ContainsCS, NotContainsCS:
+		return logicNot(map[string]any{"in": []any{toLogicValue(filter.Value), field}}, filter.Operator == NotContainsCS)
+	}
+	return b.fail(filter, "operator has no JsonLogic equivalent")
+}
+
+// quantifier converts an Any filter into some, or into none when it is
+// reversed. A reversed Any over a negated condition, as asAny produces for
+// All, becomes all again.
+func (b *logicBuilder) quantifier(filter *Filter) map[string]any {
+	if err := filter.Validate(); err != nil {
+		return b.fail(filter, err.Error())
+	}
+	inner := filter.Value.(Condition)
+	op := "some"
+	if filter.Reverse {
+		op = "none"
+		if positive, ok := unnegated(inner); ok {
+			op, inner = "all", positive
+		}
+	}
+	rule := b.condition(inner)
+	if rule == nil {
+		return nil
+	}
+	return map[string]any{op: []any{toLogicVar(filter.Field), rule}}
+}
+
+// unnegated returns a copy of a reversed filter or group without the
+// negation.
+func unnegated(condition Condition) (Condition, bool) {
+	switch c := condition.(type) {
+	case *Filter:
+		if c.Reverse {
+			positive := *c
+			positive.Reverse = false
+			return &positive, true
+		}
+	case *FilterGroup:
+		if c.Reverse {
+			positive := *c
+			positive.Reverse = false
+			return &positive, true
+		}
+	}
+	return nil, false
+}
+
+func toLogicVar(field string) map[string]any {
+	if field == SelfField {
+		field = ""
+	}
+	return map[string]any{"var": field}
+}
+
+// toLogicValue turns a {{field}} reference into a var and a time into an
+// RFC 3339 string.
+func toLogicValue(v any) any {
+	switch val := v.(type) {
+	case string:
+		if ref, isRef := reference(val); isRef {
+			return toLogicVar(ref)
+		}
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	}
+	return v
+}
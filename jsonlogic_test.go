@@ -0,0 +1,139 @@
+package filters_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/oarkflow/filters"
+)
+
+func TestParseJSONLogic(t *testing.T) {
+	tests := []struct {
+		rule string
+		want []bool
+	}{
+		{`{"and": [{">": [{"var": "age"}, 25]}, {"<": [{"var": "age"}, 35]}]}`, []bool{true, false, false}},
+		{`{"or": [{"==": [{"var": "age"}, 25]}, {"==": [{"var": "city"}, null]}]}`, []bool{false, true, true}},
+		{`{"<=": [25, {"var": "age"}, 30]}`, []bool{true, true, false}},
+		{`{"<": [25, {"var": "age"}, 35]}`, []bool{true, false, false}},
+		{`{">": [30, {"var": "age"}]}`, []bool{false, true, false}},
+		{`{"!": {"in": [{"var": "city"}, ["New York", "Los Angeles"]]}}`, []bool{false, false, true}},
+		{`{"in": [{"var": "city"}, ["new york", "Los Angeles", 1]]}`, []bool{false, true, false}},
+		{`{"==": [{"var": "name"}, "john doe"]}`, []bool{false, false, false}},
+		{`{"!=": [{"var": "name"}, "John Doe"]}`, []bool{false, true, true}},
+		{`{"in": ["Smith", {"var": "name"}]}`, []bool{false, true, false}},
+		{`{"in": ["b", {"var": "tags"}]}`, []bool{true, false, false}},
+		{`{"some": [{"var": "tags"}, {"==": [{"var": ""}, "c"]}]}`, []bool{false, true, false}},
+		{`{"all": [{"var": "tags"}, {"in": [{"var": ""}, ["a", "b"]]}]}`, []bool{true, false, true}},
+		{`{"none": [{"var": "tags"}, {"==": [{"var": ""}, "a"]}]}`, []bool{false, true, true}},
+		{`true`, []bool{true, true, true}},
+	}
+	for _, test := range tests {
+		condition, err := filters.ParseJSONLogic([]byte(test.rule))
+		if err != nil {
+			t.Fatalf("%s: %v", test.rule, err)
+		}
+		for i, person := range people {
+			if got := condition.Match(person); got != test.want[i] {
+				t.Errorf("%s: record %d: got %v, want %v", test.rule, i, got, test.want[i])
+			}
+		}
+	}
+}
+
+func TestParseJSONLogicErrors(t *testing.T) {
+	for _, rule := range []string{
+		`{"+": [1, 2]}`,
+		`{"==": [1, 2]}`,
+		`{">": [{"var": "age"}, null]}`,
+		`{"==": [{"var": "a"}, 1], "!=": [{"var": "b"}, 2]}`,
+		`{"some": [[1, 2], {"==": [{"var": ""}, 1]}]}`,
+		`{"var": "age"}`,
+	} {
+		if _, err := filters.ParseJSONLogic([]byte(rule)); err == nil {
+			t.Errorf("%s: expected an error", rule)
+		}
+	}
+}
+
+func TestToJSONLogic(t *testing.T) {
+	rule := `{"and": [` +
+		`{"<=": [18, {"var": "age"}, 30]},` +
+		`{"!=": [{"var": "age"}, {"var": "limit"}]},` +
+		`{"!": [{"in": [{"var": "city"}, ["x", "y"]]}]},` +
+		`{"or": [{"==": [{"var": "city"}, null]}, {"in": ["a", {"var": "name"}]}]},` +
+		`{"all": [{"var": "lines"}, {">": [{"var": "qty"}, 0]}]},` +
+		`{"none": [{"var": "tags"}, {"==": [{"var": ""}, "c"]}]}` +
+		`]}`
+	var want map[string]any
+	if err := json.Unmarshal([]byte(rule), &want); err != nil {
+		t.Fatal(err)
+	}
+	condition := filters.NewFilterGroup(filters.AND, false,
+		filters.NewFilter("age", filters.Between, []any{18, 30}),
+		filters.NewFilter("age", filters.NotEqual, "{{limit}}"),
+		&filters.Filter{Field: "city", Operator: filters.Pattern, Value: "^(?:x|y)$", Reverse: true},
+		filters.NewFilterGroup(filters.OR, false,
+			filters.NewFilter("city", filters.IsNull, nil),
+			filters.NewFilter("name", filters.ContainsCS, "a"),
+		),
+	)
+	parsed, err := filters.ParseJSONLogic([]byte(`{"and": [` +
+		`{"all": [{"var": "lines"}, {">": [{"var": "qty"}, 0]}]},` +
+		`{"none": [{"var": "tags"}, {"==": [{"var": ""}, "c"]}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	condition.Filters = append(condition.Filters, parsed.(*filters.FilterGroup).Filters...)
+	got, err := filters.ToJSONLogic(condition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// compare through JSON so that numbers have the same type
+	data, _ := json.Marshal(got)
+	var normalized map[string]any
+	_ = json.Unmarshal(data, &normalized)
+	if !reflect.DeepEqual(normalized, want) {
+		t.Errorf("got %s", data)
+	}
+}
+
+func TestToJSONLogicReportsUntranslatableNodes(t *testing.T) {
+	condition := filters.NewFilterGroup(filters.OR, false,
+		filters.NewFilter("name", filters.StartsWith, "J"),
+		filters.NewFilter("tags", filters.EqualCount, 2),
+		filters.NewFilter("age", filters.Equal, 30),
+		filters.NewFilter("name", filters.Equal, "jane smith"),
+		filters.NewFilter("city", filters.NotIn, []string{"x", "y"}),
+	)
+	_, err := filters.ToJSONLogic(condition)
+	var translateErr *filters.TranslateError
+	if !errors.As(err, &translateErr) {
+		t.Fatalf("expected a TranslateError, got %v", err)
+	}
+	if len(translateErr.Nodes) != 4 {
+		t.Errorf("expected 4 untranslatable nodes, got %d", len(translateErr.Nodes))
+	}
+}
+
+func TestJSONLogicStringEqualityRoundTrip(t *testing.T) {
+	for _, rule := range []string{
+		`{"==":[{"var":"name"},"Jane Smith"]}`,
+		`{"!":[{"==":[{"var":"name"},"a|b (c)"]}]}`,
+		`{"in":[{"var":"city"},["Oslo","New York"]]}`,
+	} {
+		condition, err := filters.ParseJSONLogic([]byte(rule))
+		if err != nil {
+			t.Fatalf("%s: %v", rule, err)
+		}
+		got, err := filters.ToJSONLogic(condition)
+		if err != nil {
+			t.Fatalf("%s: %v", rule, err)
+		}
+		if data, _ := json.Marshal(got); string(data) != rule {
+			t.Errorf("got %s, want %s", data, rule)
+		}
+	}
+}
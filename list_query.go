@@ -0,0 +1,250 @@
+package filters
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/oarkflow/filters/utils"
+)
+
+// NullOrder places missing and nil values when sorting. The zero value sorts
+// them as larger than any other value: last ascending, first descending.
+type NullOrder string
+
+const (
+	NullsFirst NullOrder = "first"
+	NullsLast  NullOrder = "last"
+)
+
+// SortKey orders results by one field.
+type SortKey struct {
+	Field string    `json:"field"`
+	Desc  bool      `json:"desc"`
+	Nulls NullOrder `json:"nulls,omitempty"`
+}
+
+// Query is a list request: which records to return, in what order, which
+// page of them and which of their fields.
+type Query struct {
+	Filter *FilterGroup `json:"filter"`
+	Sort   []SortKey    `json:"sort,omitempty"`
+	// Limit caps the number of results; zero means no limit.
+	Limit  int `json:"limit,omitempty"`
+	Offset int `json:"offset,omitempty"`
+	// Cursor continues a keyset paginated result after the record it was
+	// encoded from; see EncodeCursor.
+	Cursor string   `json:"cursor,omitempty"`
+	Fields []string `json:"fields,omitempty"`
+}
+
+// Parameters of a list query that are not filters.
+const (
+	sortParam   = "sort"
+	limitParam  = "limit"
+	offsetParam = "offset"
+	cursorParam = "cursor"
+	fieldsParam = "fields"
+)
+
+// ParseListQuery parses the query string of a list endpoint such as
+// ?status:eq:active&sort=-created_at,name&limit=20&offset=40&fields=id,name.
+//
+// The sort, limit, offset, cursor and fields parameters are read into the
+// Query and every other parameter is a filter as accepted by
+// ParseQueryGroup. Sort keys are separated by commas; a leading - sorts
+// descending, a leading + ascending, and a :nullsfirst or :nullslast suffix
+// places missing values.
+func ParseListQuery(queryString string, exceptFields ...string) (Query, error) {
+	queryParams, err := url.ParseQuery(strings.TrimPrefix(queryString, "?"))
+	if err != nil {
+		return Query{}, err
+	}
+	var query Query
+	if query.Sort, err = parseSort(queryParams.Get(sortParam)); err != nil {
+		return Query{}, err
+	}
+	if query.Limit, err = parseCount(limitParam, queryParams.Get(limitParam)); err != nil {
+		return Query{}, err
+	}
+	if query.Offset, err = parseCount(offsetParam, queryParams.Get(offsetParam)); err != nil {
+		return Query{}, err
+	}
+	query.Cursor = queryParams.Get(cursorParam)
+	for _, field := range strings.Split(queryParams.Get(fieldsParam), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			query.Fields = append(query.Fields, field)
+		}
+	}
+	for _, param := range []string{sortParam, limitParam, offsetParam, cursorParam, fieldsParam} {
+		queryParams.Del(param)
+	}
+	if query.Filter, err = queryGroup(queryParams, exceptFields); err != nil {
+		return Query{}, err
+	}
+	if _, err := query.CursorFilter(); err != nil {
+		return Query{}, err
+	}
+	return query, nil
+}
+
+func parseSort(value string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, spec := range strings.Split(value, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		var key SortKey
+		switch spec[0] {
+		case '-':
+			key.Desc = true
+			spec = spec[1:]
+		case '+':
+			spec = spec[1:]
+		}
+		field, nulls, _ := strings.Cut(spec, ":")
+		switch strings.ToLower(nulls) {
+		case "":
+		case "nullsfirst":
+			key.Nulls = NullsFirst
+		case "nullslast":
+			key.Nulls = NullsLast
+		default:
+			return nil, fmt.Errorf("invalid sort order %q", nulls)
+		}
+		if field == "" {
+			return nil, fmt.Errorf("sort key without a field: %q", value)
+		}
+		key.Field = field
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func parseCount(param, value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", param, value)
+	}
+	return n, nil
+}
+
+// ApplyQuery returns the records of collection that match query.Filter,
+// sorted by query.Sort and cut to the page given by Cursor, Offset and
+// Limit. When T is map[string]any the records are also projected to
+// query.Fields; use Project for other types. A cursor that does not decode
+// matches no records. The collection itself is not modified.
+func ApplyQuery[T any](collection []T, query Query) []T {
+	items := slices.Clone(collection)
+	if query.Filter != nil {
+		items = ApplyGroup(items, query.Filter)
+	}
+	keyset, err := query.CursorFilter()
+	if err != nil {
+		return nil
+	}
+	if keyset != nil {
+		items = ApplyGroup(items, keyset)
+	}
+	if len(query.Sort) > 0 {
+		SortBy(items, query.Sort...)
+	}
+	items = items[min(query.Offset, len(items)):]
+	if query.Limit > 0 && query.Limit < len(items) {
+		items = items[:query.Limit]
+	}
+	if len(query.Fields) > 0 {
+		for i, item := range items {
+			if projected, ok := any(Project(item, query.Fields)).(T); ok {
+				items[i] = projected
+			}
+		}
+	}
+	return items
+}
+
+// SortBy sorts items in place by the given keys. The sort is stable, so
+// records with equal keys keep their order.
+func SortBy[T any](items []T, keys ...SortKey) {
+	type row struct {
+		item   T
+		values []any
+	}
+	rows := make([]row, len(items))
+	for i, item := range items {
+		rows[i] = row{item: item, values: make([]any, len(keys))}
+		for j, key := range keys {
+			// a missing field sorts like nil
+			rows[i].values[j], _ = resolveField(item, key.Field)
+		}
+	}
+	slices.SortStableFunc(rows, func(a, b row) int {
+		for j, key := range keys {
+			if c := compareSortValues(a.values[j], b.values[j], key); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	for i, row := range rows {
+		items[i] = row.item
+	}
+}
+
+func compareSortValues(a, b any, key SortKey) int {
+	if a == nil || b == nil {
+		if a == nil && b == nil {
+			return 0
+		}
+		c := 1
+		if b == nil {
+			c = -1
+		}
+		// nil is the largest value unless the key places it explicitly
+		switch {
+		case key.Nulls == NullsFirst:
+			return -c
+		case key.Nulls == NullsLast:
+			return c
+		}
+		if key.Desc {
+			return -c
+		}
+		return c
+	}
+	c := utils.Compare(a, b)
+	if key.Desc {
+		return -c
+	}
+	return c
+}
+
+// Project copies the given fields of item into a new map. Dotted fields
+// are nested as in the source, and fields item does not have are left out.
+func Project(item any, fields []string) map[string]any {
+	projected := make(map[string]any, len(fields))
+	for _, field := range fields {
+		value, err := resolveField(item, field)
+		if err != nil {
+			continue
+		}
+		parts := strings.Split(field, ".")
+		target := projected
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := target[part].(map[string]any)
+			if !ok {
+				next = map[string]any{}
+				target[part] = next
+			}
+			target = next
+		}
+		target[parts[len(parts)-1]] = value
+	}
+	return projected
+}
@@ -0,0 +1,85 @@
+package filters_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/oarkflow/filters"
+)
+
+func TestParseListQuery(t *testing.T) {
+	query, err := filters.ParseListQuery("?age:gt:20&sort=-city:nullsfirst,+name&limit=2&offset=1&fields=name,age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSort := []filters.SortKey{{Field: "city", Desc: true, Nulls: filters.NullsFirst}, {Field: "name"}}
+	if !reflect.DeepEqual(query.Sort, wantSort) {
+		t.Errorf("sort: got %+v, want %+v", query.Sort, wantSort)
+	}
+	if query.Limit != 2 || query.Offset != 1 {
+		t.Errorf("page: got limit %d, offset %d", query.Limit, query.Offset)
+	}
+	if !reflect.DeepEqual(query.Fields, []string{"name", "age"}) {
+		t.Errorf("fields: got %v", query.Fields)
+	}
+	if len(query.Filter.Filters) != 1 {
+		t.Errorf("only age should be a filter, got %d filters", len(query.Filter.Filters))
+	}
+	for _, bad := range []string{"limit=-1", "offset=x", "sort=name:sideways", "sort=-"} {
+		if _, err := filters.ParseListQuery(bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestApplyQuery(t *testing.T) {
+	records := []map[string]any{
+		{"name": "Ann", "age": 41, "score": 2.5, "city": "Paris"},
+		{"name": "Bob", "age": 35, "score": 10.0},
+		{"name": "Cid", "age": 19, "score": 7.25, "city": "Oslo"},
+		{"name": "Dee", "age": 35, "score": 1.0, "city": nil},
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"sort=score", []string{"Dee", "Ann", "Cid", "Bob"}},
+		{"sort=-age,name", []string{"Ann", "Bob", "Dee", "Cid"}},
+		{"sort=city", []string{"Cid", "Ann", "Bob", "Dee"}},
+		{"sort=city:nullsfirst", []string{"Bob", "Dee", "Cid", "Ann"}},
+		{"sort=-city", []string{"Bob", "Dee", "Ann", "Cid"}},
+		{"age:ge:20&sort=name&offset=1&limit=1", []string{"Bob"}},
+		{"sort=name&offset=10", []string{}},
+	}
+	for _, test := range tests {
+		query, err := filters.ParseListQuery(test.query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		got := []string{}
+		for _, record := range filters.ApplyQuery(records, query) {
+			got = append(got, record["name"].(string))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.query, got, test.want)
+		}
+	}
+	if records[0]["name"] != "Ann" {
+		t.Errorf("ApplyQuery should not reorder its input")
+	}
+}
+
+func TestApplyQueryProjects(t *testing.T) {
+	records := []map[string]any{
+		{"id": 1, "name": "Ann", "address": map[string]any{"city": "Paris", "zip": "75001"}},
+	}
+	got := filters.ApplyQuery(records, filters.Query{Fields: []string{"id", "address.city", "missing"}})
+	want := []map[string]any{{"id": 1, "address": map[string]any{"city": "Paris"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	type person struct{ Name string }
+	if got := filters.Project(person{Name: "Ann"}, []string{"Name"}); got["Name"] != "Ann" {
+		t.Errorf("struct projection: got %v", got)
+	}
+}
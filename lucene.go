@@ -0,0 +1,471 @@
+package filters
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The Lucene query grammar, from lowest to highest precedence:
+//
+//	or     = and { ("OR" | "||") and }
+//	and    = clause { ("AND" | "&&") clause }
+//	clause = { "-" | "!" | "+" | "NOT" } ( "(" or ")" | [field ":"] value )
+//	value  = term | "phrase" | "/" regexp "/" | range | (">" | ">=" | "<" | "<=") term | "(" or ")"
+//	range  = ("[" | "{") term "TO" term ("]" | "}")
+//
+// Clauses without an operator between them are joined with
+// LuceneOptions.DefaultOperator.
+
+// LuceneOptions configures ParseLucene.
+type LuceneOptions struct {
+	// DefaultFields are searched by terms without a field; such a term
+	// matches when it matches any of them.
+	DefaultFields []string
+	// DefaultOperator joins adjacent clauses. It defaults to AND.
+	DefaultOperator Boolean
+}
+
+// ParseLucene converts a Lucene/Kibana-style search such as
+// `status:ACTIVE AND age:[18 TO 30] -city:"New York" name:jan*` into a
+// condition. field:value and quoted phrases use Equal, [a TO b] ranges use
+// Between and {a TO b} ranges the exclusive comparisons, with * for an open
+// end. Terms with * at the ends use StartsWith, EndsWith or Contains; other
+// wildcards, including ?, become an anchored case-insensitive Pattern, and a
+// /regexp/ term an anchored case-sensitive one. A leading - or ! and NOT
+// reverse a clause, and _exists_:field checks that the field is not null.
+func ParseLucene(query string, opts ...LuceneOptions) (Condition, error) {
+	var options LuceneOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	p := &luceneParser{source: query, options: options, fields: options.DefaultFields}
+	condition, err := p.parseOr()
+	if err == nil && p.skipWhitespace() < len(query) {
+		err = p.errorAt(p.pos, p.pos+1, "AND", "OR")
+	}
+	if err != nil {
+		return nil, SyntaxErrors{err}
+	}
+	return condition, nil
+}
+
+type luceneParser struct {
+	source  string
+	pos     int
+	options LuceneOptions
+	// fields are searched by bare terms: the default fields, or the field
+	// of an enclosing field:(...) group.
+	fields []string
+}
+
+// luceneTerm is a single word of the query with its escapes removed.
+type luceneTerm struct {
+	text     string
+	pos, end int
+	// pattern is text as a regular expression with * and ? as wildcards.
+	pattern string
+	// leading and trailing are set for a * at either end of the term;
+	// inner is set for any other unescaped wildcard.
+	leading, trailing, inner bool
+	quoted                   bool
+	// regexp is set for a /regexp/ term, whose text is the expression.
+	regexp bool
+}
+
+func (p *luceneParser) parseOr() (Condition, *SyntaxError) {
+	return p.parseSequence(OR, p.parseAnd, "OR", "||")
+}
+
+func (p *luceneParser) parseAnd() (Condition, *SyntaxError) {
+	return p.parseSequence(AND, p.parseClause, "AND", "&&")
+}
+
+func (p *luceneParser) parseSequence(operator Boolean, operand func() (Condition, *SyntaxError), keywords ...string) (Condition, *SyntaxError) {
+	implicit := p.options.DefaultOperator == operator || (operator == AND && p.options.DefaultOperator == "")
+	var conditions []Condition
+	for {
+		condition, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		if !p.acceptKeyword(keywords...) && !(implicit && p.atClause()) {
+			break
+		}
+	}
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	return NewFilterGroup(operator, false, conditions...), nil
+}
+
+func (p *luceneParser) parseClause() (Condition, *SyntaxError) {
+	reverse := false
+	for {
+		start := p.skipWhitespace()
+		if start < len(p.source) && strings.IndexByte("-!+", p.source[start]) >= 0 {
+			reverse = reverse != (p.source[start] != '+')
+			p.pos++
+			continue
+		}
+		if p.acceptKeyword("NOT") {
+			reverse = !reverse
+			continue
+		}
+		break
+	}
+	condition, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if reverse {
+		return negate(condition), nil
+	}
+	return condition, nil
+}
+
+func (p *luceneParser) parsePrimary() (Condition, *SyntaxError) {
+	start := p.skipWhitespace()
+	if start >= len(p.source) {
+		return nil, p.errorAt(start, start, "term")
+	}
+	switch p.source[start] {
+	case '(':
+		return p.parseGroup(p.fields)
+	case '"':
+		phrase, err := p.phrase()
+		if err != nil {
+			return nil, err
+		}
+		return p.onFields(phrase)
+	case '/':
+		term, err := p.regexpTerm()
+		if err != nil {
+			return nil, err
+		}
+		return p.onFields(term)
+	}
+	term, err := p.term("")
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.source) && p.source[p.pos] == ':' && !term.quoted {
+		p.pos++
+		return p.parseFieldValue(term.text)
+	}
+	return p.onFields(term)
+}
+
+// parseGroup parses a parenthesised query in which bare terms search fields.
+func (p *luceneParser) parseGroup(fields []string) (Condition, *SyntaxError) {
+	p.pos++
+	outer := p.fields
+	p.fields = fields
+	condition, err := p.parseOr()
+	p.fields = outer
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept(')') {
+		return nil, p.errorAt(p.pos, p.pos+1, "')'")
+	}
+	return condition, nil
+}
+
+func (p *luceneParser) parseFieldValue(field string) (Condition, *SyntaxError) {
+	if p.pos >= len(p.source) {
+		return nil, p.errorAt(p.pos, p.pos, "value")
+	}
+	switch c := p.source[p.pos]; {
+	case c == '(':
+		return p.parseGroup([]string{field})
+	case c == '"':
+		phrase, err := p.phrase()
+		if err != nil {
+			return nil, err
+		}
+		return termFilter(field, phrase), nil
+	case c == '/':
+		term, err := p.regexpTerm()
+		if err != nil {
+			return nil, err
+		}
+		return termFilter(field, term), nil
+	case c == '[' || c == '{':
+		return p.parseRange(field)
+	case c == '>' || c == '<':
+		symbol := p.source[p.pos : p.pos+1]
+		p.pos++
+		if p.pos < len(p.source) && p.source[p.pos] == '=' {
+			symbol += "="
+			p.pos++
+		}
+		term, err := p.term("")
+		if err != nil {
+			return nil, err
+		}
+		return NewFilter(field, toOperator(symbol), luceneValue(term.text)), nil
+	}
+	term, err := p.term("")
+	if err != nil {
+		return nil, err
+	}
+	if field == "_exists_" {
+		return NewFilter(term.text, NotNull, nil), nil
+	}
+	return termFilter(field, term), nil
+}
+
+func (p *luceneParser) parseRange(field string) (Condition, *SyntaxError) {
+	includeLower := p.source[p.pos] == '['
+	p.pos++
+	lower, err := p.term("]}")
+	if err != nil {
+		return nil, err
+	}
+	to, err := p.term("]}")
+	if err != nil {
+		return nil, err
+	}
+	if to.text != "TO" {
+		return nil, p.errorAt(to.pos, to.end, "TO")
+	}
+	upper, err := p.term("]}")
+	if err != nil {
+		return nil, err
+	}
+	if p.pos >= len(p.source) || (p.source[p.pos] != ']' && p.source[p.pos] != '}') {
+		return nil, p.errorAt(p.pos, p.pos+1, "']'", "'}'")
+	}
+	includeUpper := p.source[p.pos] == ']'
+	p.pos++
+
+	openLower, openUpper := lower.text == "*" && !lower.quoted, upper.text == "*" && !upper.quoted
+	var lowerFilter, upperFilter *Filter
+	if !openLower {
+		lowerFilter = NewFilter(field, GreaterThan, luceneValue(lower.text))
+		if includeLower {
+			lowerFilter.Operator = GreaterThanEqual
+		}
+	}
+	if !openUpper {
+		upperFilter = NewFilter(field, LessThan, luceneValue(upper.text))
+		if includeUpper {
+			upperFilter.Operator = LessThanEqual
+		}
+	}
+	switch {
+	case openLower && openUpper:
+		return NewFilter(field, NotNull, nil), nil
+	case openLower:
+		return upperFilter, nil
+	case openUpper:
+		return lowerFilter, nil
+	case includeLower && includeUpper:
+		return NewFilter(field, Between, []any{lowerFilter.Value, upperFilter.Value}), nil
+	}
+	return NewFilterGroup(AND, false, lowerFilter, upperFilter), nil
+}
+
+// onFields matches a term without a field against the fields in scope.
+func (p *luceneParser) onFields(term luceneTerm) (Condition, *SyntaxError) {
+	switch len(p.fields) {
+	case 0:
+		return nil, newSyntaxError(p.source, term.pos, term.end, fmt.Sprintf("term %q has no field and no default fields are set", term.text))
+	case 1:
+		return termFilter(p.fields[0], term), nil
+	}
+	conditions := make([]Condition, len(p.fields))
+	for i, field := range p.fields {
+		conditions[i] = termFilter(field, term)
+	}
+	return NewFilterGroup(OR, false, conditions...), nil
+}
+
+// termFilter matches field against a term, using the string operators or a
+// pattern for wildcards.
+func termFilter(field string, term luceneTerm) *Filter {
+	switch {
+	case term.quoted:
+		return NewFilter(field, Equal, term.text)
+	case term.regexp:
+		return NewFilter(field, Pattern, "^(?:"+term.text+")$")
+	case term.text == "*" && term.leading:
+		return NewFilter(field, NotNull, nil)
+	case term.inner:
+		return NewFilter(field, Pattern, "(?i)^"+term.pattern+"$")
+	case term.leading && term.trailing:
+		return NewFilter(field, Contains, term.text[1:len(term.text)-1])
+	case term.leading:
+		return NewFilter(field, EndsWith, term.text[1:])
+	case term.trailing:
+		return NewFilter(field, StartsWith, term.text[:len(term.text)-1])
+	}
+	return NewFilter(field, Equal, luceneValue(term.text))
+}
+
+// luceneValue types a bare term: numbers become numbers, anything else stays
+// a string.
+func luceneValue(text string) any {
+	if isNumber(text) {
+		if literal, err := numberLiteral(text); err == nil {
+			return literal
+		}
+	}
+	return text
+}
+
+func (p *luceneParser) phrase() (luceneTerm, *SyntaxError) {
+	start := p.pos
+	var b strings.Builder
+	for i := start + 1; i < len(p.source); i++ {
+		switch p.source[i] {
+		case '\\':
+			if i+1 < len(p.source) {
+				i++
+				b.WriteByte(p.source[i])
+			}
+		case '"':
+			p.pos = i + 1
+			return luceneTerm{text: b.String(), pos: start, end: p.pos, quoted: true}, nil
+		default:
+			b.WriteByte(p.source[i])
+		}
+	}
+	return luceneTerm{}, newSyntaxError(p.source, start, len(p.source), "unclosed phrase")
+}
+
+// regexpTerm reads a /regexp/ term, in which \/ stands for a slash.
+func (p *luceneParser) regexpTerm() (luceneTerm, *SyntaxError) {
+	start := p.pos
+	var b strings.Builder
+	for i := start + 1; i < len(p.source); i++ {
+		switch p.source[i] {
+		case '\\':
+			if i+1 < len(p.source) {
+				i++
+				if p.source[i] != '/' {
+					b.WriteByte('\\')
+				}
+			}
+			b.WriteByte(p.source[i])
+		case '/':
+			p.pos = i + 1
+			if _, err := regexp.Compile(b.String()); err != nil {
+				return luceneTerm{}, newSyntaxError(p.source, start, p.pos, "invalid regular expression: "+err.Error())
+			}
+			return luceneTerm{text: b.String(), pos: start, end: p.pos, regexp: true}, nil
+		default:
+			b.WriteByte(p.source[i])
+		}
+	}
+	return luceneTerm{}, newSyntaxError(p.source, start, len(p.source), "unclosed regular expression")
+}
+
+// term reads a word up to whitespace, a special character or one of stop.
+// A quoted phrase is accepted wherever a term is.
+func (p *luceneParser) term(stop string) (luceneTerm, *SyntaxError) {
+	start := p.skipWhitespace()
+	if start < len(p.source) && p.source[start] == '"' {
+		return p.phrase()
+	}
+	var text, pattern strings.Builder
+	term := luceneTerm{pos: start}
+	for p.pos < len(p.source) {
+		c := p.source[p.pos]
+		if isWhitespace(c) || strings.IndexByte(`():"[]{}^~`+stop, c) >= 0 {
+			break
+		}
+		switch {
+		case c == '\\' && p.pos+1 < len(p.source):
+			p.pos++
+			c = p.source[p.pos]
+			pattern.WriteString(regexp.QuoteMeta(string(c)))
+		case c == '*':
+			pattern.WriteString(".*")
+			switch {
+			case p.pos == start:
+				term.leading = true
+			case p.pos+1 == len(p.source) || isWhitespace(p.source[p.pos+1]) || strings.IndexByte(`():"[]{}^~`+stop, p.source[p.pos+1]) >= 0:
+				term.trailing = true
+			default:
+				term.inner = true
+			}
+		case c == '?':
+			pattern.WriteString(".")
+			term.inner = true
+		default:
+			pattern.WriteString(regexp.QuoteMeta(string(c)))
+		}
+		text.WriteByte(c)
+		p.pos++
+	}
+	term.text, term.pattern, term.end = text.String(), pattern.String(), p.pos
+	if p.pos < len(p.source) && (p.source[p.pos] == '^' || p.source[p.pos] == '~') {
+		return luceneTerm{}, newSyntaxError(p.source, p.pos, p.pos+1, "boosts and fuzzy or proximity searches are not supported")
+	}
+	if term.text == "" {
+		return luceneTerm{}, p.errorAt(start, start+1, "term")
+	}
+	return term, nil
+}
+
+// atClause reports whether another clause follows without an operator.
+func (p *luceneParser) atClause() bool {
+	start := p.skipWhitespace()
+	if start >= len(p.source) || p.source[start] == ')' {
+		return false
+	}
+	rest := p.source[start:]
+	for _, keyword := range []string{"AND", "OR", "&&", "||"} {
+		if p.isKeyword(rest, keyword) {
+			return false
+		}
+	}
+	return true
+}
+
+// acceptKeyword consumes the first of keywords found at the current position.
+func (p *luceneParser) acceptKeyword(keywords ...string) bool {
+	start := p.skipWhitespace()
+	for _, keyword := range keywords {
+		if p.isKeyword(p.source[start:], keyword) {
+			p.pos += len(keyword)
+			return true
+		}
+	}
+	return false
+}
+
+// isKeyword reports whether s starts with keyword as a separate word.
+func (p *luceneParser) isKeyword(s, keyword string) bool {
+	if !strings.HasPrefix(s, keyword) {
+		return false
+	}
+	if keyword == "&&" || keyword == "||" || len(s) == len(keyword) {
+		return true
+	}
+	next := s[len(keyword)]
+	return isWhitespace(next) || next == '('
+}
+
+func (p *luceneParser) accept(c byte) bool {
+	if p.skipWhitespace() < len(p.source) && p.source[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *luceneParser) skipWhitespace() int {
+	p.pos = skipWhitespace(p.source, p.pos)
+	return p.pos
+}
+
+// errorAt reports the unexpected input at source[pos:end], or the end of the
+// input.
+func (p *luceneParser) errorAt(pos, end int, expected ...string) *SyntaxError {
+	pos, end = min(pos, len(p.source)), min(end, len(p.source))
+	return newSyntaxError(p.source, pos, end, "", expected...)
+}
@@ -0,0 +1,87 @@
+package filters_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/oarkflow/filters"
+)
+
+func TestParseLucene(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []bool
+	}{
+		{`age:[25 TO 30] AND -city:"New York"`, []bool{false, true, false}},
+		{`age:{25 TO 35]`, []bool{true, false, true}},
+		{`age:[30 TO *]`, []bool{true, false, true}},
+		{`age:>=30 name:*son`, []bool{false, false, true}},
+		{`name:jan* OR name:*doe`, []bool{true, true, false}},
+		{`name:J?hn*`, []bool{true, false, false}},
+		{`NOT city:(Chicago OR "Los Angeles")`, []bool{true, false, true}},
+		{`!_exists_:city`, []bool{false, false, true}},
+		{`city:* && tags:c`, []bool{false, false, false}},
+		{`(age:25 || age:35) +name:*o*`, []bool{false, false, true}},
+		{`name:/J.*h/`, []bool{false, true, false}},
+		{`name:/jo.*/`, []bool{false, false, false}},
+		{`name:/J[a-z]+ (Doe|Smith)/ -name:/Jane\/? Smith/`, []bool{true, false, false}},
+	}
+	for _, test := range tests {
+		condition, err := filters.ParseLucene(test.query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		for i, person := range people {
+			if got := condition.Match(person); got != test.want[i] {
+				t.Errorf("%s: record %d: got %v, want %v", test.query, i, got, test.want[i])
+			}
+		}
+	}
+}
+
+func TestParseLuceneDefaultFields(t *testing.T) {
+	options := filters.LuceneOptions{DefaultFields: []string{"name", "city"}, DefaultOperator: filters.OR}
+	condition, err := filters.ParseLucene(`*angeles bob*`, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []bool{false, true, true}
+	for i, person := range people {
+		if got := condition.Match(person); got != want[i] {
+			t.Errorf("record %d: got %v, want %v", i, got, want[i])
+		}
+	}
+	if condition, err = filters.ParseLucene(`/.*Angeles/`, options); err != nil || !condition.Match(people[1]) {
+		t.Errorf("expected a bare regular expression to search the default fields: %v", err)
+	}
+	if _, err := filters.ParseLucene(`jane`); err == nil {
+		t.Error("expected an error for a bare term without default fields")
+	}
+}
+
+func TestParseLuceneSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		query  string
+		column int
+	}{
+		{`age:[1 TO 5`, 12},
+		{`age:[1 5]`, 8},
+		{`name:"open`, 6},
+		{`name:jane~2`, 10},
+		{`(age:5`, 7},
+		{`age:5 AND`, 10},
+		{`name:/open`, 6},
+		{`name:/a(/`, 6},
+	}
+	for _, test := range tests {
+		_, err := filters.ParseLucene(test.query)
+		var syntaxErr *filters.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%s: expected a SyntaxError, got %v", test.query, err)
+			continue
+		}
+		if syntaxErr.Column != test.column {
+			t.Errorf("%s: column = %d, want %d (%v)", test.query, syntaxErr.Column, test.column, err)
+		}
+	}
+}
@@ -0,0 +1,546 @@
+package filters
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// SchemaVersion is the version of the serialized condition tree format.
+// Documents without a version are read as the current version.
+const SchemaVersion = 1
+
+// ConditionToMap converts a condition tree into its canonical document form.
+// Every node carries a "type" discriminator so the tree can be rebuilt with
+// ConditionFromMap.
+func ConditionToMap(condition Condition) (map[string]any, error) {
+	switch c := condition.(type) {
+	case nil:
+		return nil, errors.New("condition cannot be nil")
+	case CompiledCondition:
+		return ConditionToMap(c.Source())
+	case *Filter:
+		return filterToMap(c)
+	case *FilterGroup:
+		filters := make([]any, 0, len(c.Filters))
+		for _, filter := range c.Filters {
+			m, err := ConditionToMap(filter)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, m)
+		}
+		return map[string]any{
+			"type":     NodeGroup,
+			"operator": c.Operator,
+			"filters":  filters,
+			"reverse":  c.Reverse,
+		}, nil
+	case *Rule:
+		m := map[string]any{
+			"type":      NodeRule,
+			"operator":  c.Operator,
+			"reverse":   c.Reverse,
+			"result":    c.Result,
+			"condition": c.Condition,
+		}
+		for key, child := range map[string]Condition{"node": c.Node, "next": c.Next} {
+			if child == nil {
+				continue
+			}
+			cm, err := ConditionToMap(child)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = cm
+		}
+		if c.errorResponse != (ErrorResponse{}) {
+			m["error_response"] = map[string]any{
+				"error_msg":    c.errorResponse.ErrorMsg,
+				"error_action": c.errorResponse.ErrorAction,
+			}
+		}
+		return m, nil
+	case *Join:
+		m := map[string]any{
+			"type":     NodeJoin,
+			"operator": c.Operator,
+			"reverse":  c.Reverse,
+		}
+		for key, child := range map[string]*FilterGroup{"left": c.Left, "right": c.Right} {
+			if child == nil {
+				continue
+			}
+			cm, err := ConditionToMap(child)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = cm
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("cannot serialize condition of type %T", condition)
+	}
+}
+
+func filterToMap(filter *Filter) (map[string]any, error) {
+	value := filter.Value
+	if condition, ok := value.(Condition); ok {
+		cm, err := ConditionToMap(condition)
+		if err != nil {
+			return nil, err
+		}
+		value = cm
+	}
+	m := map[string]any{
+		"type":     NodeFilter,
+		"key":      filter.Key,
+		"field":    filter.Field,
+		"operator": filter.Operator,
+		"value":    encodeValue(value),
+		"reverse":  filter.Reverse,
+	}
+	if filter.FilterKey != "" {
+		m["filter_key"] = filter.FilterKey
+	}
+	if filter.Lookup != nil {
+		m["lookup"] = map[string]any{
+			"data":              encodeValue(filter.Lookup.Data),
+			"type":              filter.Lookup.Type,
+			"source":            filter.Lookup.Source,
+			"condition":         filter.Lookup.Condition,
+			"handler_condition": filter.Lookup.HandlerCondition,
+		}
+	}
+	return m, nil
+}
+
+// ConditionFromMap rebuilds a condition tree from its document form. Nodes
+// without a "type" are read as filters.
+func ConditionFromMap(m map[string]any) (Condition, error) {
+	if err := checkVersion(m); err != nil {
+		return nil, err
+	}
+	d := document(m)
+	switch NodeType(d.string("type")) {
+	case NodeFilter, "":
+		return orNil(d.filter())
+	case NodeGroup:
+		return orNil(d.group())
+	case NodeRule:
+		return orNil(d.rule())
+	case NodeJoin:
+		return orNil(d.join())
+	default:
+		return nil, fmt.Errorf("unknown condition type %q", d.string("type"))
+	}
+}
+
+// orNil returns a literal nil Condition rather than a typed nil when decoding
+// failed.
+func orNil[T Condition](condition T, err error) (Condition, error) {
+	if err != nil {
+		return nil, err
+	}
+	return condition, nil
+}
+
+// UnmarshalCondition decodes a JSON condition tree of any node type.
+func UnmarshalCondition(data []byte) (Condition, error) {
+	m, err := decodeDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	return ConditionFromMap(m)
+}
+
+func checkVersion(m map[string]any) error {
+	version, ok := m["version"]
+	if !ok {
+		return nil
+	}
+	v, ok := normalizeValue(version).(int)
+	if !ok || v < 1 || v > SchemaVersion {
+		return fmt.Errorf("unsupported schema version %v", version)
+	}
+	return nil
+}
+
+func decodeDocument(data []byte) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var m map[string]any
+	if err := decoder.Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// versioned marshals condition as a root document carrying the schema version.
+func versioned(condition Condition) (map[string]any, error) {
+	m, err := ConditionToMap(condition)
+	if err != nil {
+		return nil, err
+	}
+	m["version"] = SchemaVersion
+	return m, nil
+}
+
+type document map[string]any
+
+func (d document) string(key string) string {
+	s, _ := d[key].(string)
+	return s
+}
+
+func (d document) bool(key string) bool {
+	b, _ := d[key].(bool)
+	return b
+}
+
+func (d document) child(key string) (Condition, error) {
+	raw, ok := d[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	m, ok := toStringMap(raw)
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object", key)
+	}
+	return ConditionFromMap(m)
+}
+
+func (d document) filter() (*Filter, error) {
+	filter := &Filter{
+		Key:       d.string("key"),
+		FilterKey: d.string("filter_key"),
+		Field:     d.string("field"),
+		Operator:  Operator(d.string("operator")),
+		Value:     normalizeValue(d["value"]),
+		Reverse:   d.bool("reverse"),
+	}
+	if m, ok := toStringMap(d["value"]); ok && isQuantifier(filter.Operator) {
+		condition, err := ConditionFromMap(m)
+		if err != nil {
+			return nil, fmt.Errorf("value: %w", err)
+		}
+		filter.Value = condition
+	}
+	if raw, ok := d["lookup"]; ok && raw != nil {
+		m, ok := toStringMap(raw)
+		if !ok {
+			return nil, errors.New("lookup must be an object")
+		}
+		lookup := document(m)
+		filter.Lookup = &Lookup{
+			Data:             normalizeValue(lookup["data"]),
+			Type:             lookup.string("type"),
+			Source:           lookup.string("source"),
+			Condition:        lookup.string("condition"),
+			HandlerCondition: lookup.string("handler_condition"),
+		}
+	}
+	return filter, nil
+}
+
+func (d document) group() (*FilterGroup, error) {
+	group := &FilterGroup{Operator: Boolean(d.string("operator")), Reverse: d.bool("reverse")}
+	raw, _ := d["filters"].([]any)
+	for i, item := range raw {
+		m, ok := toStringMap(item)
+		if !ok {
+			return nil, fmt.Errorf("filters[%d] must be an object", i)
+		}
+		condition, err := ConditionFromMap(m)
+		if err != nil {
+			return nil, fmt.Errorf("filters[%d]: %w", i, err)
+		}
+		group.Filters = append(group.Filters, condition)
+	}
+	return group, nil
+}
+
+func (d document) rule() (*Rule, error) {
+	rule := &Rule{
+		Operator:  Boolean(d.string("operator")),
+		Reverse:   d.bool("reverse"),
+		Result:    d.bool("result"),
+		Condition: d.string("condition"),
+	}
+	var err error
+	if rule.Node, err = d.child("node"); err != nil {
+		return nil, fmt.Errorf("node: %w", err)
+	}
+	if rule.Node == nil {
+		return nil, errors.New("rule must have a node")
+	}
+	if rule.Next, err = d.child("next"); err != nil {
+		return nil, fmt.Errorf("next: %w", err)
+	}
+	if m, ok := toStringMap(d["error_response"]); ok {
+		response := document(m)
+		rule.SetErrorResponse(response.string("error_msg"), response.string("error_action"))
+	}
+	return rule, nil
+}
+
+func (d document) join() (*Join, error) {
+	join := &Join{Operator: Boolean(d.string("operator")), Reverse: d.bool("reverse")}
+	for key, target := range map[string]**FilterGroup{"left": &join.Left, "right": &join.Right} {
+		child, err := d.child(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		if child == nil {
+			return nil, fmt.Errorf("join must have a %s group", key)
+		}
+		group, ok := child.(*FilterGroup)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a group", key)
+		}
+		*target = group
+	}
+	return join, nil
+}
+
+// toStringMap accepts both JSON style and YAML v2 style maps.
+func toStringMap(v any) (map[string]any, bool) {
+	switch m := v.(type) {
+	case map[string]any:
+		return m, true
+	case map[any]any:
+		converted := make(map[string]any, len(m))
+		for key, val := range m {
+			converted[fmt.Sprint(key)] = val
+		}
+		return converted, true
+	}
+	return nil, false
+}
+
+// Values JSON has no type for are stored as objects with a single tag key,
+// so that they reload with their original Go type.
+const (
+	decimalTag  = "$decimal"
+	timeTag     = "$time"
+	durationTag = "$duration"
+)
+
+// encodeValue tags the decimal, time and duration values in v.
+func encodeValue(v any) any {
+	switch val := v.(type) {
+	case json.Number:
+		return map[string]any{decimalTag: val.String()}
+	case time.Time:
+		return map[string]any{timeTag: val.Format(time.RFC3339Nano)}
+	case time.Duration:
+		return map[string]any{durationTag: val.String()}
+	case []any:
+		encoded := make([]any, len(val))
+		for i, item := range val {
+			encoded[i] = encodeValue(item)
+		}
+		return encoded
+	}
+	return v
+}
+
+// typedValue decodes an object written by encodeValue.
+func typedValue(m map[string]any) (any, bool) {
+	if len(m) != 1 {
+		return nil, false
+	}
+	for tag, raw := range m {
+		s, ok := raw.(string)
+		if !ok {
+			return nil, false
+		}
+		switch tag {
+		case decimalTag:
+			return json.Number(s), true
+		case timeTag:
+			t, err := time.Parse(time.RFC3339Nano, s)
+			return t, err == nil
+		case durationTag:
+			d, err := time.ParseDuration(s)
+			return d, err == nil
+		}
+	}
+	return nil, false
+}
+
+// normalizeValue converts decoded numbers back to int or float64, restores
+// tagged values and normalizes nested maps, so reloaded values compare like
+// the originals.
+func normalizeValue(v any) any {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil && i >= math.MinInt && i <= math.MaxInt {
+			return int(i)
+		}
+		if f, err := val.Float64(); err == nil {
+			return f
+		}
+		return val
+	case []any:
+		normalized := make([]any, len(val))
+		for i, item := range val {
+			normalized[i] = normalizeValue(item)
+		}
+		return normalized
+	case map[string]any, map[any]any:
+		m, _ := toStringMap(val)
+		if typed, ok := typedValue(m); ok {
+			return typed
+		}
+		normalized := make(map[string]any, len(m))
+		for key, item := range m {
+			normalized[key] = normalizeValue(item)
+		}
+		return normalized
+	}
+	return v
+}
+
+func (filter *Filter) MarshalJSON() ([]byte, error) {
+	m, err := filterToMap(filter)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+func (filter *Filter) UnmarshalJSON(data []byte) error {
+	m, err := decodeDocument(data)
+	if err != nil {
+		return err
+	}
+	decoded, err := document(m).filter()
+	if err != nil {
+		return err
+	}
+	*filter = *decoded
+	return nil
+}
+
+func (group *FilterGroup) MarshalJSON() ([]byte, error) {
+	return marshalVersioned(group)
+}
+
+func (group *FilterGroup) UnmarshalJSON(data []byte) error {
+	return unmarshalInto(data, group)
+}
+
+func (r *Rule) MarshalJSON() ([]byte, error) {
+	return marshalVersioned(r)
+}
+
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	return unmarshalInto(data, r)
+}
+
+func (join *Join) MarshalJSON() ([]byte, error) {
+	return marshalVersioned(join)
+}
+
+func (join *Join) UnmarshalJSON(data []byte) error {
+	return unmarshalInto(data, join)
+}
+
+func (filter *Filter) MarshalYAML() (any, error) {
+	return filterToMap(filter)
+}
+
+func (filter *Filter) UnmarshalYAML(unmarshal func(any) error) error {
+	return unmarshalYAMLInto(unmarshal, filter)
+}
+
+func (group *FilterGroup) MarshalYAML() (any, error) {
+	return versioned(group)
+}
+
+func (group *FilterGroup) UnmarshalYAML(unmarshal func(any) error) error {
+	return unmarshalYAMLInto(unmarshal, group)
+}
+
+func (r *Rule) MarshalYAML() (any, error) {
+	return versioned(r)
+}
+
+func (r *Rule) UnmarshalYAML(unmarshal func(any) error) error {
+	return unmarshalYAMLInto(unmarshal, r)
+}
+
+func (join *Join) MarshalYAML() (any, error) {
+	return versioned(join)
+}
+
+func (join *Join) UnmarshalYAML(unmarshal func(any) error) error {
+	return unmarshalYAMLInto(unmarshal, join)
+}
+
+func marshalVersioned(condition Condition) ([]byte, error) {
+	m, err := versioned(condition)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+func unmarshalInto(data []byte, target Condition) error {
+	m, err := decodeDocument(data)
+	if err != nil {
+		return err
+	}
+	return assign(m, target)
+}
+
+func unmarshalYAMLInto(unmarshal func(any) error, target Condition) error {
+	var raw any
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	m, ok := toStringMap(raw)
+	if !ok {
+		return errors.New("condition must be a mapping")
+	}
+	return assign(m, target)
+}
+
+// assign decodes m and stores the result in target, which must be of the
+// same node type as the document.
+func assign(m map[string]any, target Condition) error {
+	if _, ok := target.(*Filter); ok && m["type"] == nil {
+		m["type"] = string(NodeFilter)
+	}
+	decoded, err := ConditionFromMap(m)
+	if err != nil {
+		return err
+	}
+	switch t := target.(type) {
+	case *Filter:
+		if d, ok := decoded.(*Filter); ok {
+			*t = *d
+			return nil
+		}
+	case *FilterGroup:
+		if d, ok := decoded.(*FilterGroup); ok {
+			*t = *d
+			return nil
+		}
+	case *Rule:
+		if d, ok := decoded.(*Rule); ok {
+			*t = *d
+			return nil
+		}
+	case *Join:
+		if d, ok := decoded.(*Join); ok {
+			*t = *d
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot decode %T document into %T", decoded, target)
+}
@@ -0,0 +1,180 @@
+package filters_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oarkflow/filters"
+)
+
+func TestConditionJSONRoundTrip(t *testing.T) {
+	parsed, err := filters.ParseSQL("SELECT * FROM users WHERE age > 26 AND city = 'New York'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested := filters.NewRule()
+	nested.AddCondition(filters.OR, true,
+		filters.NewFilterGroup(filters.AND, false,
+			filters.NewFilter("age", filters.In, []any{25, 30}),
+			&filters.Join{Operator: filters.OR,
+				Left:  filters.NewFilterGroup(filters.AND, false, filters.NewFilter("name", filters.StartsWith, "J")),
+				Right: filters.NewFilterGroup(filters.AND, false, filters.NewFilter("city", filters.IsNull, nil)),
+			},
+		),
+		filters.NewFilter("logged", filters.Between, []any{"{{created}}", "{{verified}}"}),
+	)
+	nested.SetErrorResponse("rejected", "deny")
+
+	for name, rule := range map[string]*filters.Rule{"sql": parsed, "nested": nested} {
+		data, err := json.Marshal(rule)
+		if err != nil {
+			t.Fatalf("%s: marshal: %v", name, err)
+		}
+		var decoded filters.Rule
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("%s: unmarshal: %v", name, err)
+		}
+		again, err := json.Marshal(&decoded)
+		if err != nil {
+			t.Fatalf("%s: marshal decoded: %v", name, err)
+		}
+		if string(again) != string(data) {
+			t.Errorf("%s: round trip changed document\n got: %s\nwant: %s", name, again, data)
+		}
+		for i, person := range people {
+			if got, want := decoded.Match(person), rule.Match(person); got != want {
+				t.Errorf("%s: record %d: decoded=%v original=%v", name, i, got, want)
+			}
+		}
+		condition, err := filters.UnmarshalCondition(data)
+		if err != nil {
+			t.Fatalf("%s: unmarshal condition: %v", name, err)
+		}
+		if _, ok := condition.(*filters.Rule); !ok {
+			t.Errorf("%s: got %T, want *filters.Rule", name, condition)
+		}
+	}
+}
+
+func TestFilterUnmarshalWithoutType(t *testing.T) {
+	var filter filters.Filter
+	if err := json.Unmarshal([]byte(`{"field":"age","operator":"in","value":[25,2.5]}`), &filter); err != nil {
+		t.Fatal(err)
+	}
+	if want := []any{25, 2.5}; !reflect.DeepEqual(filter.Value, want) {
+		t.Errorf("value = %#v, want %#v", filter.Value, want)
+	}
+	if _, err := filters.UnmarshalCondition([]byte(`{"type":"rule","version":99}`)); err == nil {
+		t.Error("expected unsupported version error")
+	}
+}
+
+func TestConditionRoundTripKeepsTypes(t *testing.T) {
+	rule, err := filters.ParseSQL("price = DECIMAL '12.50' AND day = DATE '2024-03-15' AND age BETWEEN 1.5 AND 9223372036854775808 AND ttl < INTERVAL '2 hours'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := filters.UnmarshalCondition(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := values(rule), values(decoded); !reflect.DeepEqual(got, want) {
+		t.Errorf("values = %#v, want %#v", got, want)
+	}
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	group := filters.NewFilterGroup(filters.AND, false,
+		filters.NewFilter("age", filters.Between, []any{18, 2.5}),
+		filters.NewFilter("price", filters.Equal, json.Number("12.50")),
+		filters.NewFilter("day", filters.Before, time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)),
+		filters.NewFilter("tags", filters.Any, filters.NewFilter("$", filters.Equal, "x")),
+	)
+	doc, err := group.MarshalYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded filters.FilterGroup
+	// a YAML v2 decoder hands over untyped maps keyed by any
+	unmarshal := func(target any) error {
+		*target.(*any) = yamlValue(doc)
+		return nil
+	}
+	if err := decoded.UnmarshalYAML(unmarshal); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := values(group), values(&decoded); !reflect.DeepEqual(got, want) {
+		t.Errorf("values = %#v, want %#v", got, want)
+	}
+	if _, ok := decoded.Filters[3].(*filters.Filter).Value.(*filters.Filter); !ok {
+		t.Errorf("quantifier value = %T, want *filters.Filter", decoded.Filters[3].(*filters.Filter).Value)
+	}
+}
+
+func TestUnmarshalIncompleteConditions(t *testing.T) {
+	for _, doc := range []string{
+		`{"type":"rule"}`,
+		`{"type":"join","operator":"AND","left":{"type":"group","operator":"AND"}}`,
+		`{"type":"group","filters":[{"type":"rule"}]}`,
+	} {
+		condition, err := filters.UnmarshalCondition([]byte(doc))
+		if err == nil {
+			t.Errorf("%s: expected an error", doc)
+		}
+		if condition != nil {
+			t.Errorf("%s: got %#v, want a nil condition", doc, condition)
+		}
+	}
+}
+
+// values collects the filter values of a tree, skipping nested conditions.
+func values(condition filters.Condition) []any {
+	switch c := condition.(type) {
+	case *filters.Filter:
+		if _, ok := c.Value.(filters.Condition); ok {
+			return nil
+		}
+		return []any{c.Value}
+	case *filters.FilterGroup:
+		var all []any
+		for _, filter := range c.Filters {
+			all = append(all, values(filter)...)
+		}
+		return all
+	case *filters.Rule:
+		all := values(c.Node)
+		if c.Next != nil {
+			all = append(all, values(c.Next)...)
+		}
+		return all
+	}
+	return nil
+}
+
+// yamlValue converts v to the generic form a YAML v2 decoder produces.
+func yamlValue(v any) any {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		m := map[any]any{}
+		for _, key := range rv.MapKeys() {
+			m[key.String()] = yamlValue(rv.MapIndex(key).Interface())
+		}
+		return m
+	case reflect.Slice:
+		s := make([]any, rv.Len())
+		for i := range s {
+			s[i] = yamlValue(rv.Index(i).Interface())
+		}
+		return s
+	case reflect.String:
+		return rv.String()
+	}
+	return v
+}
@@ -0,0 +1,441 @@
+package filters
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ParseMongo converts a MongoDB query document into a condition. Top-level
+// fields are combined with AND. Comparisons keep the semantics of this
+// library, so $eq on strings is case-insensitive when evaluated in memory.
+func ParseMongo(query map[string]any) (Condition, error) {
+	var conditions []Condition
+	for _, key := range sortedKeys(query) {
+		value := query[key]
+		var condition Condition
+		var err error
+		switch key {
+		case "$and", "$or", "$nor":
+			condition, err = parseMongoLogical(key, value)
+		default:
+			if strings.HasPrefix(key, "$") {
+				return nil, fmt.Errorf("unsupported top-level operator %q", key)
+			}
+			condition, err = parseMongoField(key, value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	return NewFilterGroup(AND, false, conditions...), nil
+}
+
+func parseMongoLogical(operator string, value any) (Condition, error) {
+	items, ok := value.([]any)
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("%s expects a non-empty array", operator)
+	}
+	conditions := make([]Condition, 0, len(items))
+	for i, item := range items {
+		doc, ok := toStringMap(item)
+		if !ok {
+			return nil, fmt.Errorf("%s[%d] must be a document", operator, i)
+		}
+		condition, err := ParseMongo(doc)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	switch operator {
+	case "$and":
+		return NewFilterGroup(AND, false, conditions...), nil
+	case "$or":
+		return NewFilterGroup(OR, false, conditions...), nil
+	}
+	return NewFilterGroup(OR, true, conditions...), nil
+}
+
+// parseMongoField converts the query for one field, which is either an
+// operator document or a value to compare with.
+func parseMongoField(field string, value any) (Condition, error) {
+	ops, ok := toStringMap(value)
+	if !ok || !isOperatorDocument(ops) {
+		value = normalizeValue(value)
+		if value == nil {
+			return NewFilter(field, IsNull, nil), nil
+		}
+		return NewFilter(field, Equal, value), nil
+	}
+	var conditions []Condition
+	for _, op := range sortedKeys(ops) {
+		if op == "$options" {
+			if _, ok := ops["$regex"]; !ok {
+				return nil, fmt.Errorf("$options without $regex on field %q", field)
+			}
+			continue
+		}
+		condition, err := parseMongoOperator(field, op, ops[op], ops)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", field, err)
+		}
+		conditions = append(conditions, condition)
+	}
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	return NewFilterGroup(AND, false, conditions...), nil
+}
+
+var mongoComparisons = map[string]Operator{
+	"$eq":  Equal,
+	"$ne":  NotEqual,
+	"$gt":  GreaterThan,
+	"$gte": GreaterThanEqual,
+	"$lt":  LessThan,
+	"$lte": LessThanEqual,
+	"$in":  In,
+	"$nin": NotIn,
+}
+
+func parseMongoOperator(field, op string, value any, ops map[string]any) (Condition, error) {
+	value = normalizeValue(value)
+	if operator, ok := mongoComparisons[op]; ok {
+		switch {
+		case value == nil && operator == Equal:
+			return NewFilter(field, IsNull, nil), nil
+		case value == nil && operator == NotEqual:
+			return NewFilter(field, NotNull, nil), nil
+		case operator == In || operator == NotIn:
+			if _, ok := value.([]any); !ok {
+				return nil, fmt.Errorf("%s expects an array", op)
+			}
+		}
+		return NewFilter(field, operator, value), nil
+	}
+	switch op {
+	case "$regex":
+		pattern, ok := value.(string)
+		if !ok {
+			return nil, errors.New("$regex expects a string")
+		}
+		options, _ := ops["$options"].(string)
+		if strings.Trim(options, "ims") != "" {
+			return nil, fmt.Errorf("unsupported $options %q", options)
+		}
+		if options != "" {
+			pattern = "(?" + options + ")" + pattern
+		}
+		return NewFilter(field, Pattern, pattern), nil
+	case "$exists":
+		exists, ok := value.(bool)
+		if !ok {
+			return nil, errors.New("$exists expects a boolean")
+		}
+		if exists {
+			return NewFilter(field, Exists, nil), nil
+		}
+		return NewFilter(field, NotExists, nil), nil
+	case "$size":
+		size, ok := value.(int)
+		if f, isFloat := value.(float64); isFloat && f == float64(int(f)) {
+			size, ok = int(f), true
+		}
+		if !ok {
+			return nil, errors.New("$size expects an integer")
+		}
+		return NewFilter(field, EqualCount, size), nil
+	case "$not":
+		inner, ok := toStringMap(value)
+		if !ok || !isOperatorDocument(inner) {
+			return nil, errors.New("$not expects an operator document")
+		}
+		condition, err := parseMongoField(field, inner)
+		if err != nil {
+			return nil, err
+		}
+		return negate(condition), nil
+	case "$elemMatch":
+		doc, ok := toStringMap(value)
+		if !ok {
+			return nil, errors.New("$elemMatch expects a document")
+		}
+		var condition Condition
+		var err error
+		if isOperatorDocument(doc) {
+			condition, err = parseMongoField(SelfField, doc)
+		} else {
+			condition, err = ParseMongo(doc)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return NewFilter(field, Any, condition), nil
+	}
+	return nil, fmt.Errorf("unsupported operator %q", op)
+}
+
+func isOperatorDocument(doc map[string]any) bool {
+	if len(doc) == 0 {
+		return false
+	}
+	for key := range doc {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+// ToMongo converts condition into a MongoDB query document, resolving
+// relative times with opts. Nodes that have no Mongo equivalent are reported
+// together in a *TranslateError.
+func ToMongo(condition Condition, opts ...TranslateOptions) (map[string]any, error) {
+	b := &mongoBuilder{clock: translateClock(opts)}
+	doc := b.condition(condition)
+	if len(b.unsupported) > 0 {
+		return nil, &TranslateError{Target: "mongo", Nodes: b.unsupported}
+	}
+	return doc, nil
+}
+
+type mongoBuilder struct {
+	// clock resolves relative times; it is nil without TranslateOptions.
+	clock       *clock
+	unsupported []Untranslatable
+}
+
+func (b *mongoBuilder) fail(condition Condition, reason string) map[string]any {
+	b.unsupported = append(b.unsupported, Untranslatable{Condition: condition, Reason: reason})
+	return map[string]any{}
+}
+
+func (b *mongoBuilder) condition(condition Condition) map[string]any {
+	switch c := condition.(type) {
+	case CompiledCondition:
+		return b.condition(c.Source())
+	case *Filter:
+		return b.filter(c)
+	case *FilterGroup:
+		if c.Operator != AND && c.Operator != OR {
+			return b.fail(c, fmt.Sprintf("unsupported boolean operator: %s", c.Operator))
+		}
+		return b.sequence(c.Operator, c.Reverse, c.Filters...)
+	case *Rule:
+		if c.Node == nil {
+			return b.fail(c, "rule has no condition")
+		}
+		if c.Next == nil {
+			return b.sequence(AND, c.Reverse, c.Node)
+		}
+		if c.Operator != AND && c.Operator != OR {
+			return b.fail(c, fmt.Sprintf("unsupported boolean operator: %s", c.Operator))
+		}
+		return b.sequence(c.Operator, c.Reverse, c.Node, c.Next)
+	case *Join:
+		if c.Left == nil || c.Right == nil {
+			return b.fail(c, "missing left or right filter group")
+		}
+		if c.Operator != AND && c.Operator != OR {
+			return b.fail(c, fmt.Sprintf("unsupported boolean operator: %s", c.Operator))
+		}
+		return b.sequence(c.Operator, c.Reverse, c.Left, c.Right)
+	case nil:
+		return b.fail(c, "condition is nil")
+	default:
+		return b.fail(c, "custom conditions cannot be translated")
+	}
+}
+
+func (b *mongoBuilder) sequence(operator Boolean, reverse bool, conditions ...Condition) map[string]any {
+	docs := make([]any, len(conditions))
+	for i, condition := range conditions {
+		docs[i] = b.condition(condition)
+	}
+	var doc map[string]any
+	switch {
+	case operator == OR && len(docs) == 0:
+		// an empty OR matches nothing
+		doc = map[string]any{"$nor": []any{map[string]any{}}}
+	case operator == OR && reverse:
+		return map[string]any{"$nor": docs}
+	case operator == OR && len(docs) == 1:
+		doc = docs[0].(map[string]any)
+	case operator == OR:
+		doc = map[string]any{"$or": docs}
+	default:
+		doc = mergeMongo(docs)
+	}
+	if reverse {
+		return map[string]any{"$nor": []any{doc}}
+	}
+	return doc
+}
+
+// mergeMongo combines documents that must all match into one document,
+// falling back to $and when they constrain the same operator of a field.
+func mergeMongo(docs []any) map[string]any {
+	merged := map[string]any{}
+	for _, d := range docs {
+		for key, value := range d.(map[string]any) {
+			existing, ok := merged[key]
+			if !ok {
+				merged[key] = value
+				continue
+			}
+			left, ok1 := existing.(map[string]any)
+			right, ok2 := value.(map[string]any)
+			if !ok1 || !ok2 || !isOperatorDocument(left) || !isOperatorDocument(right) {
+				return map[string]any{"$and": docs}
+			}
+			combined := make(map[string]any, len(left)+len(right))
+			for op, v := range left {
+				combined[op] = v
+			}
+			for op, v := range right {
+				if _, clash := combined[op]; clash {
+					return map[string]any{"$and": docs}
+				}
+				combined[op] = v
+			}
+			merged[key] = combined
+		}
+	}
+	return merged
+}
+
+var mongoOperators = map[Operator]string{
+	Equal:            "$eq",
+	NotEqual:         "$ne",
+	GreaterThan:      "$gt",
+	GreaterThanEqual: "$gte",
+	LessThan:         "$lt",
+	LessThanEqual:    "$lte",
+	In:               "$in",
+	NotIn:            "$nin",
+}
+
+func (b *mongoBuilder) filter(filter *Filter) map[string]any {
+	if err := filter.Validate(); err != nil {
+		return b.fail(filter, err.Error())
+	}
+	filter, ok := b.clock.translate(filter)
+	if !ok {
+		return b.fail(filter, untranslatableRelative)
+	}
+	if filter.Lookup != nil {
+		return b.fail(filter, "lookups cannot be translated")
+	}
+	if _, isRef := reference(filter.Field); isRef {
+		return b.fail(filter, "computed fields cannot be translated")
+	}
+	if hasReference(filter.Value) {
+		return b.fail(filter, "field references cannot be translated")
+	}
+	filter = asAny(filter)
+	ops, reverse := b.operators(filter)
+	if ops == nil {
+		return map[string]any{}
+	}
+	if reverse != filter.Reverse {
+		ops = map[string]any{"$not": ops}
+	}
+	return map[string]any{filter.Field: ops}
+}
+
+// operators returns the operator document for filter and whether it
+// expresses the negation of the filter's operator.
+func (b *mongoBuilder) operators(filter *Filter) (map[string]any, bool) {
+	if op, ok := mongoOperators[filter.Operator]; ok {
+		if op == "$in" || op == "$nin" {
+			return map[string]any{op: sqlValues(filter.Value)}, false
+		}
+		return map[string]any{op: filter.Value}, false
+	}
+	switch filter.Operator {
+	case Between:
+		values := sqlValues(filter.Value)
+		return map[string]any{"$gte": values[0], "$lte": values[1]}, false
+	case IsNull:
+		return map[string]any{"$eq": nil}, false
+	case NotNull:
+		return map[string]any{"$ne": nil}, false
+	case Exists, NotExists:
+		return map[string]any{"$exists": filter.Operator == Exists}, false
+	case IsZero:
+		return map[string]any{"$in": []any{0, "", false}}, false
+	case NotZero:
+		return map[string]any{"$nin": []any{0, "", false}}, false
+	case Pattern:
+		return map[string]any{"$regex": filter.Value}, false
+	case EqualCount, LenEqual:
+		return map[string]any{"$size": filter.Value}, false
+	case ContainsAll, SupersetOf:
+		return map[string]any{"$all": sqlValues(filter.Value)}, false
+	case ContainsAny:
+		return map[string]any{"$in": sqlValues(filter.Value)}, false
+	case Disjoint:
+		return map[string]any{"$nin": sqlValues(filter.Value)}, false
+	case NotEqualCount:
+		return map[string]any{"$size": filter.Value}, true
+	case Any:
+		inner := b.condition(filter.Value.(Condition))
+		if ops, ok := inner[SelfField]; ok && len(inner) == 1 {
+			return map[string]any{"$elemMatch": ops}, false
+		}
+		return map[string]any{"$elemMatch": inner}, false
+	}
+	if shape, ok := likeShapes[filter.Operator]; ok {
+		value, ok := filter.Value.(string)
+		if !ok {
+			b.fail(filter, "string operators need a string value")
+			return nil, false
+		}
+		pattern := regexp.QuoteMeta(value)
+		if !shape.prefix {
+			pattern = "^" + pattern
+		}
+		if !shape.suffix {
+			pattern += "$"
+		}
+		ops := map[string]any{"$regex": pattern}
+		if !shape.caseSensitive {
+			ops["$options"] = "i"
+		}
+		return ops, shape.negated
+	}
+	if _, ok := countOperatorSymbols[filter.Operator]; ok {
+		b.fail(filter, "only equality count operators map to $size")
+		return nil, false
+	}
+	b.fail(filter, "operator has no Mongo equivalent")
+	return nil, false
+}
+
+// hasReference reports whether value is, or contains, a {{...}} reference.
+func hasReference(value any) bool {
+	if s, ok := value.(string); ok {
+		_, isRef := reference(s)
+		return isRef
+	}
+	if _, ok := value.(Condition); ok {
+		return false
+	}
+	return slices.ContainsFunc(sqlValues(value), func(v any) bool {
+		s, ok := v.(string)
+		_, isRef := reference(s)
+		return ok && isRef
+	})
+}
+
+func sortedKeys(m map[string]any) []string {
+	return slices.Sorted(maps.Keys(m))
+}
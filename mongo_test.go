@@ -0,0 +1,120 @@
+package filters_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/oarkflow/filters"
+)
+
+func TestParseMongo(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []bool
+	}{
+		{`{"age": {"$gt": 25, "$lt": 35}}`, []bool{true, false, false}},
+		{`{"$or": [{"age": 25}, {"city": null}]}`, []bool{false, true, true}},
+		{`{"name": {"$regex": "^j", "$options": "i"}, "age": {"$in": [25, 30]}}`, []bool{true, true, false}},
+		{`{"$nor": [{"age": {"$gte": 30}}]}`, []bool{false, true, false}},
+		{`{"name": {"$not": {"$regex": "Smith$"}}}`, []bool{true, false, true}},
+		{`{"tags": {"$size": 2}}`, []bool{true, false, false}},
+		{`{"tags": {"$elemMatch": {"$eq": "c"}}}`, []bool{false, true, false}},
+		{`{"missing": {"$exists": false}, "age": {"$exists": true}}`, []bool{true, true, true}},
+		{`{"city": {"$exists": true}}`, []bool{true, true, true}},
+		{`{"city": {"$exists": false}}`, []bool{false, false, false}},
+		{`{"city": {"$ne": null}}`, []bool{true, true, false}},
+	}
+	for _, test := range tests {
+		var query map[string]any
+		if err := json.Unmarshal([]byte(test.query), &query); err != nil {
+			t.Fatal(err)
+		}
+		condition, err := filters.ParseMongo(query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		for i, person := range people {
+			if got := condition.Match(person); got != test.want[i] {
+				t.Errorf("%s: record %d: got %v, want %v", test.query, i, got, test.want[i])
+			}
+		}
+	}
+}
+
+func TestParseMongoElemMatchDocuments(t *testing.T) {
+	order := map[string]any{"lines": []any{
+		map[string]any{"sku": "A1", "qty": 1},
+		map[string]any{"sku": "B2", "qty": 5},
+	}}
+	var query map[string]any
+	_ = json.Unmarshal([]byte(`{"lines": {"$elemMatch": {"sku": {"$regex": "^B"}, "qty": {"$gt": 2}}}}`), &query)
+	condition, err := filters.ParseMongo(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !condition.Match(order) {
+		t.Error("expected a line to match")
+	}
+	exported, err := filters.ToMongo(condition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exported, query) {
+		t.Errorf("round trip: got %v, want %v", exported, query)
+	}
+}
+
+func TestToMongo(t *testing.T) {
+	condition := filters.NewFilterGroup(filters.AND, false,
+		filters.NewFilter("age", filters.Between, []any{18, 30}),
+		filters.NewFilter("age", filters.NotEqual, 21),
+		filters.NewFilter("name", filters.StartsWith, "J."),
+		&filters.Filter{Field: "city", Operator: filters.In, Value: []string{"x", "y"}, Reverse: true},
+		filters.NewFilterGroup(filters.OR, false,
+			filters.NewFilter("tags", filters.EqualCount, 0),
+			filters.NewFilter("tags", filters.NotContainsCS, "a"),
+		),
+	)
+	got, err := filters.ToMongo(condition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"age":  map[string]any{"$gte": 18, "$lte": 30, "$ne": 21},
+		"name": map[string]any{"$regex": `^J\.`, "$options": "i"},
+		"city": map[string]any{"$not": map[string]any{"$in": []any{"x", "y"}}},
+		"$or": []any{
+			map[string]any{"tags": map[string]any{"$size": 0}},
+			map[string]any{"tags": map[string]any{"$not": map[string]any{"$regex": "a"}}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+
+	presence := filters.NewFilterGroup(filters.AND, false,
+		filters.NewFilter("city", filters.Exists, nil),
+		filters.NewFilter("zip", filters.NotExists, nil),
+		filters.NewFilter("name", filters.NotNull, nil),
+	)
+	if got, err = filters.ToMongo(presence); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]any{
+		"city": map[string]any{"$exists": true},
+		"zip":  map[string]any{"$exists": false},
+		"name": map[string]any{"$ne": nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+	if matched, err := filters.MatchE(presence, people[2], filters.MatchOptions{Mode: filters.Strict}); err != nil || !matched {
+		t.Errorf("presence checks in strict mode: got %v, %v", matched, err)
+	}
+
+	_, err = filters.ToMongo(filters.NewFilter("name", filters.Expression, "age > 1"))
+	if err == nil {
+		t.Error("expected a translate error")
+	}
+}
@@ -0,0 +1,486 @@
+package filters
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The OData $filter grammar, from lowest to highest precedence:
+//
+//	or         = and { "or" and }
+//	and        = not { "and" not }
+//	not        = "not" not | primary
+//	primary    = "(" or ")" | comparison
+//	comparison = operand [operator operand | "in" "(" value { "," value } ")"]
+//	operand    = path | literal | function "(" args ")" | path "/" ("any" | "all") "(" [var ":" or] ")"
+//
+// Paths use "/" as the separator and are converted to dotted fields.
+
+var odataOperators = map[string]Operator{
+	"eq": Equal,
+	"ne": NotEqual,
+	"gt": GreaterThan,
+	"ge": GreaterThanEqual,
+	"lt": LessThan,
+	"le": LessThanEqual,
+}
+
+var odataKeywords = map[string]bool{
+	"eq": true, "ne": true, "gt": true, "ge": true, "lt": true, "le": true,
+	"and": true, "or": true, "not": true, "in": true, "null": true,
+}
+
+// odataFunctions maps the string functions onto case-sensitive operators and
+// their case-insensitive counterparts, used when the property is wrapped in
+// tolower or toupper.
+var odataFunctions = map[string][2]Operator{
+	"contains":   {ContainsCS, Contains},
+	"startswith": {StartsWithCS, StartsWith},
+	"endswith":   {EndsWithCS, EndsWith},
+}
+
+// ParseOData converts an OData $filter expression such as
+// "Price gt 20 and startswith(Name,'Ja')" into a condition. Comparisons keep
+// the semantics of this library, so eq on strings is case-insensitive, while
+// contains, startswith and endswith are case-sensitive unless the property
+// is wrapped in tolower or toupper. Inside a lambda such as
+// "Tags/any(t: t eq 'x')" the variable refers to the element, and a nested
+// lambda can use the variables of the lambdas around it; all is true for an
+// empty collection.
+func ParseOData(filter string) (Condition, error) {
+	tokens, err := tokenizeOData(filter)
+	if err != nil {
+		return nil, SyntaxErrors{err}
+	}
+	p := &odataParser{parser: parser{tokens: tokens, source: filter}}
+	condition, err := p.parseOr()
+	if err == nil {
+		if tok, ok := p.peekToken(); ok {
+			err = p.errorAt(tok, true, "and", "or")
+		}
+	}
+	if err != nil {
+		return nil, SyntaxErrors{err}
+	}
+	return condition, nil
+}
+
+func tokenizeOData(input string) ([]token, *SyntaxError) {
+	var tokens []token
+	emit := func(typ tokenType, value string, literal any, pos, end int) {
+		tokens = append(tokens, token{typ: typ, value: value, literal: literal, pos: pos, end: end})
+	}
+	for i := 0; i < len(input); {
+		switch r := input[i]; {
+		case isWhitespace(r):
+			i++
+		case r == '(', r == ')', r == ',':
+			emit(runeToTokenType(r), string(r), nil, i, i+1)
+			i++
+		case r == ':':
+			emit(tokenColon, ":", nil, i, i+1)
+			i++
+		case r == '\'':
+			value, end, err := parseQuoted(input, i)
+			if err != nil {
+				return nil, newSyntaxError(input, i, len(input), "unclosed string literal")
+			}
+			emit(tokenValue, value, value, i, end)
+			i = end
+		case isDigit(r), r == '-' && i+1 < len(input) && isDigit(input[i+1]):
+			end := i + 1
+			for end < len(input) && strings.IndexByte("0123456789-+:.TZ", input[end]) >= 0 {
+				end++
+			}
+			if t, ok := odataTime(input[i:end]); ok {
+				emit(tokenValue, input[i:end], t, i, end)
+				i = end
+				continue
+			}
+			value, end := parseNumber(input, i)
+			literal, err := numberLiteral(value)
+			if err != nil {
+				return nil, newSyntaxError(input, i, end, "invalid number "+value)
+			}
+			emit(tokenValue, value, literal, i, end)
+			i = end
+		case isODataName(r):
+			end := i + 1
+			for end < len(input) && (isODataName(input[end]) || isDigit(input[end]) || input[end] == '/') {
+				end++
+			}
+			word := input[i:end]
+			switch lower := strings.ToLower(word); {
+			case lower == "true", lower == "false":
+				emit(tokenValue, word, lower == "true", i, end)
+			case odataKeywords[lower]:
+				emit(tokenKeyword, lower, nil, i, end)
+			default:
+				emit(tokenIdentifier, word, nil, i, end)
+			}
+			i = end
+		default:
+			return nil, newSyntaxError(input, i, i+1, fmt.Sprintf("unexpected character %q", r))
+		}
+	}
+	return tokens, nil
+}
+
+func isODataName(r byte) bool {
+	return r == '_' || r == '$' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// odataTime parses date and date-time literals such as 2024-01-31 and
+// 2024-01-31T10:00:00Z.
+func odataTime(s string) (time.Time, bool) {
+	for _, layout := range []string{time.DateOnly, time.RFC3339Nano} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+type odataParser struct {
+	parser
+	// variables are the lambda variables in scope, innermost last.
+	variables []string
+}
+
+// odataOperand is one side of a comparison: a property, a literal or a
+// boolean expression such as a function call or lambda.
+type odataOperand struct {
+	tok       token
+	field     string
+	value     any
+	condition Condition
+	// lower is set when the property is wrapped in tolower or toupper.
+	lower bool
+}
+
+func (p *odataParser) parseOr() (Condition, *SyntaxError) {
+	return p.parseSequence(OR, p.parseAnd)
+}
+
+func (p *odataParser) parseAnd() (Condition, *SyntaxError) {
+	return p.parseSequence(AND, p.parseNot)
+}
+
+func (p *odataParser) parseSequence(operator Boolean, operand func() (Condition, *SyntaxError)) (Condition, *SyntaxError) {
+	var conditions []Condition
+	for {
+		condition, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		if !p.accept(strings.ToLower(string(operator))) {
+			break
+		}
+	}
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	return NewFilterGroup(operator, false, conditions...), nil
+}
+
+func (p *odataParser) parseNot() (Condition, *SyntaxError) {
+	if !p.accept("not") {
+		return p.parsePrimary()
+	}
+	condition, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	return negate(condition), nil
+}
+
+func (p *odataParser) parsePrimary() (Condition, *SyntaxError) {
+	if tok, ok := p.peekToken(); !ok || tok.typ != tokenLParen {
+		return p.parseComparison()
+	}
+	p.pos++
+	condition, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(tokenRParen, "')'"); err != nil {
+		return nil, err
+	}
+	return condition, nil
+}
+
+func (p *odataParser) parseComparison() (Condition, *SyntaxError) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if left.condition != nil {
+		return p.parseBooleanResult(left.condition)
+	}
+	tok, ok := p.peekToken()
+	if left.field != "" && (!ok || tok.typ == tokenRParen || (tok.typ == tokenKeyword && (tok.value == "and" || tok.value == "or"))) {
+		// a boolean property used on its own
+		return NewFilter(left.field, Equal, true), nil
+	}
+	p.pos++
+	if !ok || tok.typ != tokenKeyword {
+		return nil, p.errorAt(tok, ok, "comparison operator")
+	}
+	if tok.value == "in" {
+		if left.field == "" {
+			return nil, p.errorAt(left.tok, true, "property")
+		}
+		values, err := p.parseValues()
+		if err != nil {
+			return nil, err
+		}
+		return NewFilter(left.field, In, values), nil
+	}
+	operator, ok := odataOperators[tok.value]
+	if !ok {
+		return nil, p.errorAt(tok, true, "comparison operator")
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if right.condition != nil {
+		return nil, p.errorAt(right.tok, true, "value")
+	}
+	switch {
+	case left.field != "" && right.field != "":
+		value := right.field
+		if _, isRef := reference(value); !isRef {
+			value = "{{" + value + "}}"
+		}
+		return NewFilter(left.field, operator, value), nil
+	case left.field == "" && right.field == "":
+		return nil, p.errorAt(right.tok, true, "property")
+	case left.field == "":
+		left, right, operator = right, left, flipped[operator]
+	}
+	if right.value != nil {
+		return NewFilter(left.field, operator, right.value), nil
+	}
+	switch operator {
+	case Equal:
+		return NewFilter(left.field, IsNull, nil), nil
+	case NotEqual:
+		return NewFilter(left.field, NotNull, nil), nil
+	}
+	return nil, newSyntaxError(p.source, right.tok.pos, right.tok.end, "null can only be compared with eq or ne")
+}
+
+// parseBooleanResult accepts an optional "eq true", "eq false", "ne true" or
+// "ne false" after a function call or lambda.
+func (p *odataParser) parseBooleanResult(condition Condition) (Condition, *SyntaxError) {
+	tok, ok := p.peekToken()
+	if !ok || tok.typ != tokenKeyword || (tok.value != "eq" && tok.value != "ne") {
+		return condition, nil
+	}
+	p.pos++
+	value, ok := p.nextToken()
+	b, isBool := value.literal.(bool)
+	if !ok || !isBool {
+		return nil, p.errorAt(value, ok, "true", "false")
+	}
+	if b == (tok.value == "ne") {
+		return negate(condition), nil
+	}
+	return condition, nil
+}
+
+func (p *odataParser) parseOperand() (odataOperand, *SyntaxError) {
+	tok, ok := p.nextToken()
+	switch {
+	case !ok:
+	case tok.typ == tokenValue:
+		return odataOperand{tok: tok, value: tok.literal}, nil
+	case tok.typ == tokenKeyword && tok.value == "null":
+		return odataOperand{tok: tok}, nil
+	case tok.typ == tokenIdentifier:
+		if next, ok := p.peekToken(); ok && next.typ == tokenLParen {
+			p.pos++
+			return p.parseCall(tok)
+		}
+		field, err := p.field(tok, tok.value)
+		if err != nil {
+			return odataOperand{}, err
+		}
+		return odataOperand{tok: tok, field: field}, nil
+	}
+	return odataOperand{}, p.errorAt(tok, ok, "property", "value")
+}
+
+// parseCall parses the arguments of a function or lambda whose opening
+// parenthesis has been consumed.
+func (p *odataParser) parseCall(tok token) (odataOperand, *SyntaxError) {
+	if i := strings.LastIndexByte(tok.value, '/'); i > 0 {
+		if kind := tok.value[i+1:]; kind == "any" || kind == "all" {
+			condition, err := p.parseLambda(tok, tok.value[:i], kind)
+			return odataOperand{tok: tok, condition: condition}, err
+		}
+	}
+	name := strings.ToLower(tok.value)
+	switch name {
+	case "tolower", "toupper":
+		arg, err := p.parseOperand()
+		if err != nil {
+			return odataOperand{}, err
+		}
+		if arg.field == "" {
+			return odataOperand{}, p.errorAt(arg.tok, true, "property")
+		}
+		if err := p.expect(tokenRParen, "')'"); err != nil {
+			return odataOperand{}, err
+		}
+		arg.lower = true
+		return arg, nil
+	}
+	operators, ok := odataFunctions[name]
+	if !ok {
+		return odataOperand{}, newSyntaxError(p.source, tok.pos, tok.end, fmt.Sprintf("unsupported function %s", tok.value))
+	}
+	property, err := p.parseOperand()
+	if err != nil {
+		return odataOperand{}, err
+	}
+	if property.field == "" {
+		return odataOperand{}, p.errorAt(property.tok, true, "property")
+	}
+	if err := p.expect(tokenComma, "','"); err != nil {
+		return odataOperand{}, err
+	}
+	arg, err := p.parseOperand()
+	if err != nil {
+		return odataOperand{}, err
+	}
+	value, isString := arg.value.(string)
+	if !isString && arg.field == "" {
+		return odataOperand{}, p.errorAt(arg.tok, true, "string")
+	}
+	if arg.field != "" {
+		value = "{{" + arg.field + "}}"
+	}
+	if err := p.expect(tokenRParen, "')'"); err != nil {
+		return odataOperand{}, err
+	}
+	operator := operators[0]
+	if property.lower {
+		operator = operators[1]
+	}
+	return odataOperand{tok: tok, condition: NewFilter(property.field, operator, value)}, nil
+}
+
+// parseLambda parses "var: condition)" after path/any( or path/all(. An any
+// without arguments checks that the collection is not empty.
+func (p *odataParser) parseLambda(tok token, path, kind string) (Condition, *SyntaxError) {
+	field, err := p.field(tok, path)
+	if err != nil {
+		return nil, err
+	}
+	if next, ok := p.peekToken(); ok && next.typ == tokenRParen && kind == "any" {
+		p.pos++
+		return NewFilter(field, GreaterThanCount, 0), nil
+	}
+	variable, ok := p.nextToken()
+	if !ok || variable.typ != tokenIdentifier || strings.Contains(variable.value, "/") {
+		return nil, p.errorAt(variable, ok, "lambda variable")
+	}
+	if err := p.expect(tokenColon, "':'"); err != nil {
+		return nil, err
+	}
+	p.variables = append(p.variables, variable.value)
+	condition, err := p.parseOr()
+	p.variables = p.variables[:len(p.variables)-1]
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(tokenRParen, "')'"); err != nil {
+		return nil, err
+	}
+	if kind == "all" {
+		return NewFilter(field, All, condition), nil
+	}
+	return NewFilter(field, Any, condition), nil
+}
+
+// field converts an OData path into a dotted field. Inside a lambda, paths
+// must start with a lambda variable. Paths on the innermost variable are
+// relative to the element; paths on an outer variable become references to
+// the enclosing element, $parent for the next lambda out and $parents[k]
+// further out.
+func (p *odataParser) field(tok token, path string) (string, *SyntaxError) {
+	segments := strings.Split(path, "/")
+	for _, segment := range segments {
+		if segment == "" {
+			return "", newSyntaxError(p.source, tok.pos, tok.end, "empty path segment")
+		}
+	}
+	n := len(p.variables)
+	if n == 0 {
+		return strings.Join(segments, "."), nil
+	}
+	// the innermost variable of a name shadows the outer ones
+	depth := n
+	for i := n - 1; i >= 0; i-- {
+		if p.variables[i] == segments[0] {
+			depth = n - 1 - i
+			break
+		}
+	}
+	switch {
+	case depth == n:
+		return "", newSyntaxError(p.source, tok.pos, tok.end, fmt.Sprintf("expected a path starting with %s", strings.Join(p.variables, ", ")))
+	case depth > 0:
+		scope := "$parent"
+		if depth > 1 {
+			scope = fmt.Sprintf("$parents[%d]", depth-1)
+		}
+		return "{{" + strings.Join(append([]string{scope}, segments[1:]...), ".") + "}}", nil
+	case len(segments) == 1:
+		return SelfField, nil
+	}
+	return strings.Join(segments[1:], "."), nil
+}
+
+func (p *odataParser) parseValues() ([]any, *SyntaxError) {
+	if err := p.expect(tokenLParen, "'('"); err != nil {
+		return nil, err
+	}
+	var values []any
+	for {
+		tok, ok := p.nextToken()
+		if !ok || tok.typ != tokenValue {
+			return nil, p.errorAt(tok, ok, "value")
+		}
+		values = append(values, tok.literal)
+		tok, ok = p.nextToken()
+		if !ok || (tok.typ != tokenComma && tok.typ != tokenRParen) {
+			return nil, p.errorAt(tok, ok, "','", "')'")
+		}
+		if tok.typ == tokenRParen {
+			return values, nil
+		}
+	}
+}
+
+// accept consumes the next token if it is the given keyword.
+func (p *odataParser) accept(keyword string) bool {
+	tok, ok := p.peekToken()
+	if ok && tok.typ == tokenKeyword && tok.value == keyword {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *odataParser) expect(typ tokenType, expected string) *SyntaxError {
+	tok, ok := p.nextToken()
+	if !ok || tok.typ != typ {
+		return p.errorAt(tok, ok, expected)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package filters_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/oarkflow/filters"
+)
+
+func TestParseOData(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   []bool
+	}{
+		{"age gt 25 and age lt 35", []bool{true, false, false}},
+		{"age eq 25 or city eq null", []bool{false, true, true}},
+		{"not (age ge 30)", []bool{false, true, false}},
+		{"25 lt age", []bool{true, false, true}},
+		{"city in ('New York', 'Los Angeles')", []bool{true, true, false}},
+		{"startswith(name,'J') and not endswith(name,'Doe')", []bool{false, true, false}},
+		{"contains(name,'john')", []bool{false, false, false}},
+		{"contains(tolower(name),'john')", []bool{true, false, true}},
+		{"contains(name,'Smith') eq false", []bool{true, false, true}},
+		{"tags/any(t: t eq 'c')", []bool{false, true, false}},
+		{"tags/any()", []bool{true, true, false}},
+		{"tags/all(t: t in ('a', 'b'))", []bool{true, false, true}},
+		{"created lt 2022-12-31T00:00:00Z", []bool{true, false, true}},
+	}
+	for _, test := range tests {
+		condition, err := filters.ParseOData(test.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", test.filter, err)
+		}
+		for i, person := range people {
+			if got := condition.Match(person); got != test.want[i] {
+				t.Errorf("%s: record %d: got %v, want %v", test.filter, i, got, test.want[i])
+			}
+		}
+	}
+}
+
+func TestParseODataLambdaOverDocuments(t *testing.T) {
+	order := map[string]any{"lines": []any{
+		map[string]any{"sku": "A1", "item": map[string]any{"qty": 1}},
+		map[string]any{"sku": "B2", "item": map[string]any{"qty": 5}},
+	}}
+	condition, err := filters.ParseOData("lines/any(l: startswith(l/sku,'B') and l/item/qty gt 2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !condition.Match(order) {
+		t.Error("expected a line to match")
+	}
+}
+
+func TestParseODataNestedLambdaScope(t *testing.T) {
+	customer := map[string]any{"Orders": []any{
+		map[string]any{"Status": "open", "Limit": 3, "Items": []any{
+			map[string]any{"Price": 2, "Parts": []any{map[string]any{"Kind": "bolt"}}},
+		}},
+		map[string]any{"Status": "x", "Limit": 9, "Items": []any{
+			map[string]any{"Price": 8, "Parts": []any{map[string]any{"Kind": "nut"}}},
+		}},
+	}}
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"Orders/any(o: o/Items/any(i: i/Price gt 5 and o/Status eq 'x'))", true},
+		{"Orders/any(o: o/Items/any(i: i/Price gt 5 and o/Status eq 'open'))", false},
+		{"Orders/any(o: o/Items/any(i: i/Price lt o/Limit))", true},
+		{"Orders/all(o: o/Items/any(i: i/Price lt o/Limit))", true},
+		{"Orders/any(o: o/Items/any(i: i/Parts/any(p: p/Kind eq 'nut' and o/Status eq 'x' and i/Price eq 8)))", true},
+		{"Orders/any(o: o/Items/any(i: i/Parts/any(p: p/Kind eq 'bolt' and o/Status eq 'x')))", false},
+	}
+	for _, test := range tests {
+		condition, err := filters.ParseOData(test.filter)
+		if err != nil {
+			t.Errorf("%s: %v", test.filter, err)
+			continue
+		}
+		if got, err := filters.MatchE(condition, customer); err != nil || got != test.want {
+			t.Errorf("%s: got %v, %v, want %v", test.filter, got, err, test.want)
+		}
+	}
+}
+
+func TestParseODataSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		filter string
+		column int
+	}{
+		{"age gt", 7},
+		{"age gt 25 and", 14},
+		{"age is 25", 5},
+		{"(age gt 25", 11},
+		{"substring(name,1) eq 'x'", 1},
+		{"tags/any(t: x eq 'c')", 13},
+		{"name eq 'unterminated", 9},
+		{"age gt 25 age", 11},
+	}
+	for _, test := range tests {
+		_, err := filters.ParseOData(test.filter)
+		var syntaxErr *filters.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%s: expected a SyntaxError, got %v", test.filter, err)
+			continue
+		}
+		if syntaxErr.Column != test.column {
+			t.Errorf("%s: column = %d, want %d (%v)", test.filter, syntaxErr.Column, test.column, err)
+		}
+	}
+}
@@ -42,4 +42,44 @@ const (
 	NotStartsWithCS       Operator = "nstartswith_cs"
 	EndsWithCS            Operator = "endswith_cs"
 	NotEndsWithCS         Operator = "nendswith_cs"
+	// Exists matches when Field is present in the data, even when it holds
+	// null, and NotExists when it is absent. Neither takes a value, and a
+	// missing field is not an error for them in Strict mode.
+	Exists    Operator = "exists"
+	NotExists Operator = "nexists"
+	// Any matches when at least one element of the array at Field satisfies
+	// the Condition given as Value. Inside that condition the field "$"
+	// refers to the element itself, and references can use $this for the
+	// element, $parent for the item holding the array, $parents for all the
+	// enclosing items, innermost first, and $root for the item being matched.
+	Any Operator = "any"
+	// All matches when every element of the array at Field satisfies the
+	// Condition given as Value, including when the array is empty.
+	All Operator = "all"
+	// None matches when no element of the array at Field satisfies the
+	// Condition given as Value.
+	None Operator = "none"
+
+	// The collection operators compare the array at Field with a list of
+	// values, matching elements as eq does, or check its length.
+	LenEqual       Operator = "len_eq"
+	LenGreaterThan Operator = "len_gt"
+	LenBetween     Operator = "len_between"
+	ContainsAll    Operator = "contains_all"
+	ContainsAny    Operator = "contains_any"
+	SubsetOf       Operator = "subset_of"
+	SupersetOf     Operator = "superset_of"
+	Disjoint       Operator = "disjoint"
+	HasDuplicates  Operator = "has_duplicates"
+
+	// The date operators read the field as a point in time. Strings without
+	// a zone are read in MatchOptions.Location, which is also the zone of the
+	// calendar operators on_date, age_between and day_of_week_in.
+	Before      Operator = "before"
+	After       Operator = "after"
+	OnDate      Operator = "on_date"
+	WithinLast  Operator = "within_last"
+	WithinNext  Operator = "within_next"
+	AgeBetween  Operator = "age_between"
+	DayOfWeekIn Operator = "day_of_week_in"
 )
@@ -0,0 +1,99 @@
+package filters
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+)
+
+// isQuantifier reports whether operator matches the elements of an array
+// against a nested condition.
+func isQuantifier(operator Operator) bool {
+	return operator == Any || operator == All || operator == None
+}
+
+// scope is the chain of items enclosing the element a quantifier's condition
+// is evaluated against, innermost first.
+type scope struct {
+	parent any
+	outer  *scope
+}
+
+// env returns the environment references are evaluated in: the fields of
+// item when it is a map, and $this, $parent, $parents and $root when it is
+// an element of an array. $parents lists the enclosing items, innermost
+// first, so $parents[0] is $parent.
+func (c *compiledFilter) env(item any, opts MatchOptions) any {
+	if !c.scoped || opts.scope == nil {
+		return item
+	}
+	env := map[string]any{}
+	if m, ok := item.(map[string]any); ok {
+		maps.Copy(env, m)
+	}
+	var parents []any
+	root := opts.scope
+	for s := opts.scope; s != nil; s = s.outer {
+		parents = append(parents, s.parent)
+		root = s
+	}
+	env["$this"], env["$parent"], env["$parents"], env["$root"] = item, opts.scope.parent, parents, root.parent
+	return env
+}
+
+// quantify matches condition against the elements of data, the array held by
+// parent, and combines the results as operator says. A nil array has no
+// elements.
+func quantify(operator Operator, condition CompiledCondition, data, parent any, opts MatchOptions) (bool, error) {
+	if data == nil {
+		return operator != Any, nil
+	}
+	rv := reflect.ValueOf(data)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return false, fmt.Errorf("%w: %s expects a slice, got %T", ErrTypeMismatch, operator, data)
+	}
+	opts.scope = &scope{parent: parent, outer: opts.scope}
+	for i := 0; i < rv.Len(); i++ {
+		matched, err := MatchE(condition, rv.Index(i).Interface(), opts)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case operator == Any && matched:
+			return true, nil
+		case operator == All && !matched, operator == None && matched:
+			return false, nil
+		}
+	}
+	return operator != Any, nil
+}
+
+// asAny rewrites an All or None filter as the equivalent reversed Any, so
+// that translators only need to handle Any: every element matches when no
+// element fails.
+func asAny(filter *Filter) *Filter {
+	if filter.Operator != All && filter.Operator != None {
+		return filter
+	}
+	rewritten := *filter
+	rewritten.Operator, rewritten.Reverse = Any, !filter.Reverse
+	if condition, ok := filter.Value.(Condition); ok && filter.Operator == All {
+		rewritten.Value = negated(condition)
+	}
+	return &rewritten
+}
+
+// negated returns the negation of condition without changing it.
+func negated(condition Condition) Condition {
+	switch c := condition.(type) {
+	case *Filter:
+		copied := *c
+		copied.Reverse = !c.Reverse
+		return &copied
+	case *FilterGroup:
+		copied := *c
+		copied.Reverse = !c.Reverse
+		return &copied
+	}
+	return NewFilterGroup(AND, true, condition)
+}
@@ -0,0 +1,155 @@
+package filters_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/oarkflow/filters"
+)
+
+var claim = map[string]any{
+	"max_qty": 2,
+	"tags":    []any{},
+	"coding": []any{
+		map[string]any{"dos": "2020/01/01", "details": map[string]any{"pro": map[string]any{"cpt": []any{
+			map[string]any{"procedure_num": "AN65450", "procedure_qty": 1},
+		}}}},
+		map[string]any{"dos": "2020/01/02", "details": map[string]any{"pro": map[string]any{"cpt": []any{
+			map[string]any{"procedure_num": "AN65451", "procedure_qty": 3},
+			map[string]any{"procedure_num": "99213", "procedure_qty": 1},
+		}}}},
+	},
+}
+
+func anesthesia(qty any) filters.Condition {
+	return filters.NewFilterGroup(filters.AND, false,
+		filters.NewFilter("procedure_qty", filters.GreaterThan, qty),
+		filters.NewFilter("procedure_num", filters.StartsWith, "AN"),
+	)
+}
+
+func TestQuantifiers(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition filters.Condition
+		want      bool
+	}{
+		{"any of any", filters.NewFilter("coding", filters.Any,
+			filters.NewFilter("details.pro.cpt", filters.Any, anesthesia(1))), true},
+		{"all of any", filters.NewFilter("coding", filters.All,
+			filters.NewFilter("details.pro.cpt", filters.Any, anesthesia(1))), false},
+		{"none of any", filters.NewFilter("coding", filters.None,
+			filters.NewFilter("details.pro.cpt", filters.Any, anesthesia(5))), true},
+		{"all quantities", filters.NewFilter("coding", filters.All,
+			filters.NewFilter("details.pro.cpt", filters.All, filters.NewFilter("procedure_qty", filters.GreaterThanEqual, 1))), true},
+		{"all of empty", filters.NewFilter("tags", filters.All, filters.NewFilter("$", filters.Equal, "x")), true},
+		{"none of empty", filters.NewFilter("tags", filters.None, filters.NewFilter("$", filters.Equal, "x")), true},
+		{"any of empty", filters.NewFilter("tags", filters.Any, filters.NewFilter("$", filters.Equal, "x")), false},
+		{"root reference", filters.NewFilter("coding", filters.Any,
+			filters.NewFilter("details.pro.cpt", filters.Any, anesthesia("{{$root.max_qty}}"))), true},
+		{"parent reference", filters.NewFilter("coding", filters.Any,
+			filters.NewFilter("details.pro.cpt", filters.Any, filters.NewFilterGroup(filters.AND, false,
+				filters.NewFilter("{{$parent.dos}}", filters.Equal, "2020/01/02"),
+				filters.NewFilter("procedure_qty", filters.GreaterThan, 1),
+			))), true},
+		{"this reference", filters.NewFilter("coding", filters.Any,
+			filters.NewFilter("details.pro.cpt", filters.Any,
+				filters.NewFilter("procedure_qty", filters.Expression, "{{$this.procedure_qty > $root.max_qty}}"))), true},
+	}
+	for _, test := range tests {
+		got, err := filters.MatchE(test.condition, claim)
+		if err != nil || got != test.want {
+			t.Errorf("%s: got %v, %v, want %v", test.name, got, err, test.want)
+		}
+		compiled, err := filters.Compile(test.condition)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if compiled.Match(claim) != test.want {
+			t.Errorf("%s: compiled condition disagrees", test.name)
+		}
+	}
+}
+
+func TestQuantifierErrors(t *testing.T) {
+	if err := filters.NewFilter("coding", filters.All, "x").Validate(); err == nil {
+		t.Errorf("expected a quantifier without a condition to be invalid")
+	}
+	if _, err := filters.NewFilter("max_qty", filters.None, filters.NewFilter("$", filters.Equal, 1)).MatchE(claim); err == nil {
+		t.Errorf("expected a quantifier over a number to fail")
+	}
+}
+
+func TestQuantifiersFromParsers(t *testing.T) {
+	for _, sql := range []string{
+		"EXISTS (SELECT * FROM coding WHERE EXISTS (SELECT * FROM details.pro.cpt WHERE procedure_qty > 1 AND procedure_num LIKE 'AN%'))",
+		"ANY (coding WHERE ALL (details.pro.cpt WHERE procedure_qty >= 1)) AND NONE (tags WHERE $ = 'x')",
+		"NOT EXISTS (SELECT 1 FROM coding WHERE dos = '2020/01/03') AND max_qty = 2",
+	} {
+		rule, err := filters.ParseSQL(sql)
+		if err != nil {
+			t.Errorf("%s: %v", sql, err)
+			continue
+		}
+		if got, err := rule.MatchE(claim); err != nil || !got {
+			t.Errorf("%s: got %v, %v", sql, got, err)
+		}
+	}
+	if _, err := filters.ParseSQL("EXISTS (SELECT * FROM coding dos = 1)"); err == nil {
+		t.Errorf("expected a quantifier without WHERE to fail")
+	}
+	for _, query := range []string{
+		"coding=any:details.pro.cpt:any:(procedure_qty:gt:1,procedure_num:startswith:AN)",
+		"filter=coding:all:(details.pro.cpt:any:(procedure_num:startswith:AN)),tags:none:($:eq:x)",
+	} {
+		group, err := filters.ParseQueryGroup(query)
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+			continue
+		}
+		if got, err := group.MatchE(claim); err != nil || !got {
+			t.Errorf("%s: got %v, %v", query, got, err)
+		}
+	}
+}
+
+func TestQuantifierTranslations(t *testing.T) {
+	all := filters.NewFilter("tags", filters.All, filters.NewFilter("$", filters.In, []any{"a", "b"}))
+	none := filters.NewFilter("lines", filters.None, filters.NewFilter("qty", filters.GreaterThan, 1))
+
+	mongo, err := filters.ToMongo(filters.NewFilterGroup(filters.AND, false, all, none))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"tags":  map[string]any{"$not": map[string]any{"$elemMatch": map[string]any{"$not": map[string]any{"$in": []any{"a", "b"}}}}},
+		"lines": map[string]any{"$not": map[string]any{"$elemMatch": map[string]any{"qty": map[string]any{"$gt": 1}}}},
+	}
+	if !reflect.DeepEqual(mongo, want) {
+		t.Errorf("got %v, want %v", mongo, want)
+	}
+
+	// JsonLogic compares strings case-sensitively, so the elements are numbers
+	scores := filters.NewFilter("scores", filters.All, filters.NewFilter("$", filters.In, []any{1, 2}))
+	logic, err := filters.ToJSONLogic(filters.NewFilterGroup(filters.AND, false, scores, none))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(logic)
+	parsed, err := filters.ParseJSONLogic(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	operators := []filters.Operator{}
+	for _, condition := range parsed.(*filters.FilterGroup).Filters {
+		operators = append(operators, condition.(*filters.Filter).Operator)
+	}
+	if !reflect.DeepEqual(operators, []filters.Operator{filters.All, filters.None}) {
+		t.Errorf("%s read back as %v", data, operators)
+	}
+
+	if _, err := filters.ToElasticQuery(none); err != nil {
+		t.Errorf("elasticsearch: %v", err)
+	}
+}
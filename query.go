@@ -2,120 +2,592 @@ package filters
 
 import (
 	"errors"
+	"fmt"
+	"maps"
 	"net/url"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // ParseQuery parses the query string and returns Filter or Query.
+//
+// Each parameter is either a field:operator:value key, a field:operator key
+// for operators without a value, or field=operator:value. A leading ! on a
+// field:operator:value key reverses the filter. Values of between, in and
+// the other operators are split on commas unless escaped as \, (a literal
+// backslash is \\), and the value of any, all and none is a filter
+// expression as accepted by ParseQueryGroup.
 func ParseQuery(queryString string, exceptFields ...string) (filters []*Filter, err error) {
-	queryParams, err1 := url.ParseQuery(strings.TrimPrefix(queryString, "?"))
+	queryParams, err := url.ParseQuery(strings.TrimPrefix(queryString, "?"))
 	if err != nil {
-		err = err1
-		return
+		return nil, err
 	}
 	for key, values := range queryParams {
-		if strings.Contains(key, ":") {
-			parts := strings.Split(key, ":")
-			if len(parts) == 2 {
-				field := parts[0]
-				if len(exceptFields) > 0 && slices.Contains(exceptFields, field) {
-					continue
-				}
-				operator := strings.ToLower(parts[1])
-				if _, exists := validOperators[Operator(operator)]; exists {
-					// Operators that don't require value
-					if Operator(operator) == IsNull || Operator(operator) == NotNull || Operator(operator) == IsZero || Operator(operator) == NotZero {
-						filters = append(filters, NewFilter(field, Operator(operator), nil))
-					} else {
-						filters = append(filters, NewFilter(field, Operator(operator), ""))
-					}
-				} else {
-					filters = append(filters, NewFilter(field, Equal, parts[1]))
-				}
-			} else if len(parts) == 3 {
-				if len(exceptFields) > 0 && slices.Contains(exceptFields, parts[0]) {
-					continue
-				}
-				// Handle complex field:operator:value
-				field := parts[0]
-				operator := parts[1]
-				opValue := parts[2]
-				if _, exists := validOperators[Operator(strings.ToLower(operator))]; !exists {
-					return nil, errors.New("invalid operator " + operator)
-				}
-				// For between operator, split values into two parts
-				var val any
-				if strings.Contains(opValue, ",") {
-					betweenParts := strings.Split(opValue, ",")
-					if Operator(operator) == Between && len(betweenParts) != 2 {
-						return nil, errors.New("operator must have at least two values")
-					}
-					if Operator(operator) == In && len(betweenParts) < 1 {
-						return nil, errors.New("operator must have at least two values")
-					}
-					for i, p := range betweenParts {
-						p = strings.TrimSpace(p)
-						betweenParts[i] = p
-					}
-
-					val = betweenParts
+		parsed, err := queryFilters(key, values, exceptFields)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, parsed...)
+	}
+	return
+}
+
+// queryFilters parses a single query parameter in the field:operator,
+// field:operator:value or field=operator:value forms.
+func queryFilters(key string, values []string, exceptFields []string) (filters []*Filter, err error) {
+	if strings.Contains(key, ":") {
+		reverse := strings.HasPrefix(key, "!")
+		parts := strings.SplitN(strings.TrimPrefix(key, "!"), ":", 3)
+		if len(exceptFields) > 0 && slices.Contains(exceptFields, parts[0]) {
+			return nil, nil
+		}
+		var filter *Filter
+		if len(parts) == 2 {
+			field := parts[0]
+			operator := strings.ToLower(parts[1])
+			if isValidOperator(operator) {
+				// Operators that don't require value
+				if isValueless(Operator(operator)) {
+					filter = NewFilter(field, Operator(operator), nil)
 				} else {
-					val = opValue
+					filter = NewFilter(field, Operator(operator), "")
 				}
-				filters = append(filters, NewFilter(field, Operator(operator), val))
+			} else {
+				filter = NewFilter(field, Equal, parts[1])
 			}
 		} else {
-			if len(exceptFields) > 0 && slices.Contains(exceptFields, key) {
-				continue
+			// Handle complex field:operator:value
+			field := parts[0]
+			operator := parts[1]
+			val, err := queryValue(operator, parts[2], exceptFields)
+			if err != nil {
+				return nil, err
 			}
-			if len(values) == 1 {
-				value := values[0]
-				if _, exists := validOperators[Operator(strings.ToLower(value))]; exists {
-					// Operators that don't require value
-					if Operator(strings.ToLower(value)) == IsNull || Operator(strings.ToLower(value)) == NotNull || Operator(strings.ToLower(value)) == IsZero || Operator(strings.ToLower(value)) == NotZero {
-						filters = append(filters, NewFilter(key, Operator(value), nil))
-					} else {
-						filters = append(filters, NewFilter(key, Operator(value), ""))
-					}
-				} else if strings.Contains(value, ":") {
-					parts := strings.Split(value, ":")
-					if len(parts) == 2 {
-						operator := parts[0]
-						opValue := parts[1]
-						if _, exists := validOperators[Operator(strings.ToLower(operator))]; !exists {
-							return nil, errors.New("invalid operator " + operator)
-						}
-						// For between operator, split values into two parts
-						var val any
-						if strings.Contains(opValue, ",") {
-							betweenParts := strings.Split(opValue, ",")
-							if Operator(operator) == Between && len(betweenParts) != 2 {
-								return nil, errors.New("operator must have at least two values")
-							}
-							if Operator(operator) == In && len(betweenParts) < 1 {
-								return nil, errors.New("operator must have at least two values")
-							}
-							for i, p := range betweenParts {
-								p = strings.TrimSpace(p)
-								betweenParts[i] = p
-							}
-
-							val = betweenParts
-						} else {
-							val = opValue
-						}
-						filters = append(filters, NewFilter(key, Operator(operator), val))
-					} else {
-						filters = append(filters, NewFilter(key, Equal, value))
-					}
-				} else {
-					filters = append(filters, NewFilter(key, Equal, value))
-				}
-			} else if len(values) > 1 {
-				filters = append(filters, NewFilter(key, In, values))
+			filter = NewFilter(field, Operator(operator), val)
+		}
+		filter.Reverse = reverse
+		return []*Filter{filter}, nil
+	}
+	if len(exceptFields) > 0 && slices.Contains(exceptFields, key) {
+		return nil, nil
+	}
+	if len(values) == 1 {
+		value := values[0]
+		if isValidOperator(value) {
+			// Operators that don't require value
+			if isValueless(Operator(strings.ToLower(value))) {
+				filters = append(filters, NewFilter(key, Operator(value), nil))
+			} else {
+				filters = append(filters, NewFilter(key, Operator(value), ""))
 			}
+		} else if operator, opValue, found := strings.Cut(value, ":"); found && (strings.Count(value, ":") == 1 || isValidOperator(operator)) {
+			val, err := queryValue(operator, opValue, exceptFields)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, NewFilter(key, Operator(operator), val))
+		} else {
+			filters = append(filters, NewFilter(key, Equal, value))
 		}
+	} else if len(values) > 1 {
+		filters = append(filters, NewFilter(key, In, values))
 	}
 	return
 }
+
+func isValidOperator(operator string) bool {
+	_, exists := validOperators[Operator(strings.ToLower(operator))]
+	return exists
+}
+
+func isValueless(operator Operator) bool {
+	switch operator {
+	case IsNull, NotNull, Exists, NotExists, IsZero, NotZero, HasDuplicates:
+		return true
+	}
+	return false
+}
+
+// queryValue parses the value of operator. Values containing unescaped
+// commas become a list; the value of a quantifier is a filter expression.
+func queryValue(operator, opValue string, exceptFields []string) (any, error) {
+	if !isValidOperator(operator) {
+		return nil, errors.New("invalid operator " + operator)
+	}
+	if isQuantifier(Operator(operator)) {
+		return parseFilterExpression(opValue, exceptFields)
+	}
+	parts := splitEscaped(opValue)
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	// For between operators, split values into two parts
+	if (Operator(operator) == Between || Operator(operator) == AgeBetween || Operator(operator) == LenBetween) && len(parts) != 2 {
+		return nil, errors.New("operator must have at least two values")
+	}
+	for i, p := range parts {
+		parts[i] = strings.TrimSpace(p)
+	}
+	return parts, nil
+}
+
+// splitEscaped splits s on commas, reading \, as a comma and \\ as a
+// backslash. Other backslashes are kept, so patterns need no escaping.
+func splitEscaped(s string) []string {
+	var parts []string
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && (s[i+1] == ',' || s[i+1] == '\\'):
+			i++
+			b.WriteByte(s[i])
+		case s[i] == ',':
+			parts = append(parts, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return append(parts, b.String())
+}
+
+// ParseQueryGroup parses a query string into a group, so that callers can
+// express OR and nesting from a URL. Besides the forms understood by
+// ParseQuery, which are combined with AND, it accepts:
+//
+//   - bracketed groups such as or[0][status]=eq:active&or[1][age]=gt:30,
+//     where parameters sharing an index are combined with AND and groups
+//     nest as in or[1][and][0][age]=gt:30;
+//   - a filter expression such as filter=(a:eq:1|b:eq:2),c:gt:3, where ","
+//     means AND, "|" means OR and binds looser, "!" negates and a backslash
+//     escapes a following ",", "|", parenthesis or backslash, as in
+//     age:between:18\,30. The condition of any, all and none may follow
+//     in parentheses, as in lines:any:(qty:gt:1,sku:startswith:AN).
+func ParseQueryGroup(queryString string, exceptFields ...string) (*FilterGroup, error) {
+	queryParams, err := url.ParseQuery(strings.TrimPrefix(queryString, "?"))
+	if err != nil {
+		return nil, err
+	}
+	return queryGroup(queryParams, exceptFields)
+}
+
+// queryGroup builds the group for the parsed parameters of a query string.
+func queryGroup(queryParams url.Values, exceptFields []string) (*FilterGroup, error) {
+	root := &queryItem{}
+	for _, key := range slices.Sorted(maps.Keys(queryParams)) {
+		values := queryParams[key]
+		if key == "filter" {
+			for _, value := range values {
+				condition, err := parseFilterExpression(value, exceptFields)
+				if err != nil {
+					return nil, err
+				}
+				if condition != nil {
+					root.conditions = append(root.conditions, condition)
+				}
+			}
+			continue
+		}
+		if operator, segments, ok := groupKey(key); ok {
+			if err := root.tree(operator).add(key, segments, values, exceptFields); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		parsed, err := queryFilters(key, values, exceptFields)
+		if err != nil {
+			return nil, err
+		}
+		for _, filter := range parsed {
+			root.conditions = append(root.conditions, filter)
+		}
+	}
+	condition := root.condition()
+	if group, ok := condition.(*FilterGroup); ok {
+		return group, nil
+	}
+	if condition == nil {
+		return NewFilterGroup(AND, false), nil
+	}
+	return NewFilterGroup(AND, false, condition), nil
+}
+
+// groupKey splits a key such as or[0][status] into its boolean operator and
+// bracketed segments.
+func groupKey(key string) (Boolean, []string, bool) {
+	head, rest, found := strings.Cut(key, "[")
+	operator := Boolean(strings.ToUpper(head))
+	if !found || (operator != AND && operator != OR) {
+		return "", nil, false
+	}
+	var segments []string
+	for rest != "" {
+		segment, tail, found := strings.Cut(rest, "]")
+		if !found || (tail != "" && tail[0] != '[') {
+			return "", nil, false
+		}
+		segments = append(segments, segment)
+		rest = strings.TrimPrefix(tail, "[")
+	}
+	return operator, segments, true
+}
+
+// queryTree collects the bracketed parameters of one group by index.
+type queryTree struct {
+	operator Boolean
+	items    map[int]*queryItem
+}
+
+// queryItem holds the conditions sharing an index, which are combined with AND.
+type queryItem struct {
+	conditions []Condition
+	trees      map[Boolean]*queryTree
+}
+
+func (item *queryItem) tree(operator Boolean) *queryTree {
+	if item.trees == nil {
+		item.trees = make(map[Boolean]*queryTree)
+	}
+	if item.trees[operator] == nil {
+		item.trees[operator] = &queryTree{operator: operator, items: make(map[int]*queryItem)}
+	}
+	return item.trees[operator]
+}
+
+// add places the parameter key, whose remaining segments start with an
+// index, in the tree.
+func (t *queryTree) add(key string, segments []string, values []string, exceptFields []string) error {
+	if len(segments) < 2 {
+		return fmt.Errorf("%s: expected an index and a field", key)
+	}
+	index, err := strconv.Atoi(segments[0])
+	if err != nil {
+		return fmt.Errorf("%s: invalid group index %q", key, segments[0])
+	}
+	item := t.items[index]
+	if item == nil {
+		item = &queryItem{}
+		t.items[index] = item
+	}
+	field := segments[1]
+	if operator := Boolean(strings.ToUpper(field)); (operator == AND || operator == OR) && len(segments) > 2 {
+		return item.tree(operator).add(key, segments[2:], values, exceptFields)
+	}
+	if len(segments) > 2 {
+		return fmt.Errorf("%s: unexpected segments after field %q", key, field)
+	}
+	// a repeated parameter adds one filter per value
+	for _, value := range values {
+		parsed, err := queryFilters(field, []string{value}, exceptFields)
+		if err != nil {
+			return err
+		}
+		for _, filter := range parsed {
+			item.conditions = append(item.conditions, filter)
+		}
+	}
+	return nil
+}
+
+func (t *queryTree) condition() Condition {
+	var conditions []Condition
+	for _, index := range slices.Sorted(maps.Keys(t.items)) {
+		if condition := t.items[index].condition(); condition != nil {
+			conditions = append(conditions, condition)
+		}
+	}
+	if len(conditions) == 0 {
+		return nil
+	}
+	return NewFilterGroup(t.operator, false, conditions...)
+}
+
+func (item *queryItem) condition() Condition {
+	conditions := item.conditions
+	for _, operator := range []Boolean{AND, OR} {
+		if tree := item.trees[operator]; tree != nil {
+			if condition := tree.condition(); condition != nil {
+				conditions = append(conditions, condition)
+			}
+		}
+	}
+	switch len(conditions) {
+	case 0:
+		return nil
+	case 1:
+		return conditions[0]
+	}
+	return NewFilterGroup(AND, false, conditions...)
+}
+
+// parseFilterExpression parses the value of a filter parameter:
+//
+//	or   = and { "|" and }
+//	and  = term { "," term }
+//	term = "!" term | "(" or ")" | field ":" [operator ":"] value
+func parseFilterExpression(expression string, exceptFields []string) (Condition, error) {
+	p := &filterExpressionParser{source: expression, exceptFields: exceptFields}
+	condition, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(expression) {
+		return nil, fmt.Errorf("filter: unexpected %q at offset %d", expression[p.pos], p.pos)
+	}
+	return condition, nil
+}
+
+type filterExpressionParser struct {
+	source       string
+	pos          int
+	exceptFields []string
+}
+
+func (p *filterExpressionParser) parseOr() (Condition, error) {
+	return p.parseSequence(OR, '|', p.parseAnd)
+}
+
+func (p *filterExpressionParser) parseAnd() (Condition, error) {
+	return p.parseSequence(AND, ',', p.parseTerm)
+}
+
+// parseSequence joins operands separated by separator, dropping terms on
+// excluded fields.
+func (p *filterExpressionParser) parseSequence(operator Boolean, separator byte, operand func() (Condition, error)) (Condition, error) {
+	var conditions []Condition
+	for {
+		condition, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if condition != nil {
+			conditions = append(conditions, condition)
+		}
+		if p.pos >= len(p.source) || p.source[p.pos] != separator {
+			break
+		}
+		p.pos++
+	}
+	switch len(conditions) {
+	case 0:
+		return nil, nil
+	case 1:
+		return conditions[0], nil
+	}
+	return NewFilterGroup(operator, false, conditions...), nil
+}
+
+func (p *filterExpressionParser) parseTerm() (Condition, error) {
+	if p.pos < len(p.source) && p.source[p.pos] == '!' {
+		p.pos++
+		condition, err := p.parseTerm()
+		if err != nil || condition == nil {
+			return nil, err
+		}
+		return negate(condition), nil
+	}
+	if p.pos < len(p.source) && p.source[p.pos] == '(' {
+		p.pos++
+		condition, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.source) || p.source[p.pos] != ')' {
+			return nil, fmt.Errorf("filter: expected ')' at offset %d", p.pos)
+		}
+		p.pos++
+		return condition, nil
+	}
+	start := p.pos
+	var b strings.Builder
+	for ; p.pos < len(p.source) && strings.IndexByte(",|()", p.source[p.pos]) < 0; p.pos++ {
+		if p.source[p.pos] == '\\' && p.pos+1 < len(p.source) && strings.IndexByte(expressionSpecials, p.source[p.pos+1]) >= 0 {
+			p.pos++
+		}
+		b.WriteByte(p.source[p.pos])
+	}
+	term := b.String()
+	if field, operator, ok := quantifierTerm(term); ok && p.pos < len(p.source) && p.source[p.pos] == '(' {
+		condition, err := p.parseTerm()
+		if err != nil || slices.Contains(p.exceptFields, field) {
+			return nil, err
+		}
+		if condition == nil {
+			return nil, fmt.Errorf("filter: expected a condition for %s at offset %d", operator, start)
+		}
+		return NewFilter(field, operator, condition), nil
+	}
+	if !strings.Contains(term, ":") {
+		return nil, fmt.Errorf("filter: expected field:operator:value at offset %d", start)
+	}
+	parsed, err := queryFilters(term, []string{""}, p.exceptFields)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed) == 0 {
+		return nil, nil
+	}
+	return parsed[0], nil
+}
+
+// quantifierTerm splits a field:operator: term whose operator is any, all
+// or none and whose condition follows in parentheses.
+func quantifierTerm(term string) (string, Operator, bool) {
+	rest, ok := strings.CutSuffix(term, ":")
+	if !ok {
+		return "", "", false
+	}
+	field, operator, ok := strings.Cut(rest, ":")
+	op := Operator(strings.ToLower(operator))
+	return field, op, ok && isQuantifier(op)
+}
+
+// expressionSpecials are the characters escaped with a backslash in filter
+// expressions.
+const expressionSpecials = `,|()\`
+
+var (
+	expressionEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, "|", `\|`, "(", `\(`, ")", `\)`)
+	valueEscaper      = strings.NewReplacer(`\`, `\\`, ",", `\,`)
+	// queryEscaper keeps the separators of the query syntax readable after
+	// url.QueryEscape; url.ParseQuery reads them back unchanged.
+	queryEscaper = strings.NewReplacer("%3A", ":", "%2C", ",", "%21", "!", "%28", "(", "%29", ")", "%24", "$", "%2F", "/", "%40", "@")
+)
+
+// EncodeQuery writes filters in the field:operator:value form read by
+// ParseQuery. Operators without a value are written as field:operator, list
+// values are comma-separated, reversed filters start with ! and the condition
+// of any, all and none is written as a filter expression. Parameters are
+// sorted, so equal sets of filters give equal strings.
+//
+// ParseQuery(EncodeQuery(filters)) gives the same filters with their values
+// as strings, except that:
+//
+//   - list elements lose leading and trailing spaces, which ParseQuery trims;
+//   - a list of one element, as for in or nin, comes back as that element;
+//   - a field must not contain ":" or start with "!", which ParseQuery reads
+//     as negation.
+func EncodeQuery(filters []*Filter) string {
+	params := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		params = append(params, queryEscape(encodeFilter(filter)))
+	}
+	slices.Sort(params)
+	return strings.Join(params, "&")
+}
+
+// EncodeQueryGroup writes group in the form read by ParseQueryGroup. A group
+// of plain filters combined with AND is written as by EncodeQuery; anything
+// else becomes a filter expression. Custom conditions cannot be encoded.
+func EncodeQueryGroup(group *FilterGroup) (string, error) {
+	if group.Operator == AND && !group.Reverse {
+		filters := make([]*Filter, 0, len(group.Filters))
+		for _, condition := range group.Filters {
+			if filter, ok := condition.(*Filter); ok {
+				filters = append(filters, filter)
+			}
+		}
+		if len(filters) == len(group.Filters) {
+			return EncodeQuery(filters), nil
+		}
+	}
+	expression, err := encodeExpression(group)
+	if err != nil {
+		return "", err
+	}
+	return "filter=" + queryEscape(expression), nil
+}
+
+func queryEscape(s string) string {
+	return queryEscaper.Replace(url.QueryEscape(s))
+}
+
+func encodeFilter(filter *Filter) string {
+	key := filter.Field + ":" + string(filter.Operator)
+	if filter.Reverse {
+		key = "!" + key
+	}
+	if isValueless(filter.Operator) {
+		return key
+	}
+	if condition, ok := filter.Value.(Condition); ok && isQuantifier(filter.Operator) {
+		expression, _ := encodeExpression(condition)
+		return key + ":" + expression
+	}
+	values := sqlValues(filter.Value)
+	texts := make([]string, len(values))
+	for i, v := range values {
+		texts[i] = valueEscaper.Replace(queryText(v))
+	}
+	return key + ":" + strings.Join(texts, ",")
+}
+
+func queryText(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// encodeExpression writes condition as a filter expression.
+func encodeExpression(condition Condition) (string, error) {
+	switch c := condition.(type) {
+	case CompiledCondition:
+		return encodeExpression(c.Source())
+	case *Filter:
+		term := encodeFilter(c)
+		if c.Reverse {
+			return "!" + expressionEscaper.Replace(term[1:]), nil
+		}
+		return expressionEscaper.Replace(term), nil
+	case *FilterGroup:
+		return encodeSequence(c.Operator, c.Reverse, c.Filters...)
+	case *Rule:
+		if c.Next == nil {
+			return encodeSequence(AND, c.Reverse, c.Node)
+		}
+		return encodeSequence(c.Operator, c.Reverse, c.Node, c.Next)
+	case *Join:
+		return encodeSequence(c.Operator, c.Reverse, c.Left, c.Right)
+	}
+	return "", fmt.Errorf("cannot encode %T as a query", condition)
+}
+
+func encodeSequence(operator Boolean, reverse bool, conditions ...Condition) (string, error) {
+	if operator != AND && operator != OR {
+		return "", fmt.Errorf("unsupported boolean operator: %s", operator)
+	}
+	if len(conditions) == 0 {
+		return "", errors.New("cannot encode an empty group as a query")
+	}
+	parts := make([]string, len(conditions))
+	for i, condition := range conditions {
+		if condition == nil {
+			return "", errors.New("cannot encode a nil condition as a query")
+		}
+		part, err := encodeExpression(condition)
+		if err != nil {
+			return "", err
+		}
+		if _, ok := condition.(*Filter); !ok && !strings.HasPrefix(part, "!") {
+			part = "(" + part + ")"
+		}
+		parts[i] = part
+	}
+	separator := ","
+	if operator == OR {
+		separator = "|"
+	}
+	expression := strings.Join(parts, separator)
+	if reverse {
+		return "!(" + expression + ")", nil
+	}
+	return expression, nil
+}
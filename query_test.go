@@ -0,0 +1,169 @@
+package filters_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oarkflow/filters"
+)
+
+func TestParseQueryGroup(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []bool
+	}{
+		{"or[0][age]=eq:25&or[1][city]=null", []bool{false, true, true}},
+		{"or[0][age]=gt:26&or[0][age]=lt:34&or[1][name]=startswith:jane", []bool{true, true, false}},
+		{"or[0][name]=contains:o&or[1][and][0][age]=lt:30&or[1][and][0][city]=nnull&age=lt:35", []bool{true, true, false}},
+		{"filter=(age:eq:25|age:eq:35),name:contains:o", []bool{false, false, true}},
+		{`filter=age:between:26\,40|!city:null`, []bool{true, true, true}},
+		{`filter=!(age:between:26\,40|name:jane smith)`, []bool{false, false, false}},
+		{"name:contains:J&filter=age:gt:25|city:eq:Chicago", []bool{true, false, true}},
+	}
+	for _, test := range tests {
+		group, err := filters.ParseQueryGroup(test.query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		for i, person := range people {
+			if got := group.Match(person); got != test.want[i] {
+				t.Errorf("%s: record %d: got %v, want %v", test.query, i, got, test.want[i])
+			}
+		}
+	}
+}
+
+func TestParseQueryGroupErrors(t *testing.T) {
+	for _, query := range []string{
+		"or[x][age]=eq:25",
+		"or[0]=eq:25",
+		"filter=(age:eq:25",
+		"filter=age:eq:25)",
+		"filter=age",
+		"or[0][age]=bogus:1",
+	} {
+		if _, err := filters.ParseQueryGroup(query); err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
+	}
+}
+
+func TestEncodeQueryRoundTrip(t *testing.T) {
+	when := time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)
+	list := []any{"a,b", `c\d`, "e:f"}
+	tests := []*filters.Filter{
+		filters.NewFilter("age", filters.Equal, 30),
+		filters.NewFilter("name", filters.NotEqual, "Jane & Joe"),
+		filters.NewFilter("age", filters.GreaterThan, 1.5),
+		filters.NewFilter("age", filters.GreaterThanEqual, 18),
+		filters.NewFilter("created", filters.LessThan, when),
+		filters.NewFilter("age", filters.LessThanEqual, 65),
+		filters.NewFilter("age", filters.Between, []int{18, 30}),
+		filters.NewFilter("city", filters.In, list),
+		filters.NewFilter("city", filters.NotIn, []string{"x", "y"}),
+		filters.NewFilter("name", filters.Contains, "a+b"),
+		filters.NewFilter("name", filters.NotContains, "?"),
+		filters.NewFilter("name", filters.StartsWith, "J"),
+		filters.NewFilter("name", filters.NotStartsWith, "J"),
+		filters.NewFilter("name", filters.EndsWith, "n"),
+		filters.NewFilter("name", filters.NotEndsWith, "n"),
+		filters.NewFilter("name", filters.ContainsCS, "Doe"),
+		filters.NewFilter("name", filters.NotContainsCS, "Doe"),
+		filters.NewFilter("name", filters.StartsWithCS, "J"),
+		filters.NewFilter("name", filters.NotStartsWithCS, "J"),
+		filters.NewFilter("name", filters.EndsWithCS, "e"),
+		filters.NewFilter("name", filters.NotEndsWithCS, "e"),
+		filters.NewFilter("name", filters.Pattern, `^J\w+, (Doe|Smith)$`),
+		filters.NewFilter("age", filters.Expression, "age > 20 && age < 40"),
+		filters.NewFilter("tags", filters.EqualCount, 2),
+		filters.NewFilter("tags", filters.NotEqualCount, 2),
+		filters.NewFilter("tags", filters.GreaterThanCount, 0),
+		filters.NewFilter("tags", filters.LesserThanCount, 3),
+		filters.NewFilter("tags", filters.GreaterThanEqualCount, 1),
+		filters.NewFilter("tags", filters.LesserThanEqualCount, 1),
+		filters.NewFilter("city", filters.IsNull, nil),
+		filters.NewFilter("city", filters.NotNull, nil),
+		filters.NewFilter("age", filters.IsZero, nil),
+		filters.NewFilter("age", filters.NotZero, nil),
+		filters.NewFilter("tags", filters.Any, filters.NewFilterGroup(filters.OR, false,
+			filters.NewFilter("$", filters.Equal, "a,b"),
+			&filters.Filter{Field: "$", Operator: filters.In, Value: []string{"c", "d"}, Reverse: true},
+		)),
+		{Field: "age", Operator: filters.Between, Value: []any{"{{min}}", "{{max}}"}, Reverse: true},
+	}
+	for _, filter := range tests {
+		encoded := filters.EncodeQuery([]*filters.Filter{filter})
+		parsed, err := filters.ParseQuery(encoded)
+		if err != nil {
+			t.Fatalf("%s: %v", encoded, err)
+		}
+		if len(parsed) != 1 {
+			t.Fatalf("%s: parsed %d filters", encoded, len(parsed))
+		}
+		got := parsed[0]
+		if got.Field != filter.Field || got.Operator != filter.Operator || got.Reverse != filter.Reverse {
+			t.Errorf("%s: got %s %s reverse=%v", encoded, got.Field, got.Operator, got.Reverse)
+		}
+		if again := filters.EncodeQuery(parsed); again != encoded {
+			t.Errorf("re-encoded %s as %s", encoded, again)
+		}
+	}
+}
+
+func TestEncodeQueryMatchesLikeTheFilters(t *testing.T) {
+	original := []*filters.Filter{
+		filters.NewFilter("name", filters.Contains, "o"),
+		filters.NewFilter("age", filters.Between, []any{26, 40}),
+		filters.NewFilter("tags", filters.Any, filters.NewFilter("$", filters.In, []string{"a", "c"})),
+	}
+	encoded := filters.EncodeQuery(original)
+	if want := "age:between:26,40&name:contains:o&tags:any:$:in:a%5C,c"; encoded != want {
+		t.Errorf("got %s", encoded)
+	}
+	parsed, err := filters.ParseQuery(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, person := range people {
+		want := filters.ApplyGroup([]map[string]any{person}, filters.NewFilterGroup(filters.AND, false, conditions(original)...))
+		got := filters.ApplyGroup([]map[string]any{person}, filters.NewFilterGroup(filters.AND, false, conditions(parsed)...))
+		if len(got) != len(want) {
+			t.Errorf("record %d: got %d matches, want %d", i, len(got), len(want))
+		}
+	}
+}
+
+func TestEncodeQueryGroup(t *testing.T) {
+	group := filters.NewFilterGroup(filters.AND, false,
+		filters.NewFilterGroup(filters.OR, false,
+			filters.NewFilter("age", filters.Equal, 25),
+			filters.NewFilter("age", filters.Equal, 35),
+		),
+		&filters.Filter{Field: "name", Operator: filters.Contains, Value: "smith", Reverse: true},
+		filters.NewFilterGroup(filters.AND, true, filters.NewFilter("city", filters.IsNull, nil)),
+	)
+	encoded, err := filters.EncodeQueryGroup(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "filter=(age:eq:25%7Cage:eq:35),!name:contains:smith,!(city:null)"; encoded != want {
+		t.Errorf("got %s, want %s", encoded, want)
+	}
+	parsed, err := filters.ParseQueryGroup(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, person := range people {
+		if got, want := parsed.Match(person), group.Match(person); got != want {
+			t.Errorf("record %d: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func conditions(list []*filters.Filter) []filters.Condition {
+	result := make([]filters.Condition, len(list))
+	for i, filter := range list {
+		result[i] = filter
+	}
+	return result
+}
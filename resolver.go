@@ -0,0 +1,230 @@
+package filters
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/oarkflow/dipper"
+)
+
+// FieldResolver reads a possibly dotted field from a record.
+type FieldResolver interface {
+	Resolve(item any, field string) (any, error)
+}
+
+// FieldResolverFunc adapts a function to FieldResolver.
+type FieldResolverFunc func(item any, field string) (any, error)
+
+func (f FieldResolverFunc) Resolve(item any, field string) (any, error) {
+	return f(item, field)
+}
+
+var resolvers sync.Map // reflect.Type -> FieldResolver
+
+// RegisterFieldResolver makes resolver read the fields of values of the
+// same type as sample, wherever such a value is met along a field path. The
+// resolver is passed the value and the rest of the path.
+func RegisterFieldResolver(sample any, resolver FieldResolver) {
+	resolvers.Store(reflect.TypeOf(sample), resolver)
+}
+
+// DefaultResolver is the FieldResolver used unless one is registered for a
+// type. It reads map keys, slice indexes and struct fields, where a struct
+// field is named by its filter tag, else its json tag, else its Go name,
+// and fields of embedded structs are promoted as in encoding/json. JSON
+// documents given as a string or []byte, and paths using the # and [key=value]
+// array forms, are read with dipper.
+var DefaultResolver FieldResolver = FieldResolverFunc(resolvePath)
+
+var errNoField = errors.New("no such field")
+
+func resolvePath(item any, field string) (any, error) {
+	switch item.(type) {
+	case string, []byte:
+		return dipper.Get(item, field)
+	}
+	if strings.ContainsAny(field, "#[") {
+		return dipper.Get(item, field)
+	}
+	current := item
+	segments := strings.Split(field, ".")
+	for i, segment := range segments {
+		if current != nil {
+			if resolver, ok := resolvers.Load(reflect.TypeOf(current)); ok {
+				return resolver.(FieldResolver).Resolve(current, strings.Join(segments[i:], "."))
+			}
+		}
+		next, err := resolveSegment(current, segment)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, strings.Join(segments[:i+1], "."))
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// resolveSegment reads one step of a field path.
+func resolveSegment(item any, segment string) (any, error) {
+	// the common decoded JSON case needs no reflection
+	if m, ok := item.(map[string]any); ok {
+		value, ok := m[segment]
+		if !ok {
+			return nil, errNoField
+		}
+		return value, nil
+	}
+	value := reflect.ValueOf(item)
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil, errNoField
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return nil, errNoField
+		}
+		found := value.MapIndex(reflect.ValueOf(segment).Convert(value.Type().Key()))
+		if !found.IsValid() {
+			return nil, errNoField
+		}
+		return found.Interface(), nil
+	case reflect.Struct:
+		index, ok := structFields(value.Type()).lookup(segment)
+		if !ok {
+			return nil, errNoField
+		}
+		found, err := value.FieldByIndexErr(index)
+		if err != nil || !found.CanInterface() {
+			// a nil embedded pointer hides its fields
+			return nil, errNoField
+		}
+		return found.Interface(), nil
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(segment)
+		if err != nil || i < 0 || i >= value.Len() {
+			return nil, errNoField
+		}
+		return value.Index(i).Interface(), nil
+	}
+	return nil, errNoField
+}
+
+// structPlan maps the names a struct's fields can be reached by to their
+// index paths.
+type structPlan struct {
+	tagged map[string][]int
+	named  map[string][]int
+}
+
+var structPlans sync.Map // reflect.Type -> *structPlan
+
+func structFields(t reflect.Type) *structPlan {
+	if plan, ok := structPlans.Load(t); ok {
+		return plan.(*structPlan)
+	}
+	tagged, named := fieldIndexes{}, fieldIndexes{}
+	addStructFields(t, nil, map[reflect.Type]bool{}, tagged, named)
+	plan := &structPlan{tagged: tagged.dominant(), named: named.dominant()}
+	actual, _ := structPlans.LoadOrStore(t, plan)
+	return actual.(*structPlan)
+}
+
+// lookup prefers tag names over Go names.
+func (plan *structPlan) lookup(name string) ([]int, bool) {
+	if index, ok := plan.tagged[name]; ok {
+		return index, true
+	}
+	index, ok := plan.named[name]
+	return index, ok
+}
+
+// addStructFields collects the exported fields of t and of the structs it
+// embeds by tag name and Go name. Fields tagged "-" are left out, as
+// encoding/json leaves them out.
+func addStructFields(t reflect.Type, prefix []int, seen map[reflect.Type]bool, tagged, named fieldIndexes) {
+	if seen[t] {
+		return
+	}
+	seen[t] = true
+	defer delete(seen, t)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		index := append(prefix[:len(prefix):len(prefix)], i)
+		name := tagName(field)
+		if name == "-" {
+			continue
+		}
+		embedded := field.Type
+		if embedded.Kind() == reflect.Pointer {
+			embedded = embedded.Elem()
+		}
+		if field.Anonymous && name == "" && embedded.Kind() == reflect.Struct {
+			addStructFields(embedded, index, seen, tagged, named)
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name != "" {
+			tagged.put(name, index)
+		}
+		named.put(field.Name, index)
+	}
+}
+
+// fieldIndexes holds the shallowest fields found for each name.
+type fieldIndexes map[string][][]int
+
+func (f fieldIndexes) put(name string, index []int) {
+	existing := f[name]
+	switch {
+	case len(existing) == 0 || len(index) < len(existing[0]):
+		f[name] = [][]int{index}
+	case len(index) == len(existing[0]):
+		f[name] = append(existing, index)
+	}
+}
+
+// dominant keeps the names held by a single shallowest field, as promotion
+// does; names shared by fields at the same depth are ambiguous and dropped,
+// as encoding/json drops them.
+func (f fieldIndexes) dominant() map[string][]int {
+	names := make(map[string][]int, len(f))
+	for name, indexes := range f {
+		if len(indexes) == 1 {
+			names[name] = indexes[0]
+		}
+	}
+	return names
+}
+
+// tagName returns the name given to field by its filter or json tag.
+func tagName(field reflect.StructField) string {
+	for _, key := range []string{"filter", "json"} {
+		if tag, ok := field.Tag.Lookup(key); ok {
+			if name, _, _ := strings.Cut(tag, ","); name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// hasTagOption reports whether the filter tag of field lists option, as in
+// `filter:"salary,decimal"`.
+func hasTagOption(field reflect.StructField, option string) bool {
+	_, options, _ := strings.Cut(field.Tag.Get("filter"), ",")
+	for options != "" {
+		var current string
+		current, options, _ = strings.Cut(options, ",")
+		if current == option {
+			return true
+		}
+	}
+	return false
+}
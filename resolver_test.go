@@ -0,0 +1,134 @@
+package filters_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/oarkflow/filters"
+)
+
+type audit struct {
+	CreatedBy string `json:"created_by"`
+}
+
+type address struct {
+	City string `json:"city"`
+}
+
+type account struct {
+	*audit
+	ID       int               `json:"id"`
+	Name     string            `json:"name" filter:"full_name"`
+	Address  *address          `json:"address"`
+	Previous []address         `json:"previous"`
+	Limits   map[string]int    `json:"limits"`
+	Labels   map[string]string `json:"-"`
+	internal string
+}
+
+func TestDefaultResolverStructs(t *testing.T) {
+	acc := account{
+		audit:    &audit{CreatedBy: "ops"},
+		ID:       7,
+		Name:     "Jane",
+		Address:  &address{City: "Oslo"},
+		Previous: []address{{City: "Rome"}},
+		Limits:   map[string]int{"daily": 500},
+		Labels:   map[string]string{"tier": "gold"},
+		internal: "hidden",
+	}
+	tests := []struct {
+		field string
+		want  any
+	}{
+		{"id", 7},
+		{"ID", 7},
+		{"full_name", "Jane"},
+		{"Name", "Jane"},
+		{"address.city", "Oslo"},
+		{"previous.0.city", "Rome"},
+		{"limits.daily", 500},
+		{"created_by", "ops"},
+	}
+	for _, test := range tests {
+		for _, item := range []any{acc, &acc} {
+			got, err := filters.DefaultResolver.Resolve(item, test.field)
+			if err != nil || got != test.want {
+				t.Errorf("%s: got %v, %v, want %v", test.field, got, err, test.want)
+			}
+		}
+	}
+	for _, field := range []string{"name", "internal", "address.zip", "previous.3.city", "labels", "Labels.tier"} {
+		if got, err := filters.DefaultResolver.Resolve(acc, field); err == nil {
+			t.Errorf("%s: got %v, want an error", field, got)
+		}
+	}
+	// a nil embedded pointer hides the promoted fields
+	_, err := filters.NewFilter("created_by", filters.Equal, "ops").MatchE(account{}, filters.MatchOptions{Mode: filters.Strict})
+	if !errors.Is(err, filters.ErrFieldNotFound) {
+		t.Errorf("got %v, want ErrFieldNotFound", err)
+	}
+	if !filters.NewFilter("address.city", filters.Equal, "oslo").Match(acc) {
+		t.Errorf("filter should read json tag names from structs")
+	}
+}
+
+type home struct {
+	City string `filter:"city"`
+	Zip  string
+}
+
+type office struct {
+	City string `filter:"city"`
+	Zip  string `json:"zip"`
+}
+
+type contact struct {
+	home
+	office
+	Zip string `json:"postcode"`
+}
+
+func TestDefaultResolverAmbiguousFields(t *testing.T) {
+	c := contact{home: home{City: "Oslo", Zip: "0150"}, office: office{City: "Rome", Zip: "00100"}, Zip: "1000"}
+	tests := []struct {
+		field string
+		want  any
+	}{
+		{"zip", "00100"},
+		{"Zip", "1000"},
+		{"postcode", "1000"},
+	}
+	for _, test := range tests {
+		got, err := filters.DefaultResolver.Resolve(c, test.field)
+		if err != nil || got != test.want {
+			t.Errorf("%s: got %v, %v, want %v", test.field, got, err, test.want)
+		}
+	}
+	// both embedded structs declare city at the same depth
+	for _, field := range []string{"city", "City"} {
+		if got, err := filters.DefaultResolver.Resolve(c, field); err == nil {
+			t.Errorf("%s: got %v, want an error", field, got)
+		}
+	}
+}
+
+type upperKeys map[string]string
+
+func TestRegisterFieldResolver(t *testing.T) {
+	filters.RegisterFieldResolver(upperKeys{}, filters.FieldResolverFunc(func(item any, field string) (any, error) {
+		value, ok := item.(upperKeys)[strings.ToUpper(field)]
+		if !ok {
+			return nil, errors.New("missing")
+		}
+		return value, nil
+	}))
+	data := map[string]any{"headers": upperKeys{"CONTENT.TYPE": "json"}}
+	if got, err := filters.DefaultResolver.Resolve(data, "headers.content.type"); err != nil || got != "json" {
+		t.Errorf("got %v, %v", got, err)
+	}
+	if !filters.NewFilter("headers.content.type", filters.Equal, "json").Match(data) {
+		t.Errorf("filter should use the registered resolver")
+	}
+}
@@ -0,0 +1,529 @@
+package filters
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The RSQL/FIQL grammar, from lowest to highest precedence:
+//
+//	or         = and { "," and }
+//	and        = constraint { ";" constraint }
+//	constraint = "(" or ")" | comparison
+//	comparison = selector operator argument
+//	operator   = "==" | "!=" | "<" | "<=" | ">" | ">=" | "=" name "="
+//	argument   = value | "(" value { "," value } ")"
+//
+// Values are unquoted words or strings quoted with ' or " in which a
+// backslash escapes the next character. Unquoted numbers and true/false are
+// typed; everything else is a string.
+
+var rsqlOperators = map[string]Operator{
+	"==":    Equal,
+	"!=":    NotEqual,
+	"=gt=":  GreaterThan,
+	">":     GreaterThan,
+	"=ge=":  GreaterThanEqual,
+	">=":    GreaterThanEqual,
+	"=lt=":  LessThan,
+	"<":     LessThan,
+	"=le=":  LessThanEqual,
+	"<=":    LessThanEqual,
+	"=in=":  In,
+	"=out=": NotIn,
+}
+
+const rsqlReserved = "\"'();,=!~<> \t\r\n"
+
+// ParseRSQL converts an RSQL/FIQL expression such as
+// "name==Jane*;age=gt=25,city=in=(NY,LA)" into a condition. A * in the
+// argument of == or != is a wildcard: at the ends it maps onto StartsWith,
+// EndsWith or Contains, elsewhere onto an anchored Pattern. Comparisons keep
+// the semantics of this library, so == on strings is case-insensitive.
+func ParseRSQL(query string) (Condition, error) {
+	p := &rsqlParser{source: query}
+	condition, err := p.parseOr()
+	if err == nil && p.skipWhitespace() < len(query) {
+		err = p.errorAt(p.pos, p.pos+1, "';'", "','")
+	}
+	if err != nil {
+		return nil, SyntaxErrors{err}
+	}
+	return condition, nil
+}
+
+type rsqlParser struct {
+	source string
+	pos    int
+}
+
+func (p *rsqlParser) parseOr() (Condition, *SyntaxError) {
+	return p.parseSequence(OR, ',', p.parseAnd)
+}
+
+func (p *rsqlParser) parseAnd() (Condition, *SyntaxError) {
+	return p.parseSequence(AND, ';', p.parseConstraint)
+}
+
+func (p *rsqlParser) parseSequence(operator Boolean, separator byte, operand func() (Condition, *SyntaxError)) (Condition, *SyntaxError) {
+	var conditions []Condition
+	for {
+		condition, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		if !p.accept(separator) {
+			break
+		}
+	}
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	return NewFilterGroup(operator, false, conditions...), nil
+}
+
+func (p *rsqlParser) parseConstraint() (Condition, *SyntaxError) {
+	if !p.accept('(') {
+		return p.parseComparison()
+	}
+	condition, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept(')') {
+		return nil, p.errorAt(p.pos, p.pos+1, "')'")
+	}
+	return condition, nil
+}
+
+func (p *rsqlParser) parseComparison() (Condition, *SyntaxError) {
+	start := p.skipWhitespace()
+	field := p.word()
+	if field == "" {
+		return nil, p.errorAt(start, start+1, "selector")
+	}
+	start = p.skipWhitespace()
+	symbol := p.operator()
+	operator, ok := rsqlOperators[symbol]
+	if !ok {
+		if symbol == "" {
+			return nil, p.errorAt(start, start+1, "operator")
+		}
+		return nil, newSyntaxError(p.source, start, p.pos, fmt.Sprintf("unknown operator %s", symbol))
+	}
+	if operator == In || operator == NotIn {
+		values, err := p.parseArguments()
+		if err != nil {
+			return nil, err
+		}
+		return NewFilter(field, operator, values), nil
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := value.(string); ok && strings.Contains(s, "*") && (operator == Equal || operator == NotEqual) {
+		return wildcardFilter(field, s, "*", "", operator == NotEqual), nil
+	}
+	return NewFilter(field, operator, value), nil
+}
+
+// parseArguments reads a parenthesised list of values, or a single value.
+func (p *rsqlParser) parseArguments() ([]any, *SyntaxError) {
+	p.skipWhitespace()
+	if !p.accept('(') {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return []any{value}, nil
+	}
+	var values []any
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if p.accept(')') {
+			return values, nil
+		}
+		if !p.accept(',') {
+			return nil, p.errorAt(p.pos, p.pos+1, "','", "')'")
+		}
+	}
+}
+
+func (p *rsqlParser) parseValue() (any, *SyntaxError) {
+	start := p.skipWhitespace()
+	if start < len(p.source) && (p.source[start] == '\'' || p.source[start] == '"') {
+		return p.quoted()
+	}
+	word := p.word()
+	switch {
+	case word == "":
+		return nil, p.errorAt(start, start+1, "value")
+	case word == "true", word == "false":
+		return word == "true", nil
+	case isRSQLTyped(word):
+		if literal, err := numberLiteral(word); err == nil {
+			return literal, nil
+		}
+	}
+	return word, nil
+}
+
+// quoted reads a quoted string in which a backslash escapes the next
+// character.
+func (p *rsqlParser) quoted() (string, *SyntaxError) {
+	start := p.pos
+	quote := p.source[start]
+	var b strings.Builder
+	for i := start + 1; i < len(p.source); i++ {
+		switch p.source[i] {
+		case '\\':
+			if i+1 < len(p.source) {
+				i++
+				b.WriteByte(p.source[i])
+			}
+		case quote:
+			p.pos = i + 1
+			return b.String(), nil
+		default:
+			b.WriteByte(p.source[i])
+		}
+	}
+	return "", newSyntaxError(p.source, start, len(p.source), "unclosed string literal")
+}
+
+// word reads a run of unreserved characters.
+func (p *rsqlParser) word() string {
+	start := p.pos
+	for p.pos < len(p.source) && strings.IndexByte(rsqlReserved, p.source[p.pos]) < 0 {
+		p.pos++
+	}
+	return p.source[start:p.pos]
+}
+
+// operator reads a comparison operator without checking that it is known.
+func (p *rsqlParser) operator() string {
+	start := p.pos
+	s := p.source[start:]
+	switch {
+	case strings.HasPrefix(s, "=="), strings.HasPrefix(s, "!="), strings.HasPrefix(s, "<="), strings.HasPrefix(s, ">="):
+		p.pos += 2
+	case strings.HasPrefix(s, "<"), strings.HasPrefix(s, ">"):
+		p.pos++
+	case strings.HasPrefix(s, "="):
+		end := strings.IndexByte(s[1:], '=')
+		if end < 0 {
+			p.pos = len(p.source)
+		} else {
+			p.pos += end + 2
+		}
+	}
+	return p.source[start:p.pos]
+}
+
+func (p *rsqlParser) accept(c byte) bool {
+	if p.skipWhitespace() < len(p.source) && p.source[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *rsqlParser) skipWhitespace() int {
+	p.pos = skipWhitespace(p.source, p.pos)
+	return p.pos
+}
+
+// errorAt reports the unexpected input at source[pos:end], or the end of the
+// input.
+func (p *rsqlParser) errorAt(pos, end int, expected ...string) *SyntaxError {
+	pos, end = min(pos, len(p.source)), min(end, len(p.source))
+	return newSyntaxError(p.source, pos, end, "", expected...)
+}
+
+// ToRSQL converts condition into an RSQL expression. RSQL has no negation,
+// so reversed filters and groups are pushed down by inverting operators.
+// Patterns made of text and .* runs, as ParseRSQL and ParseSQL produce them
+// for wildcards, are written back as wildcards. Case-sensitive string
+// operators, other patterns, expressions, lookups, count and null operators,
+// field references and strings containing * cannot be expressed; they are
+// reported in a *TranslateError. Relative times are written as the instants
+// opts resolve them to.
+func ToRSQL(condition Condition, opts ...TranslateOptions) (string, error) {
+	b := &rsqlBuilder{clock: translateClock(opts)}
+	query := b.condition(condition, false)
+	if len(b.unsupported) > 0 {
+		return "", &TranslateError{Target: "rsql", Nodes: b.unsupported}
+	}
+	return query.text, nil
+}
+
+// rsqlInverse gives the complement of each operator that has one in RSQL.
+var rsqlInverse = map[Operator]Operator{
+	Equal:            NotEqual,
+	NotEqual:         Equal,
+	GreaterThan:      LessThanEqual,
+	LessThanEqual:    GreaterThan,
+	GreaterThanEqual: LessThan,
+	LessThan:         GreaterThanEqual,
+	In:               NotIn,
+	NotIn:            In,
+	Contains:         NotContains,
+	NotContains:      Contains,
+	StartsWith:       NotStartsWith,
+	NotStartsWith:    StartsWith,
+	EndsWith:         NotEndsWith,
+	NotEndsWith:      EndsWith,
+}
+
+var rsqlSymbols = map[Operator]string{
+	Equal:            "==",
+	NotEqual:         "!=",
+	GreaterThan:      "=gt=",
+	GreaterThanEqual: "=ge=",
+	LessThan:         "=lt=",
+	LessThanEqual:    "=le=",
+}
+
+// rsqlExpression is rendered RSQL together with the operator joining its
+// top-level terms, empty for a single comparison.
+type rsqlExpression struct {
+	text     string
+	operator Boolean
+}
+
+type rsqlBuilder struct {
+	// clock resolves relative times; it is nil without TranslateOptions.
+	clock       *clock
+	unsupported []Untranslatable
+}
+
+func (b *rsqlBuilder) fail(condition Condition, reason string) rsqlExpression {
+	b.unsupported = append(b.unsupported, Untranslatable{Condition: condition, Reason: reason})
+	return rsqlExpression{}
+}
+
+// condition renders condition, negated when negate is set.
+func (b *rsqlBuilder) condition(condition Condition, negate bool) rsqlExpression {
+	switch c := condition.(type) {
+	case CompiledCondition:
+		return b.condition(c.Source(), negate)
+	case *Filter:
+		return b.filter(c, negate != c.Reverse)
+	case *FilterGroup:
+		return b.sequence(c, c.Operator, negate != c.Reverse, c.Filters...)
+	case *Rule:
+		if c.Node == nil {
+			return b.fail(c, "rule has no condition")
+		}
+		if c.Next == nil {
+			return b.condition(c.Node, negate != c.Reverse)
+		}
+		return b.sequence(c, c.Operator, negate != c.Reverse, c.Node, c.Next)
+	case *Join:
+		if c.Left == nil || c.Right == nil {
+			return b.fail(c, "missing left or right filter group")
+		}
+		return b.sequence(c, c.Operator, negate != c.Reverse, c.Left, c.Right)
+	case nil:
+		return b.fail(c, "condition is nil")
+	default:
+		return b.fail(c, "custom conditions cannot be translated")
+	}
+}
+
+// sequence joins conditions with operator, applying De Morgan's laws when
+// negate is set.
+func (b *rsqlBuilder) sequence(node Condition, operator Boolean, negate bool, conditions ...Condition) rsqlExpression {
+	if operator != AND && operator != OR {
+		return b.fail(node, fmt.Sprintf("unsupported boolean operator: %s", operator))
+	}
+	if len(conditions) == 0 {
+		return b.fail(node, "empty groups cannot be translated")
+	}
+	switch {
+	case negate && operator == AND:
+		operator = OR
+	case negate:
+		operator = AND
+	}
+	if len(conditions) == 1 {
+		return b.condition(conditions[0], negate)
+	}
+	parts := make([]string, len(conditions))
+	for i, condition := range conditions {
+		expression := b.condition(condition, negate)
+		parts[i] = expression.text
+		if operator == AND && expression.operator == OR {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	separator := ","
+	if operator == AND {
+		separator = ";"
+	}
+	return rsqlExpression{text: strings.Join(parts, separator), operator: operator}
+}
+
+func (b *rsqlBuilder) filter(filter *Filter, negate bool) rsqlExpression {
+	if err := filter.Validate(); err != nil {
+		return b.fail(filter, err.Error())
+	}
+	filter, ok := b.clock.translate(filter)
+	if !ok {
+		return b.fail(filter, untranslatableRelative)
+	}
+	if filter.Lookup != nil {
+		return b.fail(filter, "lookups cannot be translated")
+	}
+	if _, isRef := reference(filter.Field); isRef || strings.ContainsAny(filter.Field, rsqlReserved) {
+		return b.fail(filter, "field cannot be used as an RSQL selector")
+	}
+	if hasReference(filter.Value) {
+		return b.fail(filter, "field references cannot be translated")
+	}
+	if filter.Operator == Between {
+		values := sqlValues(filter.Value)
+		from, ok1 := rsqlValue(values[0])
+		to, ok2 := rsqlValue(values[1])
+		if !ok1 || !ok2 {
+			return b.fail(filter, "value cannot be written in RSQL")
+		}
+		if negate {
+			return rsqlExpression{text: filter.Field + "=lt=" + from + "," + filter.Field + "=gt=" + to, operator: OR}
+		}
+		return rsqlExpression{text: filter.Field + "=ge=" + from + ";" + filter.Field + "=le=" + to, operator: AND}
+	}
+	if filter.Operator == Pattern {
+		value, ok := rsqlWildcard(filter.Value)
+		if !ok {
+			return b.fail(filter, "only wildcard patterns can be written in RSQL")
+		}
+		if negate {
+			return rsqlExpression{text: filter.Field + "!=" + rsqlQuote(value)}
+		}
+		return rsqlExpression{text: filter.Field + "==" + rsqlQuote(value)}
+	}
+	operator := filter.Operator
+	if negate {
+		inverse, ok := rsqlInverse[operator]
+		if !ok {
+			return b.fail(filter, "operator cannot be negated in RSQL")
+		}
+		operator = inverse
+	}
+	if symbol, ok := rsqlSymbols[operator]; ok {
+		value, ok := rsqlValue(filter.Value)
+		if !ok {
+			return b.fail(filter, "value cannot be written in RSQL")
+		}
+		return rsqlExpression{text: filter.Field + symbol + value}
+	}
+	switch operator {
+	case In, NotIn:
+		values := sqlValues(filter.Value)
+		args := make([]string, len(values))
+		for i, v := range values {
+			arg, ok := rsqlValue(v)
+			if !ok {
+				return b.fail(filter, "value cannot be written in RSQL")
+			}
+			args[i] = arg
+		}
+		symbol := "=in="
+		if operator == NotIn {
+			symbol = "=out="
+		}
+		return rsqlExpression{text: filter.Field + symbol + "(" + strings.Join(args, ",") + ")"}
+	}
+	if shape, ok := likeShapes[operator]; ok && !shape.caseSensitive {
+		value, ok := filter.Value.(string)
+		if !ok || value == "" || strings.Contains(value, "*") {
+			return b.fail(filter, "value cannot be written as an RSQL wildcard")
+		}
+		if shape.prefix {
+			value = "*" + value
+		}
+		if shape.suffix {
+			value += "*"
+		}
+		symbol := "=="
+		if shape.negated {
+			symbol = "!="
+		}
+		return rsqlExpression{text: filter.Field + symbol + rsqlQuote(value)}
+	}
+	return b.fail(filter, "operator has no RSQL equivalent")
+}
+
+// rsqlValue writes v as an RSQL argument that ParseRSQL reads back with the
+// same type.
+func rsqlValue(v any) (string, bool) {
+	switch val := v.(type) {
+	case string:
+		if strings.Contains(val, "*") {
+			return "", false
+		}
+		return rsqlQuote(val), true
+	case bool:
+		return strconv.FormatBool(val), true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+		return fmt.Sprint(val), true
+	case time.Time:
+		return val.Format(time.RFC3339Nano), true
+	}
+	return "", false
+}
+
+// rsqlWildcard recovers the wildcard argument from a pattern as
+// wildcardFilter writes it: an anchored, case-insensitive regular expression
+// made of quoted text and .* runs.
+func rsqlWildcard(v any) (string, bool) {
+	pattern, _ := v.(string)
+	body, ok := strings.CutPrefix(pattern, "(?i)^")
+	if !ok {
+		return "", false
+	}
+	if body, ok = strings.CutSuffix(body, "$"); !ok {
+		return "", false
+	}
+	const meta = `\.+*?()|[]{}^$`
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		switch c := body[i]; {
+		case strings.HasPrefix(body[i:], ".*"):
+			b.WriteByte('*')
+			i++
+		case c == '\\' && i+1 < len(body) && body[i+1] != '*' && strings.IndexByte(meta, body[i+1]) >= 0:
+			b.WriteByte(body[i+1])
+			i++
+		case strings.IndexByte(meta, c) >= 0:
+			return "", false
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), strings.Contains(b.String(), "*")
+}
+
+// rsqlQuote quotes s when it would otherwise be read as something else.
+func rsqlQuote(s string) string {
+	if s != "" && !isRSQLTyped(s) && !strings.ContainsAny(s, rsqlReserved+`\`) {
+		return s
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// isRSQLTyped reports whether an unquoted word would be read as a number or
+// a boolean.
+func isRSQLTyped(word string) bool {
+	return word == "true" || word == "false" || isNumber(word)
+}
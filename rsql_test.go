@@ -0,0 +1,140 @@
+package filters_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/oarkflow/filters"
+)
+
+func TestParseRSQL(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []bool
+	}{
+		{"age=gt=25;age=lt=35", []bool{true, false, false}},
+		{"age==25,city=in=(Chicago,'New York')", []bool{true, true, false}},
+		{"name==Jane*", []bool{false, true, false}},
+		{"name==*son", []bool{false, false, true}},
+		{"name!=*o*", []bool{false, true, false}},
+		{"name==J*e", []bool{true, false, false}},
+		{"age=out=(25,35);(city==\"New York\",age>=40)", []bool{true, false, false}},
+		{"name=='Jane Smith'", []bool{false, true, false}},
+	}
+	for _, test := range tests {
+		condition, err := filters.ParseRSQL(test.query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.query, err)
+		}
+		for i, person := range people {
+			if got := condition.Match(person); got != test.want[i] {
+				t.Errorf("%s: record %d: got %v, want %v", test.query, i, got, test.want[i])
+			}
+		}
+	}
+}
+
+func TestParseRSQLSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		query  string
+		column int
+	}{
+		{"age=gt=", 8},
+		{"age=like=5", 4},
+		{"age", 4},
+		{"(age==5", 8},
+		{"age==5;", 8},
+		{"name=='open", 7},
+		{"age=in=(1,2", 12},
+	}
+	for _, test := range tests {
+		_, err := filters.ParseRSQL(test.query)
+		var syntaxErr *filters.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%s: expected a SyntaxError, got %v", test.query, err)
+			continue
+		}
+		if syntaxErr.Column != test.column {
+			t.Errorf("%s: column = %d, want %d (%v)", test.query, syntaxErr.Column, test.column, err)
+		}
+	}
+}
+
+func TestToRSQL(t *testing.T) {
+	condition := filters.NewFilterGroup(filters.AND, false,
+		filters.NewFilter("age", filters.Between, []any{18, 30}),
+		filters.NewFilter("name", filters.StartsWith, "J"),
+		filters.NewFilter("city", filters.In, []string{"New York", "LA"}),
+		filters.NewFilter("code", filters.Equal, "42"),
+		filters.NewFilterGroup(filters.AND, true,
+			filters.NewFilter("age", filters.GreaterThan, 21),
+			&filters.Filter{Field: "name", Operator: filters.Contains, Value: "o'b", Reverse: true},
+		),
+	)
+	got, err := filters.ToRSQL(condition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `age=ge=18;age=le=30;name==J*;city=in=('New York',LA);code=='42';(age=le=21,name=='*o\'b*')`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+	if _, err := filters.ParseRSQL(got); err != nil {
+		t.Errorf("round trip: %v", err)
+	}
+}
+
+func TestRSQLRoundTrip(t *testing.T) {
+	for _, query := range []string{
+		"age=gt=25;age=lt=35",
+		"age==25,city=in=(Chicago,'New York')",
+		"name==Jane*;name!=*son",
+		"name==J*n*e",
+		"name!=J*n*h",
+		"name=='J. *Doe (x)'",
+		"age=out=(25,35);(city==\"New York\",age>=40)",
+	} {
+		condition, err := filters.ParseRSQL(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		exported, err := filters.ToRSQL(condition)
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+			continue
+		}
+		reparsed, err := filters.ParseRSQL(exported)
+		if err != nil {
+			t.Errorf("%s: exported %s: %v", query, exported, err)
+			continue
+		}
+		if again, err := filters.ToRSQL(reparsed); err != nil || again != exported {
+			t.Errorf("%s: exported %s, then %s, %v", query, exported, again, err)
+		}
+		for i, person := range people {
+			if got, want := reparsed.Match(person), condition.Match(person); got != want {
+				t.Errorf("%s: exported %s: record %d: got %v, want %v", query, exported, i, got, want)
+			}
+		}
+	}
+	if _, err := filters.ToRSQL(filters.NewFilter("name", filters.Pattern, "^J[a-z]+$")); err == nil {
+		t.Error("expected a general pattern to be untranslatable")
+	}
+}
+
+func TestToRSQLReportsUntranslatableNodes(t *testing.T) {
+	condition := filters.NewFilterGroup(filters.OR, false,
+		filters.NewFilter("name", filters.StartsWithCS, "J"),
+		filters.NewFilter("name", filters.Equal, "a*b"),
+		filters.NewFilter("city", filters.IsNull, nil),
+		filters.NewFilter("age", filters.Equal, 30),
+	)
+	_, err := filters.ToRSQL(condition)
+	var translateErr *filters.TranslateError
+	if !errors.As(err, &translateErr) {
+		t.Fatalf("expected a TranslateError, got %v", err)
+	}
+	if len(translateErr.Nodes) != 3 {
+		t.Errorf("expected 3 untranslatable nodes, got %d", len(translateErr.Nodes))
+	}
+}
@@ -1,10 +1,14 @@
 package filters
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math/big"
 	"regexp"
-	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/oarkflow/filters/utils"
 )
@@ -13,6 +17,55 @@ type Condition interface {
 	Match(data any) bool
 }
 
+// ErrorMatcher is implemented by conditions that can report why an
+// evaluation failed instead of silently returning false.
+type ErrorMatcher interface {
+	MatchE(data any, opts ...MatchOptions) (bool, error)
+}
+
+// MatchMode controls how MatchE treats fields missing from the data.
+type MatchMode int
+
+const (
+	// Lenient treats a missing field as a non-match.
+	Lenient MatchMode = iota
+	// Strict reports a missing field as ErrFieldNotFound.
+	Strict
+)
+
+// MatchOptions configures a single MatchE evaluation.
+type MatchOptions struct {
+	Mode MatchMode
+	// Decimal rounds the operands of comparisons involving a decimal to a
+	// fixed scale before comparing them. Without it decimals compare exactly.
+	Decimal *DecimalOptions
+	// Location is the zone of dates written without one, of today() and of
+	// the calendar date operators. It defaults to UTC.
+	Location *time.Location
+	// Now returns the current time for now(), today(), within_last,
+	// within_next and age_between. It defaults to time.Now.
+	Now func() time.Time
+	// scope holds the items enclosing the element a quantifier is
+	// evaluating.
+	scope *scope
+}
+
+func matchOptions(opts []MatchOptions) MatchOptions {
+	if len(opts) > 0 {
+		return opts[0]
+	}
+	return MatchOptions{}
+}
+
+// MatchE evaluates condition against data, reporting errors when the
+// condition supports it.
+func MatchE(condition Condition, data any, opts ...MatchOptions) (bool, error) {
+	if matcher, ok := condition.(ErrorMatcher); ok {
+		return matcher.MatchE(data, opts...)
+	}
+	return condition.Match(data), nil
+}
+
 type Rule struct {
 	Node          Condition `json:"node"`
 	Operator      Boolean   `json:"operator"`
@@ -57,6 +110,30 @@ func (r *Rule) match(data any) bool {
 	return matchedNext
 }
 
+// MatchE evaluates the rule like Match but reports why evaluation failed.
+func (r *Rule) MatchE(data any, opts ...MatchOptions) (bool, error) {
+	if r.Node == nil {
+		return false, errors.New("rule has no condition")
+	}
+	matched, err := MatchE(r.Node, data, opts...)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case r.Next == nil:
+	case r.Operator == AND && !matched:
+	case r.Operator == OR && matched:
+	default:
+		if matched, err = MatchE(r.Next, data, opts...); err != nil {
+			return false, err
+		}
+	}
+	if r.Reverse {
+		return !matched, nil
+	}
+	return matched, nil
+}
+
 // AddCondition method to add new conditions to the sequence
 func (r *Rule) AddCondition(operator Boolean, reverse bool, conditions ...Condition) {
 	var condition Condition
@@ -86,19 +163,26 @@ func (r *Rule) AddCondition(operator Boolean, reverse bool, conditions ...Condit
 	}
 }
 
+// ParseSQL parses a SQL WHERE clause, with or without a leading
+// SELECT ... WHERE, into a Rule. NOT binds tighter than AND, which binds
+// tighter than OR, and parentheses group terms to any depth.
+//
+// Syntax errors are returned as SyntaxErrors, with one *SyntaxError for every
+// malformed term in the clause.
 func ParseSQL(sql string) (*Rule, error) {
-	sql = splitByWhere(sql)
-	tokens, err := tokenize(sql)
+	start, end := splitByWhere(sql)
+	tokens, err := tokenize(sql[:end], start)
 	if err != nil {
-		return nil, err
+		return nil, SyntaxErrors{err}
 	}
-
-	filterGroup, _, err := parseFilterGroup(tokens)
-	if err != nil {
-		return nil, err
+	p := &parser{tokens: tokens, source: sql}
+	condition := p.parseWhere()
+	if len(p.errs) > 0 {
+		return nil, p.errs
 	}
-	filterGroup.Condition = sql
-	return filterGroup, nil
+	rule := NewRuleNode(AND, condition)
+	rule.Condition = sql[start:end]
+	return rule, nil
 }
 
 func FilterCondition[T any](data []T, expr Condition) (result []T) {
@@ -122,11 +206,16 @@ const (
 	tokenKeyword    tokenType = "KEYWORD"
 	tokenComma      tokenType = "COMMA"
 	tokenVariable   tokenType = "VARIABLE"
+	tokenColon      tokenType = "COLON"
 )
 
 type token struct {
 	typ   tokenType
 	value string
+	// literal is the typed value of a tokenValue token.
+	literal any
+	// pos and end are the byte offsets of the token in the parsed input.
+	pos, end int
 }
 
 var (
@@ -150,59 +239,85 @@ func isOperator(s string) bool {
 	return operators[strings.ToUpper(s)]
 }
 
-func tokenize(input string) ([]token, error) {
+// tokenize splits input into tokens, starting at byte offset start.
+func tokenize(input string, start int) ([]token, *SyntaxError) {
 	var tokens []token
-	input = strings.TrimSpace(input)
+	emit := func(typ tokenType, value string, literal any, pos, end int) {
+		tokens = append(tokens, token{typ: typ, value: value, literal: literal, pos: pos, end: end})
+	}
 
-	for i := 0; i < len(input); {
+	for i := start; i < len(input); {
 		switch r := input[i]; {
 		case isWhitespace(r):
 			i++
 		case r == '(', r == ')', r == ',':
-			tokens = append(tokens, token{typ: runeToTokenType(r), value: string(r)})
+			emit(runeToTokenType(r), string(r), nil, i, i+1)
 			i++
 		case r == '\'':
-			value, newIndex, err := parseStringLiteral(input, i)
+			value, newIndex, err := parseQuoted(input, i)
+			if err != nil {
+				return nil, newSyntaxError(input, i, len(input), "unclosed string literal")
+			}
+			emit(tokenValue, value, value, i, newIndex)
+			i = newIndex
+		case r == '"', r == '`':
+			value, newIndex, err := parseQuoted(input, i)
 			if err != nil {
-				return nil, err
+				return nil, newSyntaxError(input, i, len(input), "unclosed quoted identifier")
 			}
-			tokens = append(tokens, token{typ: tokenValue, value: value})
+			emit(tokenIdentifier, value, nil, i, newIndex)
 			i = newIndex
 		case r == '{' && i+1 < len(input) && input[i+1] == '{':
 			value, newIndex, err := parseVariable(input, i)
 			if err != nil {
-				return nil, err
+				return nil, newSyntaxError(input, i, len(input), err.Error())
 			}
-			tokens = append(tokens, token{typ: tokenVariable, value: "{{" + value + "}}"})
+			emit(tokenVariable, "{{"+value+"}}", nil, i, newIndex)
 			i = newIndex
-		case isDigit(r):
+		case isDigit(r), r == '-' && i+1 < len(input) && isDigit(input[i+1]):
 			value, newIndex := parseIdentifierOrKeyword(input, i)
-			if utils.IsValidDateTime(value) {
-				tokens = append(tokens, token{typ: tokenValue, value: strings.ToUpper(value)})
+			if isDateLiteral(value) {
+				emit(tokenValue, strings.ToUpper(value), strings.ToUpper(value), i, newIndex)
 			} else {
 				value, newIndex = parseNumber(input, i)
-				tokens = append(tokens, token{typ: tokenValue, value: value})
+				literal, err := numberLiteral(value)
+				if err != nil {
+					return nil, newSyntaxError(input, i, newIndex, "invalid number "+value)
+				}
+				emit(tokenValue, value, literal, i, newIndex)
 			}
 			i = newIndex
 		case isOperatorStart(r):
 			value, newIndex := parseOperator(input, i)
-			tokens = append(tokens, token{typ: tokenOperator, value: value})
+			emit(tokenOperator, value, nil, i, newIndex)
 			i = newIndex
 			value, newIndex = parseIdentifierOrKeyword(input, i)
-			if utils.IsValidDateTime(value) {
-				tokens = append(tokens, token{typ: tokenValue, value: strings.ToUpper(value)})
+			if isDateLiteral(value) {
+				emit(tokenValue, strings.ToUpper(value), strings.ToUpper(value), i, newIndex)
 				i = newIndex
 			}
 		default:
 			value, newIndex := parseIdentifierOrKeyword(input, i)
-			if isKeyword(value) {
-				tokens = append(tokens, token{typ: tokenKeyword, value: strings.ToUpper(value)})
-			} else if utils.IsValidDateTime(value) {
-				tokens = append(tokens, token{typ: tokenValue, value: strings.ToUpper(value)})
+			upper := strings.ToUpper(value)
+			if quote := skipWhitespace(input, newIndex); typedLiterals[upper] && quote < len(input) && input[quote] == '\'' {
+				text, end, err := parseQuoted(input, quote)
+				if err != nil {
+					return nil, newSyntaxError(input, quote, len(input), "unclosed string literal")
+				}
+				literal, err := typedLiteral(upper, text)
+				if err != nil {
+					return nil, newSyntaxError(input, i, end, err.Error())
+				}
+				emit(tokenValue, text, literal, i, end)
+				newIndex = end
+			} else if isKeyword(value) {
+				emit(tokenKeyword, upper, nil, i, newIndex)
+			} else if isDateLiteral(value) {
+				emit(tokenValue, upper, upper, i, newIndex)
 			} else if isBoolean(value) {
-				tokens = append(tokens, token{typ: tokenValue, value: strings.ToUpper(value)})
+				emit(tokenValue, upper, upper == "TRUE", i, newIndex)
 			} else {
-				tokens = append(tokens, token{typ: tokenIdentifier, value: value})
+				emit(tokenIdentifier, value, nil, i, newIndex)
 			}
 			i = newIndex
 		}
@@ -226,7 +341,7 @@ func runeToTokenType(r byte) tokenType {
 }
 
 func isWhitespace(r byte) bool {
-	return r == ' ' || r == '\t' || r == '\n'
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
 }
 
 func isDigit(r byte) bool {
@@ -234,18 +349,27 @@ func isDigit(r byte) bool {
 }
 
 func isOperatorStart(r byte) bool {
-	return isOperator(string(r))
+	return strings.IndexByte("=!<>", r) >= 0
 }
 
-func parseStringLiteral(input string, start int) (string, int, error) {
-	i := start + 1
-	for i < len(input) && input[i] != '\'' {
-		i++
-	}
-	if i < len(input) && input[i] == '\'' {
-		return input[start+1 : i], i + 1, nil
+// parseQuoted reads a literal or identifier enclosed in the quote character
+// at start. A doubled quote character stands for the quote itself.
+func parseQuoted(input string, start int) (string, int, error) {
+	quote := input[start]
+	var b strings.Builder
+	for i := start + 1; i < len(input); i++ {
+		if input[i] != quote {
+			b.WriteByte(input[i])
+			continue
+		}
+		if i+1 < len(input) && input[i+1] == quote {
+			b.WriteByte(quote)
+			i++
+			continue
+		}
+		return b.String(), i + 1, nil
 	}
-	return "", 0, errors.New("unclosed string literal")
+	return "", 0, errors.New("unclosed quoted text")
 }
 
 func parseVariable(input string, start int) (string, int, error) {
@@ -259,17 +383,106 @@ func parseVariable(input string, start int) (string, int, error) {
 	return "", 0, errors.New("unclosed variable")
 }
 
+// parseNumber reads an optionally signed integer or decimal number with an
+// optional exponent.
 func parseNumber(input string, start int) (string, int) {
 	i := start
-	for i < len(input) && isDigit(input[i]) {
+	if input[i] == '-' {
+		i++
+	}
+	digits := func() {
+		for i < len(input) && isDigit(input[i]) {
+			i++
+		}
+	}
+	digits()
+	if i+1 < len(input) && input[i] == '.' && isDigit(input[i+1]) {
 		i++
+		digits()
+	}
+	if i+1 < len(input) && (input[i] == 'e' || input[i] == 'E') {
+		j := i + 1
+		if input[j] == '+' || input[j] == '-' {
+			j++
+		}
+		if j < len(input) && isDigit(input[j]) {
+			i = j
+			digits()
+		}
 	}
 	return input[start:i], i
 }
 
+// isNumber reports whether word is exactly one number as read by parseNumber.
+func isNumber(word string) bool {
+	if word == "" || !(isDigit(word[0]) || (len(word) > 1 && word[0] == '-' && isDigit(word[1]))) {
+		return false
+	}
+	_, end := parseNumber(word, 0)
+	return end == len(word)
+}
+
+// numberLiteral converts a number token to int64. Numbers with a fraction or
+// exponent, and integers too large for int64, are kept exactly as a
+// json.Number so that decimals are never rounded through a float.
+func numberLiteral(s string) (any, error) {
+	if !strings.ContainsAny(s, ".eE") {
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err == nil {
+			return i, nil
+		}
+		if errors.Is(err, strconv.ErrRange) {
+			return json.Number(s), nil
+		}
+		return nil, err
+	}
+	if _, err := strconv.ParseFloat(s, 64); err != nil && !errors.Is(err, strconv.ErrRange) {
+		return nil, err
+	}
+	return json.Number(s), nil
+}
+
+// typedLiterals are the keywords that type the string literal following them.
+var typedLiterals = map[string]bool{"DATE": true, "TIMESTAMP": true, "DECIMAL": true, "INTERVAL": true}
+
+func typedLiteral(kind, text string) (any, error) {
+	switch kind {
+	case "DATE":
+		t, err := time.Parse(time.DateOnly, text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DATE literal %q", text)
+		}
+		return t, nil
+	case "TIMESTAMP":
+		t, err := utils.ParseTime(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TIMESTAMP literal %q", text)
+		}
+		return t, nil
+	case "INTERVAL":
+		d, err := utils.ParseDuration(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid INTERVAL literal %q", text)
+		}
+		return d, nil
+	default:
+		if _, ok := new(big.Rat).SetString(text); !ok {
+			return nil, fmt.Errorf("invalid DECIMAL literal %q", text)
+		}
+		return json.Number(text), nil
+	}
+}
+
+func skipWhitespace(input string, i int) int {
+	for i < len(input) && isWhitespace(input[i]) {
+		i++
+	}
+	return i
+}
+
 func parseOperator(input string, start int) (string, int) {
 	i := start
-	for i < len(input) && isOperator(string(input[i])) {
+	for i < len(input) && isOperatorStart(input[i]) {
 		i++
 	}
 	return input[start:i], i
@@ -283,6 +496,13 @@ func parseIdentifierOrKeyword(input string, start int) (string, int) {
 	return input[start:i], i
 }
 
+// isDateLiteral reports whether an unquoted word is a date or time. Only
+// words with date punctuation qualify, so that numbers such as 2024 or
+// 20240101 stay numbers; DATE '...' types any other spelling.
+func isDateLiteral(s string) bool {
+	return strings.ContainsAny(strings.TrimPrefix(s, "-"), "-/:") && utils.IsValidDateTime(s)
+}
+
 func isBoolean(s string) bool {
 	return strings.ToUpper(s) == "TRUE" || strings.ToUpper(s) == "FALSE"
 }
@@ -294,7 +514,7 @@ func combineCompoundOperators(tokens []token) []token {
 			if i+1 < len(tokens) && tokens[i+1].typ == tokenKeyword {
 				compoundOperator := strings.ToUpper(tokens[i].value + " " + tokens[i+1].value)
 				if isOperator(compoundOperator) {
-					result = append(result, token{typ: tokenOperator, value: compoundOperator})
+					result = append(result, token{typ: tokenOperator, value: compoundOperator, pos: tokens[i].pos, end: tokens[i+1].end})
 					i++
 					continue
 				}
@@ -304,14 +524,14 @@ func combineCompoundOperators(tokens []token) []token {
 			if i+1 < len(tokens) && tokens[i+1].typ == tokenKeyword {
 				compoundOperator := strings.ToUpper(tokens[i].value + " " + tokens[i+1].value)
 				if isOperator(compoundOperator) {
-					result = append(result, token{typ: tokenOperator, value: compoundOperator})
+					result = append(result, token{typ: tokenOperator, value: compoundOperator, pos: tokens[i].pos, end: tokens[i+1].end})
 					i++
 					continue
 				}
 				if strings.ToUpper(tokens[i+1].value) == "NOT" && i+2 < len(tokens) && tokens[i+2].typ == tokenKeyword {
 					compoundOperator = strings.ToUpper(tokens[i].value + " " + tokens[i+1].value + " " + tokens[i+2].value)
 					if isOperator(compoundOperator) {
-						result = append(result, token{typ: tokenOperator, value: compoundOperator})
+						result = append(result, token{typ: tokenOperator, value: compoundOperator, pos: tokens[i].pos, end: tokens[i+2].end})
 						i += 2
 						continue
 					}
@@ -326,6 +546,8 @@ func combineCompoundOperators(tokens []token) []token {
 type parser struct {
 	tokens []token
 	pos    int
+	source string
+	errs   SyntaxErrors
 }
 
 func (p *parser) nextToken() (token, bool) {
@@ -377,118 +599,6 @@ func toOperator(sqlOperator string) Operator {
 	}
 }
 
-func parseFilter(tokens []token) (*Filter, Boolean, int, error) {
-	p := &parser{tokens: tokens}
-
-	tok, ok := p.nextToken()
-	if !ok || (tok.typ == tokenKeyword && slices.Contains([]Boolean{AND, OR}, Boolean(tok.value))) {
-		return nil, Boolean(tok.value), p.pos, nil
-	}
-	if tok.typ != tokenIdentifier {
-		return nil, "", 0, errors.New("expected field name")
-	}
-	field := tok.value
-
-	tok, ok = p.nextToken()
-	if !ok {
-		return nil, "", 0, errors.New("unexpected error")
-	}
-	if tok.typ == tokenOperator {
-		operator := toOperator(tok.value)
-		var value any
-		if operator != IsNull && operator != NotNull {
-			tok, ok = p.nextToken()
-			if !ok || (tok.typ != tokenValue && tok.typ != tokenIdentifier && tok.typ != tokenVariable) {
-				return nil, "", 0, errors.New("expected value")
-			}
-			value = tok.value
-		}
-		if operator == NotContains {
-			val := value.(string)
-			val = strings.Trim(val, "%")
-			switch {
-			case strings.HasPrefix(val, "%") && strings.HasSuffix(val, "%"):
-				return NewFilter(field, NotContains, val), "", p.pos, nil
-			case strings.HasPrefix(val, "%"):
-				return NewFilter(field, NotEndsWith, val), "", p.pos, nil
-			case strings.HasSuffix(val, "%"):
-				return NewFilter(field, NotStartsWith, val), "", p.pos, nil
-			}
-		}
-		return NewFilter(field, operator, value), "", p.pos, nil
-	}
-
-	if tok.typ == tokenKeyword {
-		switch tok.value {
-		case "BETWEEN":
-			return parseBetween(p, field)
-		case "LIKE":
-			return parseLike(p, field)
-		case "IN":
-			return parseIn(p, field)
-		case "NOT":
-			return parseNot(p, field)
-		}
-	}
-
-	return nil, "", 0, errors.New("unexpected token")
-}
-
-func parseFilterGroup(tokens []token) (*Rule, int, error) {
-	p := &parser{tokens: tokens}
-	seq := &Rule{}
-	var operator Boolean
-
-	for {
-		tok, ok := p.peekToken()
-		if !ok {
-			break
-		}
-
-		if tok.typ == tokenBoolean {
-			p.nextToken() // consume the boolean token
-			operator = Boolean(strings.ToUpper(tok.value))
-			seq.Operator = operator
-			continue
-		}
-
-		if tok.typ == tokenLParen {
-			p.nextToken() // consume '('
-			group, consumed, err := parseFilterGroup(tokens[p.pos:])
-			if err != nil {
-				return nil, 0, err
-			}
-			p.pos += consumed
-			if seq.Node == nil {
-				seq.Node = group
-			} else {
-				seq.Next = group
-			}
-			continue
-		}
-
-		if tok.typ == tokenRParen {
-			p.nextToken() // consume ')'
-			break
-		}
-		filter, ops, consumed, err := parseFilter(tokens[p.pos:])
-		if err != nil {
-			return nil, 0, err
-		}
-		p.pos += consumed
-		if ops != "" {
-			seq.Operator = ops
-		} else {
-			if seq.Node == nil {
-				seq.Node = filter
-			} else {
-				seq.Next = filter
-			}
-		}
-	}
-	return seq, p.pos, nil
-}
-
 func FirstTermFilter(seq *Rule) (*Filter, error) {
 	if seq == nil {
 		return nil, errors.New("sequence is nil")
@@ -499,9 +609,18 @@ func FirstTermFilter(seq *Rule) (*Filter, error) {
 	traverse = func(node any) *Filter {
 		switch n := node.(type) {
 		case *Filter:
-			if n.Operator == Equal {
+			if n.Operator == Equal && !n.Reverse {
 				return n
 			}
+		case *FilterGroup:
+			if n.Reverse {
+				return nil
+			}
+			for _, condition := range n.Filters {
+				if filter := traverse(condition); filter != nil {
+					return filter
+				}
+			}
 		case *Rule:
 			if n.Node != nil {
 				if filter := traverse(n.Node); filter != nil {
@@ -532,16 +651,23 @@ var (
 	re = regexp.MustCompile(`(?i)\bWHERE\b`)
 )
 
-func splitByWhere(sql string) string {
-	loc := re.FindStringIndex(sql)
-	if loc == nil {
-		return sql
+// splitByWhere returns the byte range of the condition in sql: the text after
+// a leading SELECT ... WHERE, or all of sql when there is none. A WHERE
+// after a parenthesis or quote belongs to a subquery or a literal.
+func splitByWhere(sql string) (int, int) {
+	start, end := 0, len(sql)
+	if loc := re.FindStringIndex(sql); loc != nil && !strings.ContainsAny(sql[:loc[0]], "('\"`") {
+		if strings.TrimSpace(sql[loc[1]:]) != "" {
+			start = loc[1]
+		} else {
+			end = loc[0]
+		}
 	}
-
-	beforeWhere := strings.TrimSpace(sql[:loc[0]])
-	afterWhere := strings.TrimSpace(sql[loc[1]:])
-	if afterWhere != "" {
-		return afterWhere
+	for start < end && isWhitespace(sql[start]) {
+		start++
+	}
+	for end > start && isWhitespace(sql[end-1]) {
+		end--
 	}
-	return beforeWhere
+	return start, end
 }
@@ -0,0 +1,524 @@
+package filters
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oarkflow/filters/utils"
+)
+
+// FieldType is the type a Schema declares for a field.
+type FieldType string
+
+const (
+	StringField FieldType = "string"
+	IntField    FieldType = "int"
+	FloatField  FieldType = "float"
+	// DecimalField holds exact decimals such as money amounts. Values are
+	// kept as json.Number, never converted through a float.
+	DecimalField  FieldType = "decimal"
+	BoolField     FieldType = "bool"
+	TimeField     FieldType = "time"
+	DurationField FieldType = "duration"
+	// SliceField is an array; the fields of its elements are declared under
+	// "field.", and scalar elements under "field.$".
+	SliceField FieldType = "slice"
+	// AnyField accepts every operator and value, for itself and for any
+	// field nested below it.
+	AnyField FieldType = "any"
+)
+
+var (
+	equalityOperators = []Operator{Equal, NotEqual, In, NotIn, IsNull, NotNull, Exists, NotExists, IsZero, NotZero, Expression}
+	orderedOperators  = []Operator{GreaterThan, GreaterThanEqual, LessThan, LessThanEqual, Between}
+	textOperators     = []Operator{
+		Contains, NotContains, StartsWith, NotStartsWith, EndsWith, NotEndsWith,
+		ContainsCS, NotContainsCS, StartsWithCS, NotStartsWithCS, EndsWithCS, NotEndsWithCS, Pattern,
+	}
+	countOperators = []Operator{EqualCount, NotEqualCount, GreaterThanCount, LesserThanCount, GreaterThanEqualCount, LesserThanEqualCount}
+	dateOperators  = []Operator{Before, After, OnDate, WithinLast, WithinNext, AgeBetween, DayOfWeekIn}
+	setOperators   = []Operator{ContainsAll, ContainsAny, SubsetOf, SupersetOf, Disjoint}
+	lenOperators   = []Operator{LenEqual, LenGreaterThan, LenBetween, HasDuplicates}
+)
+
+// fieldOperators lists the operators each field type accepts.
+var fieldOperators = map[FieldType][]Operator{
+	StringField:   operatorSet(equalityOperators, orderedOperators, textOperators),
+	IntField:      operatorSet(equalityOperators, orderedOperators),
+	FloatField:    operatorSet(equalityOperators, orderedOperators),
+	DecimalField:  operatorSet(equalityOperators, orderedOperators),
+	TimeField:     operatorSet(equalityOperators, orderedOperators, dateOperators),
+	DurationField: operatorSet(equalityOperators, orderedOperators),
+	BoolField:     equalityOperators,
+	SliceField:    operatorSet(equalityOperators, countOperators, setOperators, lenOperators, []Operator{Any, All, None}),
+}
+
+func operatorSet(lists ...[]Operator) []Operator {
+	var operators []Operator
+	for _, list := range lists {
+		operators = append(operators, list...)
+	}
+	return operators
+}
+
+// Schema declares the type of every field a query may filter on. Parsing
+// through a schema converts values to the declared types and rejects unknown
+// fields and operators that make no sense for a field's type.
+type Schema map[string]FieldType
+
+// SchemaErrors collects every field a condition failed the schema on.
+type SchemaErrors []*FilterError
+
+func (e SchemaErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e SchemaErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// ParseQuery parses queryString like the package level ParseQuery and
+// coerces the filters to the schema.
+func (s Schema) ParseQuery(queryString string, exceptFields ...string) ([]*Filter, error) {
+	filters, err := ParseQuery(queryString, exceptFields...)
+	if err != nil {
+		return nil, err
+	}
+	var errs SchemaErrors
+	for _, filter := range filters {
+		errs = s.coerce(filter, errs)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return filters, nil
+}
+
+// ParseSQL parses sql like the package level ParseSQL and coerces the
+// resulting rule to the schema.
+func (s Schema) ParseSQL(sql string) (*Rule, error) {
+	rule, err := ParseSQL(sql)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Coerce(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// Coerce converts the values of every filter in condition to the types the
+// schema declares, in place. It reports every unknown field, disallowed
+// operator and unconvertible value together as SchemaErrors.
+func (s Schema) Coerce(condition Condition) error {
+	if errs := s.coerce(condition, nil); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (s Schema) coerce(condition Condition, errs SchemaErrors) SchemaErrors {
+	switch c := condition.(type) {
+	case *Filter:
+		return s.coerceFilter(c, errs)
+	case *FilterGroup:
+		for _, child := range c.Filters {
+			errs = s.coerce(child, errs)
+		}
+	case *Rule:
+		errs = s.coerce(c.Node, errs)
+		errs = s.coerce(c.Next, errs)
+	case *Join:
+		errs = s.coerce(c.Left, errs)
+		errs = s.coerce(c.Right, errs)
+	case CompiledCondition:
+		errs = s.coerce(c.Source(), errs)
+	}
+	return errs
+}
+
+func (s Schema) coerceFilter(filter *Filter, errs SchemaErrors) SchemaErrors {
+	if _, computed := reference(filter.Field); computed {
+		return errs
+	}
+	fail := func(err error) SchemaErrors {
+		return append(errs, &FilterError{Key: filter.Key, Field: filter.Field, Operator: filter.Operator, Err: err})
+	}
+	fieldType, ok := s.fieldType(filter.Field)
+	if !ok {
+		return fail(ErrUnknownField)
+	}
+	if fieldType == AnyField {
+		return errs
+	}
+	allowed := false
+	for _, operator := range fieldOperators[fieldType] {
+		allowed = allowed || operator == filter.Operator
+	}
+	if !allowed {
+		return fail(fmt.Errorf("%w on %s field", ErrOperatorNotAllowed, fieldType))
+	}
+	if filter.Lookup != nil {
+		return errs
+	}
+	value, err := filter.Value, error(nil)
+	switch filter.Operator {
+	case IsNull, NotNull, Exists, NotExists, IsZero, NotZero, Expression, DayOfWeekIn, HasDuplicates:
+		return errs
+	case Before, After, OnDate:
+		// strings are only checked, so that they are read in the zone of
+		// the evaluation
+		if _, err := coerceValue(fieldType, filter.Value); err != nil {
+			return fail(err)
+		}
+		return errs
+	case WithinLast, WithinNext:
+		value, err = coerceValue(DurationField, filter.Value)
+	case AgeBetween:
+		value, err = coerceValues(IntField, filter.Value)
+	case Any, All, None:
+		if inner, ok := filter.Value.(Condition); ok {
+			if elements := s.elements(filter.Field); len(elements) > 0 {
+				return elements.coerce(inner, errs)
+			}
+		}
+		return errs
+	case EqualCount, NotEqualCount, GreaterThanCount, LesserThanCount, GreaterThanEqualCount, LesserThanEqualCount, LenEqual, LenGreaterThan:
+		value, err = coerceValue(IntField, filter.Value)
+	case LenBetween:
+		value, err = coerceValues(IntField, filter.Value)
+	case In, NotIn, Between, ContainsAll, ContainsAny, SubsetOf, SupersetOf, Disjoint:
+		elementType := fieldType
+		if fieldType == SliceField {
+			if elementType, ok = s[filter.Field+"."+SelfField]; !ok {
+				return errs
+			}
+		}
+		value, err = coerceValues(elementType, filter.Value)
+	default:
+		value, err = coerceValue(fieldType, filter.Value)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	filter.Value = value
+	filter.validated = false
+	return errs
+}
+
+// fieldType looks up field, falling back to the nearest AnyField parent.
+func (s Schema) fieldType(field string) (FieldType, bool) {
+	if fieldType, ok := s[field]; ok {
+		return fieldType, true
+	}
+	for i := strings.LastIndexByte(field, '.'); i > 0; i = strings.LastIndexByte(field[:i], '.') {
+		if s[field[:i]] == AnyField {
+			return AnyField, true
+		}
+	}
+	return "", false
+}
+
+// elements returns the schema of the elements of the array at field.
+func (s Schema) elements(field string) Schema {
+	prefix := field + "."
+	elements := Schema{}
+	for name, fieldType := range s {
+		if rest, ok := strings.CutPrefix(name, prefix); ok {
+			elements[rest] = fieldType
+		}
+	}
+	return elements
+}
+
+func coerceValues(fieldType FieldType, value any) (any, error) {
+	if hasReference(value) {
+		return value, nil
+	}
+	values := sqlValues(value)
+	for i, v := range values {
+		coerced, err := coerceValue(fieldType, v)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = coerced
+	}
+	return values, nil
+}
+
+// coerceValue converts value to fieldType. References, relative times and
+// nil are left for evaluation time.
+func coerceValue(fieldType FieldType, value any) (any, error) {
+	if s, ok := value.(string); ok {
+		if _, isRef := reference(s); isRef || isRelative(s) {
+			return value, nil
+		}
+	}
+	if value == nil {
+		return nil, nil
+	}
+	var (
+		coerced any
+		err     error
+	)
+	switch fieldType {
+	case StringField:
+		coerced, err = coerceString(value)
+	case IntField:
+		coerced, err = coerceInt(value)
+	case FloatField:
+		coerced, err = coerceFloat(value)
+	case DecimalField:
+		coerced, err = coerceDecimal(value)
+	case BoolField:
+		coerced, err = coerceBool(value)
+	case TimeField:
+		coerced, err = coerceTime(value)
+	case DurationField:
+		coerced, err = coerceDuration(value)
+	default:
+		return value, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot convert %v to %s", ErrTypeMismatch, value, fieldType)
+	}
+	return coerced, nil
+}
+
+var errNotConvertible = errors.New("not convertible")
+
+func coerceString(value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case bool, json.Number, time.Duration:
+		return fmt.Sprint(v), nil
+	}
+	if isNumeric(value) {
+		return fmt.Sprint(value), nil
+	}
+	return nil, errNotConvertible
+}
+
+func coerceInt(value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return strconv.Atoi(strings.TrimSpace(v))
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err
+	}
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.CanInt():
+		return int(rv.Int()), nil
+	case rv.CanUint() && rv.Uint() <= math.MaxInt:
+		return int(rv.Uint()), nil
+	case rv.CanFloat() && rv.Float() == math.Trunc(rv.Float()):
+		return int(rv.Float()), nil
+	}
+	return nil, errNotConvertible
+}
+
+func coerceFloat(value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	case json.Number:
+		return v.Float64()
+	}
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.CanInt():
+		return float64(rv.Int()), nil
+	case rv.CanUint():
+		return float64(rv.Uint()), nil
+	case rv.CanFloat():
+		return rv.Float(), nil
+	}
+	return nil, errNotConvertible
+}
+
+func coerceDecimal(value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		v = strings.TrimSpace(v)
+		if _, ok := utils.ToRat(v); ok {
+			return json.Number(v), nil
+		}
+		return nil, errNotConvertible
+	case json.Number:
+		if _, ok := utils.ToRat(v); ok {
+			return v, nil
+		}
+		return nil, errNotConvertible
+	}
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.CanInt(), rv.CanUint():
+		return json.Number(fmt.Sprint(value)), nil
+	case rv.CanFloat():
+		return json.Number(strconv.FormatFloat(rv.Float(), 'g', -1, rv.Type().Bits())), nil
+	}
+	// registered decimal types are exact already
+	if _, ok := utils.ToRat(value); ok {
+		return value, nil
+	}
+	return nil, errNotConvertible
+}
+
+func coerceBool(value any) (any, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(strings.TrimSpace(v))
+	}
+	return nil, errNotConvertible
+}
+
+func coerceTime(value any) (any, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return utils.ParseTime(strings.TrimSpace(v))
+	}
+	return nil, errNotConvertible
+}
+
+func coerceDuration(value any) (any, error) {
+	switch v := value.(type) {
+	case time.Duration:
+		return v, nil
+	case string:
+		return utils.ParseDuration(v)
+	}
+	return nil, errNotConvertible
+}
+
+func isNumeric(value any) bool {
+	rv := reflect.ValueOf(value)
+	return rv.CanInt() || rv.CanUint() || rv.CanFloat()
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// SchemaFromStruct derives a schema from the filter or json tags of a
+// struct, or a pointer to one, naming fields as DefaultResolver does.
+// Nested structs contribute dotted paths, and the elements of slices are
+// declared as described on SliceField.
+func SchemaFromStruct(v any) (Schema, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || t == timeType {
+		return nil, fmt.Errorf("schema requires a struct, got %T", v)
+	}
+	schema := Schema{}
+	schema.addStruct(t, "", map[reflect.Type]bool{})
+	return schema, nil
+}
+
+func (s Schema) addStruct(t reflect.Type, prefix string, seen map[reflect.Type]bool) {
+	// stop at recursive types; their deeper fields are not declared
+	if seen[t] {
+		return
+	}
+	seen[t] = true
+	defer delete(seen, t)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := tagName(field)
+		if name == "-" {
+			continue
+		}
+		if hasTagOption(field, "decimal") {
+			s[prefix+cmp.Or(name, field.Name)] = DecimalField
+			continue
+		}
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if name == "" && field.Anonymous && fieldType.Kind() == reflect.Struct {
+			s.addStruct(fieldType, prefix, seen)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		s.addField(fieldType, prefix+name, seen)
+	}
+}
+
+func (s Schema) addField(t reflect.Type, path string, seen map[reflect.Type]bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		s[path] = TimeField
+		return
+	case t == durationType:
+		s[path] = DurationField
+		return
+	case utils.IsDecimalType(t) || utils.IsDecimalType(reflect.PointerTo(t)):
+		s[path] = DecimalField
+		return
+	}
+	switch t.Kind() {
+	case reflect.String:
+		s[path] = StringField
+	case reflect.Bool:
+		s[path] = BoolField
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s[path] = IntField
+	case reflect.Float32, reflect.Float64:
+		s[path] = FloatField
+	case reflect.Struct:
+		s.addStruct(t, path+".", seen)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			s[path] = StringField
+			return
+		}
+		s[path] = SliceField
+		elem := t.Elem()
+		for elem.Kind() == reflect.Pointer {
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Struct && elem != timeType {
+			s.addStruct(elem, path+".", seen)
+		} else {
+			s.addField(elem, path+"."+SelfField, seen)
+		}
+	default:
+		s[path] = AnyField
+	}
+}
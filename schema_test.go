@@ -0,0 +1,159 @@
+package filters_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oarkflow/filters"
+)
+
+var peopleSchema = filters.Schema{
+	"name":    filters.StringField,
+	"age":     filters.IntField,
+	"city":    filters.StringField,
+	"tags":    filters.SliceField,
+	"tags.$":  filters.StringField,
+	"created": filters.TimeField,
+}
+
+func TestSchemaParseQuery(t *testing.T) {
+	list, err := peopleSchema.ParseQuery("age=gt:26&name=John Doe&created=between:2022-01-01,2022-12-31&tags=gtc:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]any{}
+	for _, filter := range list {
+		values[filter.Field] = filter.Value
+	}
+	want := map[string]any{
+		"age":     26,
+		"name":    "John Doe",
+		"created": []any{time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 12, 31, 0, 0, 0, 0, time.UTC)},
+		"tags":    1,
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("got %#v, want %#v", values, want)
+	}
+	for i, want := range []bool{true, false, false} {
+		matched := true
+		for _, filter := range list {
+			matched = matched && filter.Match(people[i])
+		}
+		if matched != want {
+			t.Errorf("record %d: got %v, want %v", i, matched, want)
+		}
+	}
+}
+
+func TestSchemaParseSQL(t *testing.T) {
+	rule, err := peopleSchema.ParseSQL("SELECT * FROM people WHERE age IN ('25', '35') AND created < '2022-01-01'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{false, false, true} {
+		if got := rule.Match(people[i]); got != want {
+			t.Errorf("record %d: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestSchemaReportsEveryError(t *testing.T) {
+	_, err := peopleSchema.ParseQuery("age=gt:old&email=eq:x&created=contains:2022&name=eq:{{city}}")
+	var errs filters.SchemaErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got %v, want SchemaErrors", err)
+	}
+	kinds := map[string]error{}
+	for _, err := range errs {
+		kinds[err.Field] = err.Err
+	}
+	want := map[string]error{
+		"age":     filters.ErrTypeMismatch,
+		"email":   filters.ErrUnknownField,
+		"created": filters.ErrOperatorNotAllowed,
+	}
+	if len(kinds) != len(want) {
+		t.Fatalf("got %v", err)
+	}
+	for field, kind := range want {
+		if !errors.Is(kinds[field], kind) {
+			t.Errorf("%s: got %v, want %v", field, kinds[field], kind)
+		}
+	}
+	if !errors.Is(err, filters.ErrUnknownField) {
+		t.Errorf("errors.Is should see through SchemaErrors")
+	}
+}
+
+func TestSchemaCoercesAnyCondition(t *testing.T) {
+	schema := filters.Schema{"items": filters.SliceField, "items.qty": filters.IntField, "meta": filters.AnyField}
+	inner := filters.NewFilter("qty", filters.GreaterThan, "2")
+	condition := filters.NewFilterGroup(filters.AND, false,
+		filters.NewFilter("items", filters.Any, inner),
+		filters.NewFilter("meta.source", filters.Equal, "web"),
+	)
+	if err := schema.Coerce(condition); err != nil {
+		t.Fatal(err)
+	}
+	if inner.Value != 2 {
+		t.Errorf("got %#v, want 2", inner.Value)
+	}
+	data := map[string]any{"items": []any{map[string]any{"qty": 3}}, "meta": map[string]any{"source": "web"}}
+	if !condition.Match(data) {
+		t.Errorf("coerced condition should match")
+	}
+	if err := schema.Coerce(filters.NewFilter("items", filters.Any, filters.NewFilter("sku", filters.Equal, "x"))); !errors.Is(err, filters.ErrUnknownField) {
+		t.Errorf("got %v, want unknown field", err)
+	}
+}
+
+func TestSchemaFromStruct(t *testing.T) {
+	type Address struct {
+		City string `json:"city"`
+	}
+	type Base struct {
+		ID uint64 `json:"id"`
+	}
+	type User struct {
+		Base
+		Name     string            `json:"name,omitempty"`
+		Score    *float64          `json:"score"`
+		Active   bool              `json:"active"`
+		Joined   time.Time         `json:"joined"`
+		Timeout  time.Duration     `json:"timeout"`
+		Address  Address           `json:"address"`
+		Tags     []string          `json:"tags"`
+		Friends  []*Address        `json:"friends"`
+		Extra    map[string]string `json:"extra"`
+		Password string            `json:"-"`
+		Nickname string
+		internal int
+	}
+	schema, err := filters.SchemaFromStruct(&User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filters.Schema{
+		"id":           filters.IntField,
+		"name":         filters.StringField,
+		"score":        filters.FloatField,
+		"active":       filters.BoolField,
+		"joined":       filters.TimeField,
+		"timeout":      filters.DurationField,
+		"address.city": filters.StringField,
+		"tags":         filters.SliceField,
+		"tags.$":       filters.StringField,
+		"friends":      filters.SliceField,
+		"friends.city": filters.StringField,
+		"extra":        filters.AnyField,
+		"Nickname":     filters.StringField,
+	}
+	if !reflect.DeepEqual(schema, want) {
+		t.Errorf("got %v, want %v", schema, want)
+	}
+	if _, err := filters.SchemaFromStruct(42); err == nil {
+		t.Errorf("expected an error for a non-struct")
+	}
+}
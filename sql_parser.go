@@ -1,114 +1,514 @@
 package filters
 
 import (
-	"errors"
-	"slices"
+	"regexp"
 	"strings"
+	"time"
+
+	"github.com/oarkflow/filters/utils"
 )
 
-func parseBetween(p *parser, field string) (*Filter, Boolean, int, error) {
-	operator := Between
+// The WHERE clause grammar, from lowest to highest precedence:
+//
+//	or         = and { OR and }
+//	and        = not { AND not }
+//	not        = NOT not | primary
+//	primary    = "(" or ")" | quantifier | predicate
+//	quantifier = (EXISTS | ANY | ALL | NONE) "(" [SELECT "*" FROM] field WHERE or ")"
+//	predicate  = field operator [value | value AND value | "(" value { "," value } ")"]
+//	value      = literal | NOW() | TODAY() [("+" | "-") (INTERVAL 'text' | 'text')]
+//
+// The date operators BEFORE, AFTER, ON_DATE, WITHIN_LAST, WITHIN_NEXT,
+// AGE_BETWEEN (with AND) and DAY_OF_WEEK_IN (with a list) are written
+// between the field and the value like the comparison operators.
+//
+// A malformed predicate is recorded and skipped up to the next AND, OR or
+// ")", so every error in a clause is reported in one pass.
 
-	tok, ok := p.nextToken()
-	if !ok || !slices.Contains([]tokenType{tokenIdentifier, tokenValue, tokenVariable}, tok.typ) {
-		return nil, "", 0, errors.New("expected value")
+func (p *parser) parseWhere() Condition {
+	condition := p.parseOr()
+	for tok, ok := p.peekToken(); ok; tok, ok = p.peekToken() {
+		p.fail(p.errorAt(tok, true, "AND", "OR"))
+		p.pos++
+		p.skip()
+		if p.acceptKeyword("AND") || p.acceptKeyword("OR") {
+			p.parseOr()
+		}
 	}
-	field1 := tok.value
+	return condition
+}
+
+func (p *parser) parseOr() Condition {
+	return p.parseSequence(OR, p.parseAnd)
+}
+
+func (p *parser) parseAnd() Condition {
+	return p.parseSequence(AND, p.parseNot)
+}
 
-	tok, ok = p.nextToken()
-	if !ok || tok.value != "AND" {
-		return nil, "", 0, errors.New("expected AND operator")
+// parseSequence parses operands joined by operator, grouping them when there
+// is more than one.
+func (p *parser) parseSequence(operator Boolean, operand func() Condition) Condition {
+	var conditions []Condition
+	for {
+		if condition := operand(); condition != nil {
+			conditions = append(conditions, condition)
+		}
+		if !p.acceptKeyword(string(operator)) {
+			break
+		}
+	}
+	switch len(conditions) {
+	case 0:
+		return nil
+	case 1:
+		return conditions[0]
 	}
+	return NewFilterGroup(operator, false, conditions...)
+}
 
-	tok, ok = p.nextToken()
-	if !ok || !slices.Contains([]tokenType{tokenIdentifier, tokenValue, tokenVariable}, tok.typ) {
-		return nil, "", 0, errors.New("expected value")
+func (p *parser) parseNot() Condition {
+	if !p.acceptKeyword("NOT") {
+		return p.parsePrimary()
 	}
-	field2 := tok.value
+	return negate(p.parseNot())
+}
 
-	return NewFilter(field, operator, []any{field1, field2}), "", p.pos, nil
+func (p *parser) parsePrimary() Condition {
+	tok, ok := p.peekToken()
+	if !ok {
+		p.fail(p.errorAt(tok, false, "field name", "'('"))
+		return nil
+	}
+	if tok.typ != tokenLParen {
+		parse := p.parsePredicate
+		if p.atQuantifier() {
+			parse = p.parseQuantifier
+		}
+		condition, err := parse()
+		if err != nil {
+			p.fail(err)
+			p.skip()
+			return nil
+		}
+		return condition
+	}
+	p.nextToken()
+	condition := p.parseOr()
+	if tok, ok := p.peekToken(); !ok || tok.typ != tokenRParen {
+		p.fail(p.errorAt(tok, ok, "')'"))
+		p.skip()
+	}
+	if tok, ok := p.peekToken(); ok && tok.typ == tokenRParen {
+		p.pos++
+	}
+	return condition
+}
+
+// sqlQuantifiers map the quantifier words to their operators. EXISTS is the
+// same as ANY.
+var sqlQuantifiers = map[string]Operator{"EXISTS": Any, "ANY": Any, "ALL": All, "NONE": None}
+
+// atQuantifier reports whether the next tokens start a quantifier, a
+// quantifier word followed by "(".
+func (p *parser) atQuantifier() bool {
+	tok := p.tokens[p.pos]
+	_, ok := sqlQuantifiers[strings.ToUpper(tok.value)]
+	return ok && tok.typ == tokenIdentifier && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].typ == tokenLParen
 }
 
-func parseLike(p *parser, field string) (*Filter, Boolean, int, error) {
-	tok, ok := p.nextToken()
-	if !ok || !slices.Contains([]tokenType{tokenIdentifier, tokenValue, tokenVariable}, tok.typ) {
-		return nil, "", 0, errors.New("expected value")
+// parseQuantifier parses a condition on the elements of an array field, such
+// as EXISTS (SELECT * FROM lines WHERE qty > 1). Fields in the WHERE clause
+// are relative to the element.
+func (p *parser) parseQuantifier() (Condition, *SyntaxError) {
+	operator := sqlQuantifiers[strings.ToUpper(p.tokens[p.pos].value)]
+	p.pos += 2
+	if p.acceptKeyword("SELECT") {
+		if tok, ok := p.take(); !ok || tok.typ == tokenKeyword {
+			return nil, p.errorAt(tok, ok, "'*'")
+		}
+		if !p.acceptKeyword("FROM") {
+			next, ok := p.peekToken()
+			return nil, p.errorAt(next, ok, "FROM")
+		}
 	}
-	val := strings.Trim(tok.value, "%")
-	switch {
-	case strings.HasPrefix(tok.value, "%") && strings.HasSuffix(tok.value, "%"):
-		return NewFilter(field, Contains, val), "", p.pos, nil
-	case strings.HasPrefix(tok.value, "%"):
-		return NewFilter(field, EndsWith, val), "", p.pos, nil
-	case strings.HasSuffix(tok.value, "%"):
-		return NewFilter(field, StartsWith, val), "", p.pos, nil
+	tok, ok := p.take()
+	if !ok || tok.typ != tokenIdentifier {
+		return nil, p.errorAt(tok, ok, "field name")
+	}
+	if !p.acceptKeyword("WHERE") {
+		next, ok := p.peekToken()
+		return nil, p.errorAt(next, ok, "WHERE")
 	}
-	return nil, "", 0, errors.New("unexpected LIKE pattern")
+	condition := p.parseOr()
+	if next, ok := p.peekToken(); !ok || next.typ != tokenRParen {
+		return nil, p.errorAt(next, ok, "')'")
+	}
+	p.pos++
+	if condition == nil {
+		return nil, nil
+	}
+	return NewFilter(tok.value, operator, condition), nil
 }
 
-func parseIn(p *parser, field string) (*Filter, Boolean, int, error) {
-	var in []any
-	tok, ok := p.nextToken()
-	if !ok || tok.typ != tokenLParen {
-		return nil, "", 0, errors.New("expected '('")
+func (p *parser) parsePredicate() (Condition, *SyntaxError) {
+	tok, ok := p.take()
+	if !ok || tok.typ != tokenIdentifier {
+		return nil, p.errorAt(tok, ok, "field name")
 	}
-	for {
-		tok, _ = p.nextToken()
-		if tok.typ == tokenRParen {
-			break
+	field := tok.value
+
+	tok, ok = p.take()
+	if ok && tok.typ == tokenIdentifier {
+		if operator, isDate := sqlDateOperators[strings.ToUpper(tok.value)]; isDate {
+			return p.parseDatePredicate(field, operator)
+		}
+	}
+	if !ok || (tok.typ != tokenOperator && tok.typ != tokenKeyword) {
+		return nil, p.errorAt(tok, ok, "operator")
+	}
+	sqlOperator := strings.ToUpper(tok.value)
+	if sqlOperator == "NOT" {
+		next, ok := p.take()
+		if !ok || next.typ != tokenKeyword {
+			return nil, p.errorAt(next, ok, "IN", "LIKE", "BETWEEN")
+		}
+		sqlOperator = "NOT " + next.value
+	}
+
+	switch sqlOperator {
+	case "IS NULL", "IS NOT NULL":
+		return NewFilter(field, toOperator(sqlOperator), nil), nil
+	case "LIKE", "NOT LIKE":
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		pattern, ok := value.(string)
+		if !ok {
+			return nil, p.errorAt(p.tokens[p.pos-1], true, "LIKE pattern")
 		}
-		if slices.Contains([]tokenType{tokenIdentifier, tokenValue, tokenVariable}, tok.typ) {
-			in = append(in, tok.value)
+		return likeFilter(field, pattern, sqlOperator == "NOT LIKE"), nil
+	case "BETWEEN", "NOT BETWEEN":
+		from, err := p.parseValue()
+		if err != nil {
+			return nil, err
 		}
+		if !p.acceptKeyword("AND") {
+			next, ok := p.peekToken()
+			return nil, p.errorAt(next, ok, "AND")
+		}
+		to, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		filter := NewFilter(field, Between, []any{from, to})
+		filter.Reverse = sqlOperator == "NOT BETWEEN"
+		return filter, nil
+	case "IN", "NOT IN":
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return NewFilter(field, toOperator(sqlOperator), values), nil
+	}
+
+	operator := toOperator(sqlOperator)
+	if operator == "" || tok.typ != tokenOperator {
+		return nil, p.errorAt(tok, true, "operator")
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		switch operator {
+		case Equal:
+			return NewFilter(field, IsNull, nil), nil
+		case NotEqual:
+			return NewFilter(field, NotNull, nil), nil
+		}
+		return nil, p.errorAt(p.tokens[p.pos-1], true, "value")
 	}
-	return NewFilter(field, In, in), "", p.pos, nil
+	return NewFilter(field, operator, value), nil
 }
 
-func parseNot(p *parser, field string) (*Filter, Boolean, int, error) {
-	tok, ok := p.nextToken()
-	if !ok {
-		return nil, "", 0, errors.New("unexpected error")
+var sqlDateOperators = map[string]Operator{
+	"BEFORE": Before, "AFTER": After, "ON_DATE": OnDate, "WITHIN_LAST": WithinLast,
+	"WITHIN_NEXT": WithinNext, "AGE_BETWEEN": AgeBetween, "DAY_OF_WEEK_IN": DayOfWeekIn,
+}
+
+func (p *parser) parseDatePredicate(field string, operator Operator) (Condition, *SyntaxError) {
+	switch operator {
+	case AgeBetween:
+		from, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if !p.acceptKeyword("AND") {
+			next, ok := p.peekToken()
+			return nil, p.errorAt(next, ok, "AND")
+		}
+		to, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return NewFilter(field, AgeBetween, []any{from, to}), nil
+	case DayOfWeekIn:
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return NewFilter(field, DayOfWeekIn, values), nil
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
 	}
-	switch tok.value {
-	case "IN":
-		return parseNotIn(p, field)
-	case "LIKE":
-		return parseNotLike(p, field)
+	if value == nil {
+		return nil, p.errorAt(p.tokens[p.pos-1], true, "value")
 	}
-	return nil, "", 0, errors.New("unexpected NOT token")
+	return NewFilter(field, operator, value), nil
 }
 
-func parseNotIn(p *parser, field string) (*Filter, Boolean, int, error) {
-	var in []any
-	tok, ok := p.nextToken()
-	if !ok || tok.typ != tokenLParen {
-		return nil, "", 0, errors.New("expected '('")
+// parseRelative reads the parentheses of NOW() or TODAY(), named by name,
+// and an optional offset into a relative time such as "today() - 7 days".
+// The offset is an INTERVAL, a quoted duration or, as ParseQuery writes it,
+// a bare one such as 7d or 1h30m.
+func (p *parser) parseRelative(name string) (any, *SyntaxError) {
+	p.pos++
+	if tok, ok := p.peekToken(); !ok || tok.typ != tokenRParen {
+		return nil, p.errorAt(tok, ok, "')'")
+	}
+	p.pos++
+	relative := strings.ToLower(name) + "()"
+	sign, ok := p.peekToken()
+	switch {
+	case !ok:
+		return relative, nil
+	case sign.typ == tokenIdentifier && (sign.value == "+" || sign.value == "-"):
+		p.pos++
+	case sign.typ == tokenValue && strings.ContainsRune("+-", rune(p.source[sign.pos])):
+		// now()-7d lexes the sign as part of the number
+		sign = token{value: p.source[sign.pos : sign.pos+1], pos: sign.pos, end: sign.pos + 1}
+	default:
+		return relative, nil
+	}
+	offset, ok := p.take()
+	if !ok || offset.typ != tokenValue {
+		return nil, p.errorAt(offset, ok, "INTERVAL")
+	}
+	if _, isDuration := offset.literal.(time.Duration); isDuration {
+		return relative + " " + sign.value + " " + offset.value, nil
+	}
+	// a bare duration is lexed as a number followed by its units
+	text := offset.value
+	if offset.pos < sign.end || p.adjacent(offset) {
+		offset.pos = max(offset.pos, sign.end)
+		for p.adjacent(offset) {
+			offset.end = p.tokens[p.pos].end
+			p.pos++
+		}
+		text = p.source[offset.pos:offset.end]
 	}
+	if _, err := utils.ParseDuration(text); err != nil {
+		return nil, p.errorAt(offset, true, "INTERVAL")
+	}
+	return relative + " " + sign.value + " " + text, nil
+}
+
+// adjacent reports whether the next token is a word or number that directly
+// follows tok, with no space between them.
+func (p *parser) adjacent(tok token) bool {
+	next, ok := p.peekToken()
+	return ok && next.pos == tok.end && (next.typ == tokenIdentifier || next.typ == tokenValue)
+}
+
+// parseValue reads a literal, a bare word, NOW() or TODAY(), a {{variable}}
+// or NULL, which is returned as nil.
+func (p *parser) parseValue() (any, *SyntaxError) {
+	tok, ok := p.take()
+	switch {
+	case !ok:
+	case tok.typ == tokenValue:
+		return tok.literal, nil
+	case tok.typ == tokenIdentifier && (strings.EqualFold(tok.value, "now") || strings.EqualFold(tok.value, "today")):
+		if next, ok := p.peekToken(); ok && next.typ == tokenLParen {
+			return p.parseRelative(tok.value)
+		}
+		return tok.value, nil
+	case tok.typ == tokenIdentifier, tok.typ == tokenVariable:
+		return tok.value, nil
+	case tok.typ == tokenKeyword && tok.value == "NULL":
+		return nil, nil
+	}
+	return nil, p.errorAt(tok, ok, "value")
+}
+
+func (p *parser) parseList() ([]any, *SyntaxError) {
+	if tok, ok := p.take(); !ok || tok.typ != tokenLParen {
+		return nil, p.errorAt(tok, ok, "'('")
+	}
+	var values []any
 	for {
-		tok, _ = p.nextToken()
+		value, err := p.parseValue()
+		if err != nil {
+			p.skipList()
+			return nil, err
+		}
+		values = append(values, value)
+		tok, ok := p.take()
+		if !ok || (tok.typ != tokenRParen && tok.typ != tokenComma) {
+			p.skipList()
+			return nil, p.errorAt(tok, ok, "','", "')'")
+		}
 		if tok.typ == tokenRParen {
-			break
+			p.pos++
+			return values, nil
 		}
-		if slices.Contains([]tokenType{tokenIdentifier, tokenValue, tokenVariable}, tok.typ) {
-			in = append(in, tok.value)
+	}
+}
+
+// skipList advances past the ")" closing a malformed list, stopping early at
+// AND or OR when the list is not closed.
+func (p *parser) skipList() {
+	for tok, ok := p.peekToken(); ok; tok, ok = p.peekToken() {
+		if isBoundary(tok) {
+			if tok.typ == tokenRParen {
+				p.pos++
+			}
+			return
 		}
+		p.pos++
 	}
-	return NewFilter(field, NotIn, in), "", p.pos, nil
 }
 
-func parseNotLike(p *parser, field string) (*Filter, Boolean, int, error) {
-	tok, ok := p.nextToken()
-	if !ok || tok.typ != tokenIdentifier {
-		return nil, "", 0, errors.New("expected value")
+// acceptKeyword consumes the next token if it is the given keyword.
+func (p *parser) acceptKeyword(keyword string) bool {
+	tok, ok := p.peekToken()
+	if ok && tok.typ == tokenKeyword && tok.value == keyword {
+		p.pos++
+		return true
 	}
-	val := strings.Trim(tok.value, "%")
+	return false
+}
+
+// errorAt reports an unexpected token, or the end of the input when ok is false.
+func (p *parser) errorAt(tok token, ok bool, expected ...string) *SyntaxError {
+	if !ok {
+		end := 0
+		if len(p.tokens) > 0 {
+			end = p.tokens[len(p.tokens)-1].end
+		}
+		return newSyntaxError(p.source, end, end, "", expected...)
+	}
+	return newSyntaxError(p.source, tok.pos, tok.end, "", expected...)
+}
+
+// take consumes the next token unless it is AND, OR or ")", which are left
+// for the enclosing sequence when a term turns out to be malformed.
+func (p *parser) take() (token, bool) {
+	tok, ok := p.peekToken()
+	if ok && !isBoundary(tok) {
+		p.pos++
+	}
+	return tok, ok
+}
+
+// fail records err unless an error was already reported at the same place.
+func (p *parser) fail(err *SyntaxError) {
+	if n := len(p.errs); n > 0 && p.errs[n-1].Line == err.Line && p.errs[n-1].Column == err.Column {
+		return
+	}
+	p.errs = append(p.errs, err)
+}
+
+// skip advances to the next AND, OR or ")".
+func (p *parser) skip() {
+	for tok, ok := p.peekToken(); ok && !isBoundary(tok); tok, ok = p.peekToken() {
+		p.pos++
+	}
+}
+
+func isBoundary(tok token) bool {
+	return tok.typ == tokenRParen || (tok.typ == tokenKeyword && (tok.value == "AND" || tok.value == "OR"))
+}
+
+// negate applies a standalone NOT to a parsed condition.
+func negate(condition Condition) Condition {
+	switch c := condition.(type) {
+	case *Filter:
+		c.Reverse = !c.Reverse
+	case *FilterGroup:
+		c.Reverse = !c.Reverse
+	}
+	return condition
+}
+
+// likeFilter maps a LIKE pattern onto the closest operator.
+func likeFilter(field, pattern string, not bool) *Filter {
+	return wildcardFilter(field, pattern, "%", "_", not)
+}
+
+// wildcardFilter maps a pattern using the wildcards many, for any run of
+// characters, and one, for a single character, onto the closest operator.
+// Patterns with many only at the ends use the string operators; anything
+// else becomes an anchored, case-insensitive regular expression. An empty one
+// means the pattern has no single-character wildcard.
+func wildcardFilter(field, pattern, many, one string, not bool) *Filter {
+	val := strings.Trim(pattern, many)
+	prefix, suffix := strings.HasPrefix(pattern, many), strings.HasSuffix(pattern, many)
+	var filter *Filter
 	switch {
-	case strings.HasPrefix(tok.value, "%") && strings.HasSuffix(tok.value, "%"):
-		return NewFilter(field, NotContains, val), "", p.pos, nil
-	case strings.HasPrefix(tok.value, "%"):
-		return NewFilter(field, NotEndsWith, val), "", p.pos, nil
-	case strings.HasSuffix(tok.value, "%"):
-		return NewFilter(field, NotStartsWith, val), "", p.pos, nil
-	}
-	return nil, "", 0, errors.New("unexpected LIKE pattern")
+	case strings.Contains(val, many), one != "" && strings.Contains(pattern, one):
+		filter = NewFilter(field, Pattern, "(?i)^"+wildcardPattern(pattern, many, one)+"$")
+		filter.Reverse = not
+		return filter
+	case prefix && suffix:
+		filter = NewFilter(field, Contains, val)
+	case prefix:
+		filter = NewFilter(field, EndsWith, val)
+	case suffix:
+		filter = NewFilter(field, StartsWith, val)
+	default:
+		filter = NewFilter(field, Equal, val)
+	}
+	if not {
+		filter.Operator = negatedOperators[filter.Operator]
+	}
+	return filter
+}
+
+// wildcardPattern translates the wildcards in pattern to a regular expression
+// and quotes everything else.
+func wildcardPattern(pattern, many, one string) string {
+	var b strings.Builder
+	for pattern != "" {
+		switch {
+		case strings.HasPrefix(pattern, many):
+			b.WriteString(".*")
+			pattern = pattern[len(many):]
+		case one != "" && strings.HasPrefix(pattern, one):
+			b.WriteString(".")
+			pattern = pattern[len(one):]
+		default:
+			end := len(pattern)
+			if i := strings.Index(pattern, many); i >= 0 {
+				end = i
+			}
+			if i := strings.Index(pattern, one); one != "" && i >= 0 && i < end {
+				end = i
+			}
+			b.WriteString(regexp.QuoteMeta(pattern[:end]))
+			pattern = pattern[end:]
+		}
+	}
+	return b.String()
+}
+
+var negatedOperators = map[Operator]Operator{
+	Contains:   NotContains,
+	EndsWith:   NotEndsWith,
+	StartsWith: NotStartsWith,
+	Equal:      NotEqual,
 }
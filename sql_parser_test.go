@@ -0,0 +1,136 @@
+package filters_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oarkflow/filters"
+)
+
+func TestParseSQLPrecedence(t *testing.T) {
+	tests := []struct {
+		where string
+		want  []bool
+	}{
+		{"age > 20 AND age < 40 AND city = 'Los Angeles'", []bool{false, true, false}},
+		{"age = 25 OR age = 35 AND city IS NULL", []bool{false, true, true}},
+		{"(age = 25 OR age = 35) AND city IS NULL", []bool{false, false, true}},
+		{"NOT (age = 25 OR age = 35)", []bool{true, false, false}},
+		{"NOT age = 30 AND NOT city IS NULL", []bool{false, true, false}},
+		{"age NOT BETWEEN 26 AND 34", []bool{false, true, true}},
+		{"age NOT IN (25, 35)", []bool{true, false, false}},
+		{"name LIKE 'J%n'", []bool{false, false, false}},
+		{"name LIKE 'J%h'", []bool{false, true, false}},
+		{"name NOT LIKE '%Smith'", []bool{true, false, true}},
+		{"name LIKE 'j%H'", []bool{false, true, false}},
+		{"name LIKE 'J_ne%'", []bool{false, true, false}},
+		{"name LIKE 'b_b johnson'", []bool{false, false, true}},
+		{"name NOT LIKE 'J.%'", []bool{true, true, true}},
+		{"SELECT * FROM people WHERE ((age >= 30) AND (name != 'Bob Johnson' OR age < 0))", []bool{true, false, false}},
+	}
+	for _, test := range tests {
+		rule, err := filters.ParseSQL(test.where)
+		if err != nil {
+			t.Fatalf("%s: %v", test.where, err)
+		}
+		for i, person := range people {
+			if got := rule.Match(person); got != test.want[i] {
+				t.Errorf("%s: record %d: got %v, want %v", test.where, i, got, test.want[i])
+			}
+		}
+	}
+}
+
+func TestParseSQLRejectsMalformedInput(t *testing.T) {
+	for _, where := range []string{"", "age >", "(age > 1", "age > 1)", "age > 1 AND", "age IN (1, 2", "age BETWEEN 1 OR 2"} {
+		if _, err := filters.ParseSQL(where); err == nil {
+			t.Errorf("%q: expected error", where)
+		}
+	}
+}
+
+func TestParseSQLSyntaxErrors(t *testing.T) {
+	_, err := filters.ParseSQL("SELECT * FROM people\nWHERE age > AND name = 'x'\n  OR city IN ('a' 'b') OR (age = 1")
+	var syntaxErrs filters.SyntaxErrors
+	if !errors.As(err, &syntaxErrs) {
+		t.Fatalf("got %T (%v), want SyntaxErrors", err, err)
+	}
+	want := []struct {
+		line, column int
+		token        string
+	}{
+		{2, 13, "AND"},
+		{3, 19, "'b'"},
+		{3, 35, ""},
+	}
+	if len(syntaxErrs) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(syntaxErrs), len(want), err)
+	}
+	for i, w := range want {
+		got := syntaxErrs[i]
+		if got.Line != w.line || got.Column != w.column || got.Token != w.token {
+			t.Errorf("error %d: got %d:%d %q, want %d:%d %q", i, got.Line, got.Column, got.Token, w.line, w.column, w.token)
+		}
+	}
+	if snippet := syntaxErrs[0].Snippet; snippet != "WHERE age > AND name = 'x'\n            ^^^" {
+		t.Errorf("unexpected snippet:\n%s", snippet)
+	}
+	var syntaxErr *filters.SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr != syntaxErrs[0] {
+		t.Error("errors.As did not find the first *SyntaxError")
+	}
+}
+
+func TestParseSQLTypedLiterals(t *testing.T) {
+	tests := []struct {
+		where string
+		want  any
+	}{
+		{"n = 42", int64(42)},
+		{"n = -7", int64(-7)},
+		{"year = 2024", int64(2024)},
+		{"amount = 20240101", int64(20240101)},
+		{"id = 9223372036854775807", int64(9223372036854775807)},
+		{"day = 2024-01-15", "2024-01-15"},
+		{"n = 2.50", json.Number("2.50")},
+		{"n = 1e3", json.Number("1e3")},
+		{"n = 99999999999999999999", json.Number("99999999999999999999")},
+		{"n = DECIMAL '0.10'", json.Number("0.10")},
+		{"n = true", true},
+		{"n = 'O''Brien'", "O'Brien"},
+		{"n = DATE '2024-02-29'", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{`"first name" = 'x'`, "x"},
+	}
+	for _, test := range tests {
+		rule, err := filters.ParseSQL(test.where)
+		if err != nil {
+			t.Fatalf("%s: %v", test.where, err)
+		}
+		filter, ok := rule.Node.(*filters.Filter)
+		if !ok {
+			t.Fatalf("%s: got %T, want *filters.Filter", test.where, rule.Node)
+		}
+		if !reflect.DeepEqual(filter.Value, test.want) {
+			t.Errorf("%s: got %#v, want %#v", test.where, filter.Value, test.want)
+		}
+	}
+
+	rule, err := filters.ParseSQL("`first name` = 'x' AND city = NULL AND age != null")
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := rule.Node.(*filters.FilterGroup)
+	want := []struct {
+		field    string
+		operator filters.Operator
+	}{{"first name", filters.Equal}, {"city", filters.IsNull}, {"age", filters.NotNull}}
+	for i, w := range want {
+		filter := group.Filters[i].(*filters.Filter)
+		if filter.Field != w.field || filter.Operator != w.operator {
+			t.Errorf("filter %d: got %s %s, want %s %s", i, filter.Field, filter.Operator, w.field, w.operator)
+		}
+	}
+}
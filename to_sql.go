@@ -0,0 +1,378 @@
+package filters
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dialect selects the SQL flavour produced by ToSQL.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	MySQL    Dialect = "mysql"
+	SQLite   Dialect = "sqlite"
+)
+
+// ToSQL renders condition as a parameterised SQL WHERE clause for dialect and
+// returns it with its bind arguments. Nested fields are rendered as qualified
+// names and {{field}} values as column references.
+//
+// Equality and IN compare values exactly, since the column types are not
+// known; Schema.ToSQL folds case on string columns as Match does. Values
+// such as now() - 7d are bound as the times opts resolve them to.
+//
+// Nodes that cannot be pushed down, such as expressions, lookups, count
+// operators and computed references, are reported together in a
+// *TranslateError.
+func ToSQL(condition Condition, dialect Dialect, opts ...TranslateOptions) (string, []any, error) {
+	return toSQL(condition, dialect, nil, opts)
+}
+
+// ToSQL renders condition like the package level ToSQL, but compares the
+// fields the schema declares as StringField case-insensitively with LOWER(),
+// matching the in-memory semantics of Equal, NotEqual, In and NotIn.
+func (s Schema) ToSQL(condition Condition, dialect Dialect, opts ...TranslateOptions) (string, []any, error) {
+	return toSQL(condition, dialect, s, opts)
+}
+
+func toSQL(condition Condition, dialect Dialect, schema Schema, opts []TranslateOptions) (string, []any, error) {
+	switch dialect {
+	case Postgres, MySQL, SQLite:
+	default:
+		return "", nil, fmt.Errorf("unsupported SQL dialect %q", dialect)
+	}
+	b := &sqlBuilder{dialect: dialect, schema: schema, clock: translateClock(opts)}
+	clause := b.condition(condition)
+	if len(b.unsupported) > 0 {
+		return "", nil, &TranslateError{Target: string(dialect), Nodes: b.unsupported}
+	}
+	return clause, b.args, nil
+}
+
+type sqlBuilder struct {
+	// clock resolves relative times; it is nil without TranslateOptions.
+	clock   *clock
+	dialect Dialect
+	// schema marks the text columns whose comparisons fold case.
+	schema      Schema
+	args        []any
+	unsupported []Untranslatable
+}
+
+// foldCase reports whether comparisons on field ignore case: only fields the
+// schema declares as strings are known to hold text.
+func (b *sqlBuilder) foldCase(field string) bool {
+	fieldType, ok := b.schema.fieldType(field)
+	return ok && fieldType == StringField
+}
+
+func (b *sqlBuilder) fail(condition Condition, reason string) string {
+	b.unsupported = append(b.unsupported, Untranslatable{Condition: condition, Reason: reason})
+	return ""
+}
+
+func (b *sqlBuilder) condition(condition Condition) string {
+	switch c := condition.(type) {
+	case CompiledCondition:
+		return b.condition(c.Source())
+	case *Filter:
+		return reverseSQL(b.filter(c), c.Reverse)
+	case *FilterGroup:
+		if c.Operator != AND && c.Operator != OR {
+			return b.fail(c, fmt.Sprintf("unsupported boolean operator: %s", c.Operator))
+		}
+		return reverseSQL(b.sequence(c.Operator, c.Filters...), c.Reverse)
+	case *Rule:
+		if c.Node == nil {
+			return b.fail(c, "rule has no condition")
+		}
+		if c.Next == nil {
+			return reverseSQL(b.condition(c.Node), c.Reverse)
+		}
+		if c.Operator != AND && c.Operator != OR {
+			return b.fail(c, fmt.Sprintf("unsupported boolean operator: %s", c.Operator))
+		}
+		return reverseSQL(b.sequence(c.Operator, c.Node, c.Next), c.Reverse)
+	case *Join:
+		if c.Left == nil || c.Right == nil {
+			return b.fail(c, "missing left or right filter group")
+		}
+		if c.Operator != AND && c.Operator != OR {
+			return b.fail(c, fmt.Sprintf("unsupported boolean operator: %s", c.Operator))
+		}
+		return reverseSQL(b.sequence(c.Operator, c.Left, c.Right), c.Reverse)
+	case nil:
+		return b.fail(c, "condition is nil")
+	default:
+		return b.fail(c, "custom conditions cannot be translated")
+	}
+}
+
+func (b *sqlBuilder) sequence(operator Boolean, conditions ...Condition) string {
+	if len(conditions) == 0 {
+		if operator == AND {
+			return "1 = 1"
+		}
+		return "1 = 0"
+	}
+	parts := make([]string, len(conditions))
+	for i, condition := range conditions {
+		parts[i] = b.condition(condition)
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return "(" + strings.Join(parts, " "+string(operator)+" ") + ")"
+}
+
+func reverseSQL(clause string, reverse bool) string {
+	if !reverse || clause == "" {
+		return clause
+	}
+	return "NOT (" + clause + ")"
+}
+
+func (b *sqlBuilder) filter(filter *Filter) string {
+	if err := filter.Validate(); err != nil {
+		return b.fail(filter, err.Error())
+	}
+	filter, ok := b.clock.translate(filter)
+	if !ok {
+		return b.fail(filter, untranslatableRelative)
+	}
+	if filter.Lookup != nil {
+		return b.fail(filter, "lookups cannot be pushed down")
+	}
+	column, ok := b.reference(filter.Field)
+	if !ok {
+		return b.fail(filter, "computed fields cannot be pushed down")
+	}
+	switch filter.Operator {
+	case Equal, NotEqual, GreaterThan, LessThan, GreaterThanEqual, LessThanEqual:
+		symbol := map[Operator]string{Equal: "=", NotEqual: "<>", GreaterThan: ">", LessThan: "<", GreaterThanEqual: ">=", LessThanEqual: "<="}[filter.Operator]
+		if s, ok := filter.Value.(string); ok && (filter.Operator == Equal || filter.Operator == NotEqual) && b.foldCase(filter.Field) {
+			if _, isRef := reference(s); !isRef {
+				// string equality is case-insensitive in memory
+				return fmt.Sprintf("LOWER(%s) %s %s", column, symbol, b.bind(strings.ToLower(s)))
+			}
+		}
+		value, ok := b.operand(filter.Value)
+		if !ok {
+			return b.fail(filter, "expression values cannot be pushed down")
+		}
+		return fmt.Sprintf("%s %s %s", column, symbol, value)
+	case Between:
+		values := sqlValues(filter.Value)
+		from, ok1 := b.operand(values[0])
+		to, ok2 := b.operand(values[1])
+		if !ok1 || !ok2 {
+			return b.fail(filter, "expression values cannot be pushed down")
+		}
+		return fmt.Sprintf("%s BETWEEN %s AND %s", column, from, to)
+	case In, NotIn:
+		return b.in(filter, column, b.foldCase(filter.Field))
+	case IsNull:
+		return column + " IS NULL"
+	case NotNull:
+		return column + " IS NOT NULL"
+	case IsZero:
+		return fmt.Sprintf("(%s IS NOT NULL AND %s IN ('', '0', 'false'))", column, b.text(column))
+	case NotZero:
+		return fmt.Sprintf("(%s IS NULL OR %s NOT IN ('', '0', 'false'))", column, b.text(column))
+	case Contains, NotContains, StartsWith, NotStartsWith, EndsWith, NotEndsWith,
+		ContainsCS, NotContainsCS, StartsWithCS, NotStartsWithCS, EndsWithCS, NotEndsWithCS:
+		return b.like(filter, column)
+	case Pattern:
+		pattern, _ := filter.Value.(string)
+		switch b.dialect {
+		case Postgres:
+			return fmt.Sprintf("%s ~ %s", column, b.bind(pattern))
+		case MySQL:
+			return fmt.Sprintf("REGEXP_LIKE(%s, %s, 'c')", column, b.bind(pattern))
+		}
+		return b.fail(filter, "regular expressions are not available in "+string(b.dialect))
+	case Expression:
+		return b.fail(filter, "expressions cannot be pushed down")
+	}
+	if _, ok := countOperatorSymbols[filter.Operator]; ok {
+		return b.fail(filter, "count operators cannot be pushed down")
+	}
+	return b.fail(filter, "operator has no SQL equivalent")
+}
+
+// in renders an IN list, lowering the column and the values when fold is set
+// and every value is a string.
+func (b *sqlBuilder) in(filter *Filter, column string, fold bool) string {
+	values := sqlValues(filter.Value)
+	if len(values) == 0 {
+		if filter.Operator == In {
+			return "1 = 0"
+		}
+		return "1 = 1"
+	}
+	lower := fold
+	for _, v := range values {
+		s, ok := v.(string)
+		if _, isRef := reference(s); !ok || isRef {
+			lower = false
+			break
+		}
+	}
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		if lower {
+			v = strings.ToLower(v.(string))
+		}
+		operand, ok := b.operand(v)
+		if !ok {
+			return b.fail(filter, "expression values cannot be pushed down")
+		}
+		placeholders[i] = operand
+	}
+	if lower {
+		column = "LOWER(" + column + ")"
+	}
+	keyword := "IN"
+	if filter.Operator == NotIn {
+		keyword = "NOT IN"
+	}
+	return fmt.Sprintf("%s %s (%s)", column, keyword, strings.Join(placeholders, ", "))
+}
+
+// likeShapes gives, for every string operator, whether it is negated, whether
+// it is case-sensitive and where the wildcards go.
+var likeShapes = map[Operator]struct {
+	negated, caseSensitive bool
+	prefix, suffix         bool
+}{
+	Contains:        {false, false, true, true},
+	NotContains:     {true, false, true, true},
+	StartsWith:      {false, false, false, true},
+	NotStartsWith:   {true, false, false, true},
+	EndsWith:        {false, false, true, false},
+	NotEndsWith:     {true, false, true, false},
+	ContainsCS:      {false, true, true, true},
+	NotContainsCS:   {true, true, true, true},
+	StartsWithCS:    {false, true, false, true},
+	NotStartsWithCS: {true, true, false, true},
+	EndsWithCS:      {false, true, true, false},
+	NotEndsWithCS:   {true, true, true, false},
+}
+
+var (
+	likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+	globEscaper = strings.NewReplacer("[", "[[]", "*", "[*]", "?", "[?]")
+)
+
+func (b *sqlBuilder) like(filter *Filter, column string) string {
+	shape := likeShapes[filter.Operator]
+	value, ok := filter.Value.(string)
+	if !ok {
+		return b.fail(filter, "string operators need a string value")
+	}
+	if _, isRef := reference(value); isRef {
+		return b.fail(filter, "string operators cannot compare against references")
+	}
+	wildcard, escaper := "%", likeEscaper
+	if shape.caseSensitive && b.dialect == SQLite {
+		wildcard, escaper = "*", globEscaper
+	}
+	if !shape.caseSensitive {
+		value = strings.ToLower(value)
+	}
+	pattern := escaper.Replace(value)
+	if shape.prefix {
+		pattern = wildcard + pattern
+	}
+	if shape.suffix {
+		pattern += wildcard
+	}
+
+	not := ""
+	if shape.negated {
+		not = "NOT "
+	}
+	param := b.bind(pattern)
+	switch {
+	case shape.caseSensitive && b.dialect == SQLite:
+		return fmt.Sprintf("%s %sGLOB %s", column, not, param)
+	case shape.caseSensitive && b.dialect == MySQL:
+		return fmt.Sprintf("CAST(%s AS BINARY) %sLIKE %s ESCAPE '!'", column, not, param)
+	case shape.caseSensitive:
+		return fmt.Sprintf("%s %sLIKE %s ESCAPE '!'", column, not, param)
+	case b.dialect == Postgres:
+		return fmt.Sprintf("%s %sILIKE %s ESCAPE '!'", column, not, param)
+	}
+	return fmt.Sprintf("LOWER(%s) %sLIKE %s ESCAPE '!'", column, not, param)
+}
+
+// operand renders v as a column reference when it is a {{field}} reference
+// and as a bind parameter otherwise.
+func (b *sqlBuilder) operand(v any) (string, bool) {
+	if s, ok := v.(string); ok {
+		if _, isRef := reference(s); isRef {
+			return b.reference(s)
+		}
+	}
+	return b.bind(v), true
+}
+
+var fieldPath = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// reference renders a field, or a {{field}} reference, as a quoted column
+// name. References to anything but a plain field path cannot be rendered.
+func (b *sqlBuilder) reference(field string) (string, bool) {
+	if ref, isRef := reference(field); isRef {
+		field = strings.TrimSpace(ref)
+		if !fieldPath.MatchString(field) {
+			return "", false
+		}
+	}
+	if field == "" || strings.Contains(field, "#") {
+		return "", false
+	}
+	parts := strings.Split(field, ".")
+	for i, part := range parts {
+		parts[i] = b.quote(part)
+	}
+	return strings.Join(parts, "."), true
+}
+
+func (b *sqlBuilder) quote(identifier string) string {
+	if b.dialect == MySQL {
+		return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+func (b *sqlBuilder) bind(v any) string {
+	b.args = append(b.args, v)
+	if b.dialect == Postgres {
+		return "$" + strconv.Itoa(len(b.args))
+	}
+	return "?"
+}
+
+func (b *sqlBuilder) text(column string) string {
+	if b.dialect == MySQL {
+		return "CAST(" + column + " AS CHAR)"
+	}
+	return "CAST(" + column + " AS TEXT)"
+}
+
+// sqlValues returns the elements of a slice value.
+func sqlValues(value any) []any {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return []any{value}
+	}
+	values := make([]any, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values
+}
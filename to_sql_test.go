@@ -0,0 +1,80 @@
+package filters_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/oarkflow/filters"
+)
+
+func TestToSQL(t *testing.T) {
+	rule, err := filters.ParseSQL("(name = 'Jane' OR city LIKE 'New%') AND NOT age BETWEEN 18 AND 30 AND logged > {{created}} AND tags IN ('a', 'b')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		dialect filters.Dialect
+		want    string
+	}{
+		{filters.Postgres, `(("name" = $1 OR "city" ILIKE $2 ESCAPE '!') AND NOT ("age" BETWEEN $3 AND $4) AND "logged" > "created" AND "tags" IN ($5, $6))`},
+		{filters.MySQL, "((`name` = ? OR LOWER(`city`) LIKE ? ESCAPE '!') AND NOT (`age` BETWEEN ? AND ?) AND `logged` > `created` AND `tags` IN (?, ?))"},
+	}
+	wantArgs := []any{"Jane", "new%", int64(18), int64(30), "a", "b"}
+	for _, test := range tests {
+		clause, args, err := filters.ToSQL(rule, test.dialect)
+		if err != nil {
+			t.Fatalf("%s: %v", test.dialect, err)
+		}
+		if clause != test.want {
+			t.Errorf("%s:\n got %s\nwant %s", test.dialect, clause, test.want)
+		}
+		if !reflect.DeepEqual(args, wantArgs) {
+			t.Errorf("%s: args %#v, want %#v", test.dialect, args, wantArgs)
+		}
+	}
+
+	schema := filters.Schema{"name": filters.StringField, "age": filters.IntField, "tags": filters.StringField}
+	clause, args, err := schema.ToSQL(filters.NewFilterGroup(filters.AND, false,
+		filters.NewFilter("name", filters.Equal, "Jane"),
+		filters.NewFilter("age", filters.Equal, "30"),
+		filters.NewFilter("tags", filters.In, []any{"A", "b"}),
+	), filters.Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `(LOWER("name") = $1 AND "age" = $2 AND LOWER("tags") IN ($3, $4))`; clause != want {
+		t.Errorf("schema:\n got %s\nwant %s", clause, want)
+	}
+	if want := []any{"jane", "30", "a", "b"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("schema: args %#v, want %#v", args, want)
+	}
+
+	clause, args, err = filters.ToSQL(filters.NewFilter("user.name", filters.StartsWithCS, "50%_off"), filters.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"user"."name" GLOB ?`; clause != want || args[0] != "50%_off*" {
+		t.Errorf("got %s %v, want %s [50%%_off*]", clause, args, want)
+	}
+}
+
+func TestToSQLReportsUntranslatableNodes(t *testing.T) {
+	condition := filters.NewFilterGroup(filters.AND, false,
+		filters.NewFilter("age", filters.GreaterThan, 20),
+		filters.NewFilter("name", filters.Expression, "age > 26"),
+		filters.NewFilter("tags", filters.GreaterThanCount, 1),
+		filters.NewFilter("name", filters.Pattern, "^J"),
+	)
+	_, _, err := filters.ToSQL(condition, filters.SQLite)
+	var translateErr *filters.TranslateError
+	if !errors.As(err, &translateErr) {
+		t.Fatalf("got %v, want *TranslateError", err)
+	}
+	if len(translateErr.Nodes) != 3 {
+		t.Errorf("got %d untranslatable nodes, want 3: %v", len(translateErr.Nodes), err)
+	}
+	if _, _, err := filters.ToSQL(condition.Filters[0], "oracle"); err == nil {
+		t.Error("expected unsupported dialect error")
+	}
+}
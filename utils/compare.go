@@ -0,0 +1,321 @@
+package utils
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrIncomparable is returned by CompareValues for values without a common
+// order.
+var ErrIncomparable = errors.New("values are not comparable")
+
+// CompareValues orders a and b, returning -1, 0 or +1. The rules, tried in
+// this order, are:
+//
+//   - nil equals nil and is incomparable with anything else;
+//   - a time.Duration compares with durations, duration strings such as
+//     "1h30m" or "2d" and, as nanoseconds, with numbers;
+//   - a time.Time compares with times and strings holding a date or time;
+//   - numbers of every Go kind, json.Number, big.Int, big.Float, big.Rat and
+//     registered decimal types compare by value, and so do strings holding a
+//     decimal number when compared with a number. A float32 or float64 is
+//     compared with a decimal as the shortest decimal that reads back as the
+//     same float, and with other numbers as float64; all other comparisons
+//     are exact;
+//   - a bool compares with bools and with strings accepted by
+//     strconv.ParseBool, false before true;
+//   - two strings compare as times when both hold a date, else bytewise; a
+//     string that cannot be parsed as a number or bool is incomparable with
+//     one;
+//   - any other values are equal when reflect.DeepEqual says so and are
+//     otherwise incomparable.
+func CompareValues(a, b any) (int, error) {
+	if a == nil || b == nil {
+		if a == nil && b == nil {
+			return 0, nil
+		}
+		return 0, incomparable(a, b)
+	}
+	if c, ok, err := compareSpecial(a, b); ok {
+		return c, err
+	}
+	if c, ok, err := compareSpecial(b, a); ok {
+		return -c, err
+	}
+	x, xNumber := toNumber(a)
+	y, yNumber := toNumber(b)
+	switch {
+	case xNumber && yNumber:
+		return compareNumbers(x, y)
+	case xNumber:
+		return compareWithString(b, a, x, true)
+	case yNumber:
+		return compareWithString(a, b, y, false)
+	}
+	if as, ok := a.(string); ok {
+		if bs, ok := b.(string); ok {
+			return compareStrings(as, bs), nil
+		}
+	}
+	if reflect.TypeOf(a) == reflect.TypeOf(b) && reflect.DeepEqual(a, b) {
+		return 0, nil
+	}
+	return 0, incomparable(a, b)
+}
+
+// EqualValues reports whether a and b are equal under the rules of
+// CompareValues.
+func EqualValues(a, b any) (bool, error) {
+	c, err := CompareValues(a, b)
+	return err == nil && c == 0, err
+}
+
+func incomparable(a, b any) error {
+	return fmt.Errorf("%w: %T and %T", ErrIncomparable, a, b)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// compareSpecial orders a against b when a is a duration, time or bool,
+// reporting whether it applied.
+func compareSpecial(a, b any) (int, bool, error) {
+	switch x := a.(type) {
+	case time.Duration:
+		var y time.Duration
+		switch v := b.(type) {
+		case time.Duration:
+			y = v
+		case string:
+			d, err := ParseDuration(v)
+			if err != nil {
+				return 0, true, incomparable(a, b)
+			}
+			y = d
+		default:
+			n, ok := toNumber(b)
+			if !ok {
+				return 0, true, incomparable(a, b)
+			}
+			c, err := compareNumbers(number{kind: intNumber, i: int64(x)}, n)
+			return c, true, err
+		}
+		return cmp.Compare(x, y), true, nil
+	case time.Time:
+		var y time.Time
+		switch v := b.(type) {
+		case time.Time:
+			y = v
+		case string:
+			t, err := ParseTime(strings.TrimSpace(v))
+			if err != nil {
+				return 0, true, incomparable(a, b)
+			}
+			y = t
+		default:
+			return 0, true, incomparable(a, b)
+		}
+		return x.Compare(y), true, nil
+	case bool:
+		var y bool
+		switch v := b.(type) {
+		case bool:
+			y = v
+		case string:
+			parsed, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return 0, true, incomparable(a, b)
+			}
+			y = parsed
+		default:
+			return 0, true, incomparable(a, b)
+		}
+		switch {
+		case x == y:
+			return 0, true, nil
+		case y:
+			return -1, true, nil
+		}
+		return 1, true, nil
+	}
+	return 0, false, nil
+}
+
+// compareWithString orders other, which should be a string holding a decimal
+// number, against the number n, whose original value is original. Swapped
+// reports that the number was the left operand.
+func compareWithString(other, original any, n number, swapped bool) (int, error) {
+	s, ok := other.(string)
+	parsed, isNumber := parseDecimal(s)
+	if !ok || !isNumber {
+		if swapped {
+			return 0, incomparable(original, other)
+		}
+		return 0, incomparable(other, original)
+	}
+	c, err := compareNumbers(parsed, n)
+	if err != nil {
+		return 0, err
+	}
+	if swapped {
+		return -c, nil
+	}
+	return c, nil
+}
+
+func compareStrings(a, b string) int {
+	if IsValidDateTime(a) && IsValidDateTime(b) {
+		at, errA := ParseTime(a)
+		bt, errB := ParseTime(b)
+		if errA == nil && errB == nil {
+			return at.Compare(bt)
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+type numberKind int
+
+const (
+	intNumber numberKind = iota
+	uintNumber
+	floatNumber
+	exactNumber
+)
+
+// number holds a numeric value in the cheapest exact representation.
+type number struct {
+	kind numberKind
+	i    int64
+	u    uint64
+	f    float64
+	r    *big.Rat
+}
+
+func (n number) float() float64 {
+	switch n.kind {
+	case intNumber:
+		return float64(n.i)
+	case uintNumber:
+		return float64(n.u)
+	case exactNumber:
+		f, _ := n.r.Float64()
+		return f
+	}
+	return n.f
+}
+
+func (n number) rat() *big.Rat {
+	switch n.kind {
+	case intNumber:
+		return new(big.Rat).SetInt64(n.i)
+	case uintNumber:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(n.u))
+	}
+	return n.r
+}
+
+var decimalPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// parseDecimal reads a decimal number written out in a string.
+func parseDecimal(s string) (number, bool) {
+	s = strings.TrimSpace(s)
+	if !decimalPattern.MatchString(s) {
+		return number{}, false
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return number{kind: intNumber, i: i}, true
+	}
+	r, ok := new(big.Rat).SetString(s)
+	return number{kind: exactNumber, r: r}, ok
+}
+
+func toNumber(v any) (number, bool) {
+	if r, ok := registeredDecimal(v); ok {
+		return number{kind: exactNumber, r: r}, true
+	}
+	switch x := v.(type) {
+	case json.Number:
+		return parseDecimal(x.String())
+	case *big.Int:
+		return number{kind: exactNumber, r: new(big.Rat).SetInt(x)}, x != nil
+	case big.Int:
+		return number{kind: exactNumber, r: new(big.Rat).SetInt(&x)}, true
+	case *big.Rat:
+		return number{kind: exactNumber, r: x}, x != nil
+	case big.Rat:
+		return number{kind: exactNumber, r: &x}, true
+	case *big.Float:
+		if x == nil {
+			return number{}, false
+		}
+		return bigFloat(x), true
+	case big.Float:
+		return bigFloat(&x), true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Type() == durationType {
+		return number{}, false
+	}
+	switch {
+	case rv.CanInt():
+		return number{kind: intNumber, i: rv.Int()}, true
+	case rv.CanUint():
+		return number{kind: uintNumber, u: rv.Uint()}, true
+	case rv.CanFloat():
+		return number{kind: floatNumber, f: rv.Float()}, true
+	}
+	return number{}, false
+}
+
+func bigFloat(x *big.Float) number {
+	if x.IsInf() {
+		return number{kind: floatNumber, f: math.Inf(x.Sign())}
+	}
+	r, _ := x.Rat(nil)
+	return number{kind: exactNumber, r: r}
+}
+
+func compareNumbers(x, y number) (int, error) {
+	if x.kind == floatNumber && y.kind == exactNumber {
+		if r, ok := floatRat(x.f); ok {
+			return r.Cmp(y.r), nil
+		}
+	}
+	if x.kind == exactNumber && y.kind == floatNumber {
+		if r, ok := floatRat(y.f); ok {
+			return x.r.Cmp(r), nil
+		}
+	}
+	switch {
+	case x.kind == floatNumber || y.kind == floatNumber:
+		fx, fy := x.float(), y.float()
+		if math.IsNaN(fx) || math.IsNaN(fy) {
+			return 0, fmt.Errorf("%w: NaN", ErrIncomparable)
+		}
+		return cmp.Compare(fx, fy), nil
+	case x.kind == intNumber && y.kind == intNumber:
+		return cmp.Compare(x.i, y.i), nil
+	case x.kind == uintNumber && y.kind == uintNumber:
+		return cmp.Compare(x.u, y.u), nil
+	case x.kind == intNumber && y.kind == uintNumber:
+		if x.i < 0 {
+			return -1, nil
+		}
+		return cmp.Compare(uint64(x.i), y.u), nil
+	case x.kind == uintNumber && y.kind == intNumber:
+		if y.i < 0 {
+			return 1, nil
+		}
+		return cmp.Compare(x.u, uint64(y.i)), nil
+	}
+	return x.rat().Cmp(y.rat()), nil
+}
@@ -0,0 +1,84 @@
+package utils_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/oarkflow/filters/utils"
+)
+
+type level uint8
+
+func TestCompareValues(t *testing.T) {
+	day := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	tests := []struct {
+		name string
+		a, b any
+		want int
+	}{
+		{"int int", 1, 2, -1},
+		{"int float", 30, 30.0, 0},
+		{"float int", 30.5, 30, 1},
+		{"int64 uint", int64(-1), uint(1), -1},
+		{"max uint int", uint64(math.MaxUint64), math.MaxInt64, 1},
+		{"int8 float32", int8(3), float32(2.5), 1},
+		{"named kind", level(2), 2, 0},
+		{"json number int", json.Number("42"), 42, 0},
+		{"json number float", json.Number("0.1"), 0.1, 0},
+		{"json number exact", json.Number("0.30000000000000001"), json.Number("0.3"), 1},
+		{"big int", huge, int64(math.MaxInt64), 1},
+		{"big int float", big.NewInt(10), 10.0, 0},
+		{"big float", big.NewFloat(2.5), json.Number("2.5"), 0},
+		{"big rat", big.NewRat(1, 3), 0.3, 1},
+		{"decimal string", "10.50", 10.5, 0},
+		{"decimal string order", "9", 10, -1},
+		{"number decimal string", 10, "9.99", 1},
+		{"strings", "apple", "banana", -1},
+		{"numeric strings", "10", "9", -1},
+		{"date strings", "2024-01-31", "2024-01-31 00:00:00", 0},
+		{"bool", false, true, -1},
+		{"bool string", true, "TRUE", 0},
+		{"time", day, day.Add(time.Hour), -1},
+		{"time string", day, "2024-02-01", -1},
+		{"string time", "2024-02-01", day, 1},
+		{"duration", time.Minute, time.Second, 1},
+		{"duration string", 90 * time.Minute, "1h30m", 0},
+		{"duration nanoseconds", time.Microsecond, 1000, 0},
+		{"nil nil", nil, nil, 0},
+		{"slices", []int{1, 2}, []int{1, 2}, 0},
+	}
+	for _, test := range tests {
+		got, err := utils.CompareValues(test.a, test.b)
+		if err != nil || got != test.want {
+			t.Errorf("%s: CompareValues(%v, %v) = %d, %v, want %d", test.name, test.a, test.b, got, err, test.want)
+		}
+		if back, err := utils.CompareValues(test.b, test.a); err != nil || back != -test.want {
+			t.Errorf("%s: reversed = %d, %v, want %d", test.name, back, err, -test.want)
+		}
+	}
+}
+
+func TestCompareValuesIncomparable(t *testing.T) {
+	for _, pair := range [][2]any{
+		{nil, 0},
+		{math.NaN(), 1.0},
+		{time.Now(), 5},
+		{true, 1},
+		{true, "abc"},
+		{"abc", 5},
+		{25, "2024-01-02"},
+		{"2024-01-02 03:04:05", 20},
+		{time.Second, "soon"},
+		{[]int{1}, []int{2}},
+		{map[string]any{}, 1},
+	} {
+		if _, err := utils.CompareValues(pair[0], pair[1]); !errors.Is(err, utils.ErrIncomparable) {
+			t.Errorf("CompareValues(%v, %v): got %v, want ErrIncomparable", pair[0], pair[1], err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+var decimalTypes sync.Map // reflect.Type -> func(any) (*big.Rat, error)
+
+// RegisterDecimal makes values of the same type as sample, such as a
+// third-party decimal type, compare exactly as the value toRat returns.
+func RegisterDecimal(sample any, toRat func(any) (*big.Rat, error)) {
+	decimalTypes.Store(reflect.TypeOf(sample), toRat)
+}
+
+// IsDecimalType reports whether values of type t are decimals: json.Number,
+// big.Rat and registered decimal types.
+func IsDecimalType(t reflect.Type) bool {
+	switch t {
+	case reflect.TypeOf(json.Number("")), reflect.TypeOf(big.Rat{}), reflect.TypeOf(&big.Rat{}):
+		return true
+	}
+	_, ok := decimalTypes.Load(t)
+	return ok
+}
+
+func registeredDecimal(v any) (*big.Rat, bool) {
+	toRat, ok := decimalTypes.Load(reflect.TypeOf(v))
+	if !ok {
+		return nil, false
+	}
+	r, err := toRat.(func(any) (*big.Rat, error))(v)
+	return r, err == nil && r != nil
+}
+
+// IsDecimal reports whether v holds an exact decimal: a json.Number, a
+// big.Rat, a registered decimal type or a string holding a number with a
+// fraction.
+func IsDecimal(v any) bool {
+	switch x := v.(type) {
+	case json.Number, *big.Rat, big.Rat:
+		return true
+	case string:
+		n, ok := parseDecimal(x)
+		return ok && n.kind == exactNumber
+	}
+	_, ok := registeredDecimal(v)
+	return ok
+}
+
+// ToRat returns the exact value of the number v, reading a float as the
+// shortest decimal that reads back as the same float and a string as the
+// decimal number it holds.
+func ToRat(v any) (*big.Rat, bool) {
+	n, ok := toNumber(v)
+	if !ok {
+		s, isString := v.(string)
+		if !isString {
+			return nil, false
+		}
+		if n, ok = parseDecimal(s); !ok {
+			return nil, false
+		}
+	}
+	if n.kind == floatNumber {
+		return floatRat(n.f)
+	}
+	return n.rat(), true
+}
+
+// floatRat converts f through its shortest decimal representation, so that
+// 0.1 becomes 1/10 rather than the binary fraction nearest to it.
+func floatRat(f float64) (*big.Rat, bool) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, false
+	}
+	return new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+}
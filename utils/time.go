@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oarkflow/date"
+)
+
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond, "us": time.Microsecond, "µs": time.Microsecond, "ms": time.Millisecond,
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "wk": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+// ParseDuration reads a duration as time.ParseDuration does, also accepting
+// days and weeks and spelled-out units separated by spaces, as in "30d",
+// "1w2d", "1d 12h" or "7 days".
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	rest, negative := s, false
+	if rest != "" && (rest[0] == '-' || rest[0] == '+') {
+		negative = rest[0] == '-'
+		rest = strings.TrimSpace(rest[1:])
+	}
+	if rest == "" {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	var total time.Duration
+	for rest != "" {
+		n := strings.IndexFunc(rest, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if n <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		number := rest[:n]
+		rest = strings.TrimLeft(rest[n:], " ")
+		u := strings.IndexAny(rest, " 0123456789")
+		if u < 0 {
+			u = len(rest)
+		}
+		unit, ok := durationUnits[strings.ToLower(rest[:u])]
+		if !ok {
+			return 0, fmt.Errorf("invalid duration %q: unknown unit %q", s, rest[:u])
+		}
+		rest = strings.TrimLeft(rest[u:], " ")
+		if i, err := strconv.ParseInt(number, 10, 64); err == nil {
+			if i > math.MaxInt64/int64(unit) {
+				return 0, fmt.Errorf("invalid duration %q: out of range", s)
+			}
+			total += time.Duration(i) * unit
+			continue
+		}
+		f, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		total += time.Duration(f * float64(unit))
+	}
+	if negative {
+		return -total, nil
+	}
+	return total, nil
+}
+
+// ParseTimeIn is like ParseTime, but reads dates and times written without a
+// zone or offset in loc.
+func ParseTimeIn(s any, loc *time.Location) (time.Time, error) {
+	if str, ok := s.(string); ok {
+		return date.ParseIn(strings.TrimSpace(str), loc)
+	}
+	return ParseTime(s)
+}
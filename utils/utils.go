@@ -11,78 +11,11 @@ import (
 	"github.com/oarkflow/date"
 )
 
+// Compare orders a and b like CompareValues, treating values that cannot
+// be compared as equal.
 func Compare(a, b any) int {
-	switch a := a.(type) {
-	case int:
-		ai := a
-		bi := b.(int)
-		switch {
-		case ai < bi:
-			return -1
-		case ai > bi:
-			return 1
-		default:
-			return 0
-		}
-	case string:
-		isADateTime := IsValidDateTime(a)
-		if !isADateTime {
-			switch b := b.(type) {
-			case string:
-				return strings.Compare(a, b)
-			default:
-				return strings.Compare(a, fmt.Sprint(b))
-			}
-		}
-		at, err := ParseTime(a)
-		if err != nil {
-			return 0
-		}
-		switch b := b.(type) {
-		case string:
-			bt, err := ParseTime(b)
-			if err != nil {
-				return 0
-			}
-			switch {
-			case at.Before(bt):
-				return -1
-			case at.After(bt):
-				return 1
-			default:
-				return 0
-			}
-		case time.Time:
-			switch {
-			case at.Before(b):
-				return -1
-			case at.After(b):
-				return 1
-			default:
-				return 0
-			}
-		}
-		return 0
-	case time.Time:
-		at, err := ParseTime(a)
-		if err != nil {
-			return 0
-		}
-		bt, err := ParseTime(b)
-		if err != nil {
-			return 0
-		}
-		switch {
-		case at.Before(bt):
-			return -1
-		case at.After(bt):
-			return 1
-		default:
-			return 0
-		}
-	default:
-		return 0
-	}
+	c, _ := CompareValues(a, b)
+	return c
 }
 
 func IsValidDateTime(str string) bool {